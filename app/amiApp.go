@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+	"ired.com/callcenter/utils"
+)
+
+// archivo con las reglas de rastreo de llamadas, ver callrules_example.json
+const CallRulesFile = ".callrules"
+
+// archivo con el catalogo de tipificaciones, ver dispositions_example.json
+const DispositionsFile = ".dispositions"
+
+// archivo con las suscripciones de webhooks, ver webhooks_example.json
+const WebhooksFile = ".webhooks"
+
+// archivo con las centrales asterisk, ver pbx_example.json
+const PbxFile = ".pbx"
+
+// LoadPbxConfig carga las centrales, sin archivo se usan las variables AMI_* del .env
+func LoadPbxConfig() {
+	if err := utils.LoadPbxConfig(PbxFile); err != nil {
+		utils.Fatalf("Failed to load pbx config: %v", err)
+	}
+}
+
+// LoadCallRules carga las reglas de rastreo, un archivo invalido detiene el servicio
+func LoadCallRules() {
+	if _, err := repo.LoadCallRules(CallRulesFile); err != nil {
+		utils.Fatalf("Failed to load call rules: %v", err)
+	}
+}
+
+// LoadDispositions carga el catalogo de tipificaciones y su validacion, un archivo
+// invalido detiene el servicio
+func LoadDispositions() {
+	if _, err := repo.LoadDispositions(DispositionsFile); err != nil {
+		utils.Fatalf("Failed to load dispositions: %v", err)
+	}
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterStructValidation(repo.ValidateDisposition, models.DispositionReq{})
+		v.RegisterStructValidation(repo.ValidateDispositionsReport, models.DispositionsReportReq{})
+	}
+}
+
+// LoadWebhooks carga las suscripciones de webhooks, un archivo invalido detiene el servicio
+func LoadWebhooks() {
+	if _, err := repo.LoadWebhooks(WebhooksFile); err != nil {
+		utils.Fatalf("Failed to load webhooks: %v", err)
+	}
+}
+
+// StartAmiListener lanza un supervisor de eventos AMI por central, cada evento
+// recibido queda en el journal logs/ami-events.log
+func StartAmiListener() {
+	repo.StartAmiJournal()
+	db := models.ConnMysql{Conn: PoolMysql, Ctx: context.Background()}
+	repo.StartWebhooks(db)
+	for _, pbx := range utils.PbxList() {
+		go repo.AmiListener(db, pbx)
+	}
+}
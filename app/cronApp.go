@@ -73,12 +73,28 @@ func LoadCrontab() {
 				gocron.NewTask(chatAutoOpen),
 				gocron.WithSingletonMode(gocron.LimitModeReschedule),
 			)
-		case "service_ami_events":
+		case "service_call_janitor":
+			_, err = scheduler.NewJob(
+				gocron.CronJob(taskConfig.Schedule, false),
+				gocron.NewTask(serviceCallJanitor),
+				gocron.WithSingletonMode(gocron.LimitModeReschedule),
+			)
+		case "service_callbacks":
+			_, err = scheduler.NewJob(
+				gocron.CronJob(taskConfig.Schedule, false),
+				gocron.NewTask(serviceCallbacks),
+				gocron.WithSingletonMode(gocron.LimitModeReschedule),
+			)
+		case "service_cdr_reconcile":
 			_, err = scheduler.NewJob(
 				gocron.CronJob(taskConfig.Schedule, false),
-				gocron.NewTask(serviceAmiEvents),
+				gocron.NewTask(serviceCdrReconcile),
 				gocron.WithSingletonMode(gocron.LimitModeReschedule),
 			)
+		case "service_ami_events":
+			// ahora los eventos AMI son atendidos por el listener que arranca en main.go
+			utils.Logline("Task service_ami_events is deprecated, ignoring it", taskConfig.Task)
+			continue
 		default:
 			utils.Logline("Unknown task", taskConfig.Task)
 		}
@@ -129,18 +145,58 @@ func chatAutoOpen() {
 	}
 }
 
-func serviceAmiEvents() {
+func serviceCallJanitor() {
 	defer func() {
 		if r := recover(); r != nil {
-			utils.Logline("Recovered from panic <<service_ami_events>>: %v", r)
+			utils.Logline("Recovered from panic <<service_call_janitor>>: %v", r)
 		}
 	}()
 
 	//set variables for handling mysql conn
-	db := models.ConnMysql{Conn: PoolMysql, Ctx: context.Background()}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: PoolMysql, Ctx: ctx}
 
 	// run actual task
-	if err := repo.AmiEvents(db, "cronJob"); err != nil {
-		utils.Logline("Error on service_ami_events")
+	if _, err := repo.CallJanitor(db); err != nil {
+		utils.Logline("Error on service_call_janitor", err)
+	}
+}
+
+func serviceCallbacks() {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.Logline("Recovered from panic <<service_callbacks>>: %v", r)
+		}
+	}()
+
+	//set variables for handling mysql conn, los originate esperan a que el agente conteste
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	db := models.ConnMysql{Conn: PoolMysql, Ctx: ctx}
+
+	// run actual task
+	if _, err := repo.CallbackDialer(db); err != nil {
+		utils.Logline("Error on service_callbacks", err)
+	}
+}
+
+func serviceCdrReconcile() {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.Logline("Recovered from panic <<service_cdr_reconcile>>: %v", r)
+		}
+	}()
+
+	//set variables for handling mysql conn of calls and of the asterisk cdr
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	db := models.ConnMysql{Conn: PoolMysql, Ctx: ctx}
+	cdr := models.ConnMysql{Conn: PoolCdr, Ctx: ctx}
+
+	// run actual task, CDR_RECONCILE_DRY_RUN=true only reports the differences
+	req := models.CdrReconcileReq{DryRun: os.Getenv("CDR_RECONCILE_DRY_RUN") == "true"}
+	if _, err := repo.CdrReconcile(db, cdr, req); err != nil {
+		utils.Logline("Error on service_cdr_reconcile", err)
 	}
 }
@@ -73,3 +73,23 @@ func InitDbMysql() {
 func CloseDbMysql() {
 	PoolMysql.Close()
 }
+
+// PoolCdr base de datos del CDR de asterisk, sin DB_CDR es la misma conexion de mysql
+var PoolCdr *sql.DB
+
+func InitDbCdr() {
+	if os.Getenv("DB_CDR") == "" {
+		PoolCdr = PoolMysql
+		return
+	}
+
+	var err error
+	PoolCdr, err = sql.Open("mysql", os.Getenv("DB_CDR"))
+	if err != nil {
+		utils.Fatalf("Error connecting to mysql(cdr): %v", err)
+	}
+	PoolCdr.SetMaxOpenConns(2)
+	PoolCdr.SetMaxIdleConns(1)
+	PoolCdr.SetConnMaxLifetime(time.Hour)
+	PoolCdr.SetConnMaxIdleTime(time.Minute * 30)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+	"ired.com/callcenter/utils"
+)
+
+// Replay modo cli que reproduce eventos AMI grabados contra una base de datos,
+// ej: callcenter replay --file events.jsonl --speed 10
+// retorna el codigo de salida del proceso
+func Replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := flags.String("file", "", "recorded AMI events, JSON lines or raw AMI dump (required)")
+	dsn := flags.String("db", "", "mysql dsn of the call_center database, default DB_MYSQL of .env")
+	speed := flags.Float64("speed", 0, "1 keeps the original timing, 10 is ten times faster, 0 does not wait")
+	rules := flags.String("rules", CallRulesFile, "call rules file")
+	pbx := flags.String("pbx", utils.DefaultPbxName, "pbx name of the events that do not include it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *speed < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// el .env es opcional si la base de datos viene por parametro
+	if err := godotenv.Load(); err != nil && *dsn == "" {
+		fmt.Fprintln(os.Stderr, "replay: .env not found, use --db")
+		return 1
+	}
+	if *dsn != "" {
+		os.Setenv("DB_MYSQL", *dsn)
+	}
+
+	if _, err := repo.LoadCallRules(*rules); err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		return 1
+	}
+
+	input, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		return 1
+	}
+	defer input.Close()
+
+	events, err := repo.ReadAmiEvents(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: invalid events file %s: %v\n", *file, err)
+		return 1
+	}
+
+	InitDbMysql()
+	defer CloseDbMysql()
+	if err := PoolMysql.Ping(); err != nil {
+		fmt.Fprintln(os.Stderr, "replay: failed to connect to mysql:", err)
+		return 1
+	}
+
+	db := models.ConnMysql{Conn: PoolMysql, Ctx: context.Background()}
+	report := repo.ReplayAmiEvents(db, events, *speed, *pbx)
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(report)
+	return 0
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"ired.com/callcenter/utils"
+)
+
+// WebhookSink modo cli que levanta un receptor de webhooks local para pruebas, valida la
+// firma y muestra cada entrega, ej: callcenter webhook-sink --secret xxx --fail 2
+// retorna el codigo de salida del proceso
+func WebhookSink(args []string) int {
+	flags := flag.NewFlagSet("webhook-sink", flag.ContinueOnError)
+	listen := flags.String("listen", "127.0.0.1:7010", "address to listen")
+	secret := flags.String("secret", "", "secret of the webhook to verify the signature (required)")
+	fail := flags.Int("fail", 0, "answer 500 to the first n deliveries to test the retries")
+	status := flags.Int("status", http.StatusOK, "status answered once the failures are done")
+	tolerance := flags.Duration("tolerance", 5*time.Minute, "max age of the signature timestamp")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *secret == "" {
+		flags.Usage()
+		return 2
+	}
+
+	var received atomic.Int64
+	out := json.NewEncoder(os.Stdout)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n := received.Add(1)
+
+		signature := "valid"
+		answer := *status
+		if err := utils.VerifyWebhook(*secret, r.Header.Get(utils.WebhookHeaderSignature), r.Header.Get(utils.WebhookHeaderTimestamp), body, *tolerance); err != nil {
+			signature = err.Error()
+			answer = http.StatusUnauthorized
+		} else if n <= int64(*fail) {
+			answer = http.StatusInternalServerError
+		}
+
+		var payload any = json.RawMessage(body)
+		if !json.Valid(body) {
+			payload = string(body)
+		}
+		out.Encode(map[string]any{
+			"received_at": time.Now(),
+			"path":        r.URL.Path,
+			"event":       r.Header.Get(utils.WebhookHeaderEvent),
+			"delivery":    r.Header.Get(utils.WebhookHeaderDelivery),
+			"signature":   signature,
+			"answer":      answer,
+			"payload":     payload,
+		})
+		w.WriteHeader(answer)
+	})
+
+	fmt.Fprintln(os.Stderr, "webhook-sink: listening on", *listen)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		fmt.Fprintln(os.Stderr, "webhook-sink:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	ginI18n "github.com/gin-contrib/i18n"
+	"github.com/gin-gonic/gin"
+	"ired.com/callcenter/app"
+	"ired.com/callcenter/middlewares"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+)
+
+func AdminRoutes(r *gin.Engine) {
+	admin := r.Group("/admin")
+	{
+		admin.GET("/ami-listener-status", middlewares.BasicAuth(), amiListenerStatus)
+		admin.GET("/call-recovery-report", middlewares.BasicAuth(), callRecoveryReport)
+		admin.GET("/call-rules", middlewares.BasicAuth(), callRules)
+		admin.POST("/call-rules/reload", middlewares.BasicAuth(), reloadCallRules)
+		admin.POST("/dispositions/reload", middlewares.BasicAuth(), reloadDispositions)
+		admin.GET("/ami-events", middlewares.BasicAuth(), amiEvents)
+		admin.GET("/call-janitor-report", middlewares.BasicAuth(), callJanitorReport)
+		admin.GET("/cdr-reconcile-report", middlewares.BasicAuth(), cdrReconcileReport)
+		admin.POST("/cdr-reconcile", middlewares.BasicAuth(), cdrReconcile)
+	}
+}
+
+// @Summary 			Get status of the AMI events listener
+// @Description 	muestra el estado de la sesion AMI de cada central que escucha eventos: conectado desde, cantidad de reconexiones y ultimo error, ademas de las metricas de los workers
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.AmiServiceStatus}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/ami-listener-status [get]
+func amiListenerStatus(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: repo.GetAmiListenerStatus(),
+		},
+	)
+}
+
+// @Summary 			Get report of the calls recovered on startup
+// @Description 	muestra el resultado de la ultima reconciliacion de current_calls contra los canales vivos de cada central, llamadas retomadas y cerradas
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=[]models.CallRecoveryReport}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/call-recovery-report [get]
+func callRecoveryReport(c *gin.Context) {
+	report, err := repo.GetCallRecoveryReport()
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: report,
+		},
+	)
+}
+
+// @Summary 			Get active call tracking rules
+// @Description 	muestra las reglas activas de rastreo de llamadas: patrones de extensiones, contextos, colas y campañas
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.CallRules}
+// @Router 				/admin/call-rules [get]
+func callRules(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: repo.GetCallRules(),
+		},
+	)
+}
+
+// @Summary 			Reload call tracking rules
+// @Description 	vuelve a leer el archivo .callrules, si es invalido se retorna el error de validacion y se mantienen las reglas activas
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.CallRules}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/call-rules/reload [post]
+func reloadCallRules(c *gin.Context) {
+	rules, err := repo.LoadCallRules(app.CallRulesFile)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: rules,
+		},
+	)
+}
+
+// @Summary 			Reload catalogue of disposition codes
+// @Description 	vuelve a leer el archivo .dispositions, si es invalido se retorna el error de validacion y se mantiene el catalogo activo
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.Dispositions}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/dispositions/reload [post]
+func reloadDispositions(c *gin.Context) {
+	dispositions, err := repo.LoadDispositions(app.DispositionsFile)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: dispositions,
+		},
+	)
+}
+
+// @Summary 			Get raw AMI events of a call
+// @Description 	busca en el journal de eventos AMI la secuencia completa de eventos de una llamada por su Linkedid, incluye los eventos de transferencia donde aparece el Linkedid
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				linkedid query string true "Linkedid de la llamada"
+// @Param 				days query int false "dias hacia atras a revisar, por defecto 1, maximo 30"
+// @Success 			200 {object} models.SuccessResponse{record=[]models.AmiJournalEntry}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/ami-events [get]
+func amiEvents(c *gin.Context) {
+	// Bind and Validate the query params
+	var eventsReq models.AmiEventsReq
+	if err := c.ShouldBindQuery(&eventsReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+	if eventsReq.Days == 0 {
+		eventsReq.Days = 1
+	}
+
+	events, err := repo.AmiJournalByLinkedid(eventsReq.Linkedid, eventsReq.Days)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: events,
+		},
+	)
+}
+
+// @Summary 			Get report of the last stale calls cleanup
+// @Description 	muestra el resultado de la ultima ejecucion del janitor: llamadas que superaron CALL_MAX_AGE, cuantas siguen vivas en asterisk y cuales se cerraron como 'Cerrada por sistema'
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.CallJanitorReport}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/call-janitor-report [get]
+func callJanitorReport(c *gin.Context) {
+	report, err := repo.GetCallJanitorReport()
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: report,
+		},
+	)
+}
+
+// @Summary 			Get report of the last CDR reconciliation
+// @Description 	muestra el resultado de la ultima conciliacion de calls contra el CDR de asterisk: llamadas faltantes, abiertas, con estado final o duracion distinta y la accion tomada
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.CdrReconcileReport}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/cdr-reconcile-report [get]
+func cdrReconcileReport(c *gin.Context) {
+	report, err := repo.GetCdrReconcileReport()
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: report,
+		},
+	)
+}
+
+// @Summary 			Run the CDR reconciliation
+// @Description 	concilia calls contra el CDR de asterisk en el rango indicado, sin fechas usa la ventana de la tarea service_cdr_reconcile. Con dry_run solo reporta las diferencias sin corregir calls
+// @Tags 					Admin
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				date_from query string false "desde (YYYY-MM-DD HH:MM:SS)"
+// @Param 				date_to query string false "hasta (YYYY-MM-DD HH:MM:SS)"
+// @Param 				dry_run query bool false "solo reportar"
+// @Success 			200 {object} models.SuccessResponse{record=models.CdrReconcileReport}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/cdr-reconcile [post]
+func cdrReconcile(c *gin.Context) {
+	// Bind and Validate the query params
+	var reconcileReq models.CdrReconcileReq
+	if err := c.ShouldBindQuery(&reconcileReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn of calls and of the asterisk cdr
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+	cdr := models.ConnMysql{Conn: app.PoolCdr, Ctx: ctx}
+
+	report, err := repo.CdrReconcile(db, cdr, reconcileReq)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: report,
+		},
+	)
+}
@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	ginI18n "github.com/gin-contrib/i18n"
 	"github.com/gin-gonic/gin"
+	"ired.com/callcenter/app"
 	"ired.com/callcenter/middlewares"
 	"ired.com/callcenter/models"
 	"ired.com/callcenter/repo"
@@ -15,6 +19,8 @@ func AmiRoutes(r *gin.Engine) {
 	cron := r.Group("/ami")
 	{
 		cron.POST("/hangup-call", middlewares.ApiRestAuth(), hangupCall)
+		cron.POST("/originate", middlewares.ApiRestAuth(), originateCall)
+		cron.GET("/originate/:id", middlewares.ApiRestAuth(), getOriginate)
 	}
 }
 
@@ -74,3 +80,123 @@ func hangupCall(c *gin.Context) {
 		models.SuccessResponse{Notice: ginI18n.MustGetMessage(c, "formOK")},
 	)
 }
+
+// @Summary 			Click-to-call desde la extension de un agente
+// @Description 	repica la extension del agente y al contestar marca el numero. El numero debe cumplir ORIGINATE_ALLOWED_PATTERN y no puede estar marcado como dnc ni en call_blacklist. La respuesta llega al encolar el Originate con status queued, el resultado y el uniqueid llegan despues con el evento originate.response del bus (websocket, SSE y webhooks) o consultando /ami/originate/{id}. La llamada queda en calls como saliente
+// @Tags 					Ami
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				originate body models.OriginateReq true "extension del agente, numero y opciones del Originate"
+// @Success 			200 {object} models.SuccessResponse{record=models.CallOriginate}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			403 {object} models.ErrorResponse
+// @Failure 			409 {object} models.ErrorResponse
+// @Router 				/ami/originate [post]
+func originateCall(c *gin.Context) {
+	var originateReq models.OriginateReq
+	if !bindJsonBody(c, &originateReq) {
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	originate, err := repo.OriginateCall(db, originateReq, c.GetString("authUser"))
+	if err != nil {
+		originateError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "formOK"),
+			Record: originate,
+		},
+	)
+}
+
+// @Summary 			Get a click-to-call
+// @Description 	estado de la llamada originada: queued hasta que llega el OriginateResponse, luego success con el uniqueid y canal del agente o failure con la razon
+// @Tags 					Ami
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la llamada originada"
+// @Success 			200 {object} models.SuccessResponse{record=models.CallOriginate}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Router 				/ami/originate/{id} [get]
+func getOriginate(c *gin.Context) {
+	var idReq models.OriginateIdReq
+	if err := c.ShouldBindUri(&idReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	originate, err := repo.GetOriginate(db, idReq.Id)
+	if err != nil {
+		originateError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: originate,
+		},
+	)
+}
+
+func originateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repo.ErrOriginateNotFound):
+		c.AbortWithStatusJSON(
+			http.StatusNotFound,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "recordDontExist")},
+		)
+	case errors.Is(err, repo.ErrPhoneDnc):
+		c.AbortWithStatusJSON(
+			http.StatusConflict,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "phoneDnc")},
+		)
+	case errors.Is(err, repo.ErrPhoneBlacklisted):
+		c.AbortWithStatusJSON(
+			http.StatusConflict,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "phoneBlacklisted")},
+		)
+	case errors.Is(err, repo.ErrPhoneNotAllowed):
+		c.AbortWithStatusJSON(
+			http.StatusForbidden,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "phoneNotAllowed")},
+		)
+	case errors.Is(err, repo.ErrOriginateAgentBusy):
+		c.AbortWithStatusJSON(
+			http.StatusConflict,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "agentBusy")},
+		)
+	case errors.Is(err, repo.ErrOriginateAgent):
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "agentNotFound")},
+		)
+	default:
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+	}
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	ginI18n "github.com/gin-contrib/i18n"
+	"github.com/gin-gonic/gin"
+	"ired.com/callcenter/app"
+	"ired.com/callcenter/middlewares"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+)
+
+func CallbackRoutes(r *gin.Engine) {
+	callbacks := r.Group("/callbacks")
+	{
+		callbacks.GET("", middlewares.ApiRestAuth(), callbacksList)
+		callbacks.GET("/:id", middlewares.ApiRestAuth(), callbackDetail)
+		callbacks.POST("/:id/claim", middlewares.ApiRestAuth(), callbackClaim)
+		callbacks.POST("/:id/close", middlewares.ApiRestAuth(), callbackClose)
+	}
+}
+
+// @Summary 			List callbacks of abandoned calls
+// @Description 	lista de rellamadas de las llamadas entrantes no atendidas, una por telefono con la cantidad de abandonos. Sin status retorna las abiertas
+// @Tags 					Callbacks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				status query string false "estado de la rellamada" Enums(pending, calling, claimed, closed)
+// @Param 				queue query string false "cola donde abandono"
+// @Param 				phone query string false "telefono del cliente"
+// @Success 			200 {object} models.SuccessResponse{record=[]models.Callback}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/callbacks [get]
+func callbacksList(c *gin.Context) {
+	// Bind and Validate the query params
+	var callbacksReq models.CallbacksReq
+	if err := c.ShouldBindQuery(&callbacksReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	callbacks, err := repo.Callbacks(db, callbacksReq)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: callbacks,
+		},
+	)
+}
+
+// @Summary 			Get a callback with its attempts
+// @Description 	rellamada con cada intento originado, cada intento indica la llamada abandonada original y el agente
+// @Tags 					Callbacks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la rellamada"
+// @Success 			200 {object} models.SuccessResponse{record=models.Callback}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Router 				/callbacks/{id} [get]
+func callbackDetail(c *gin.Context) {
+	var idReq models.CallbackIdReq
+	if err := c.ShouldBindUri(&idReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	callback, err := repo.GetCallback(db, idReq.Id)
+	if err != nil {
+		callbackError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: callback,
+		},
+	)
+}
+
+// @Summary 			Claim a callback
+// @Description 	el agente toma la rellamada pendiente para devolver la llamada
+// @Tags 					Callbacks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la rellamada"
+// @Param 				claim body models.CallbackClaimReq true "extension del agente"
+// @Success 			200 {object} models.SuccessResponse{record=models.Callback}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Failure 			409 {object} models.ErrorResponse
+// @Router 				/callbacks/{id}/claim [post]
+func callbackClaim(c *gin.Context) {
+	var idReq models.CallbackIdReq
+	if err := c.ShouldBindUri(&idReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	var claimReq models.CallbackClaimReq
+	if !bindJsonBody(c, &claimReq) {
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	callback, err := repo.ClaimCallback(db, idReq.Id, claimReq.Agent)
+	if err != nil {
+		callbackError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "formOK"),
+			Record: callback,
+		},
+	)
+}
+
+// @Summary 			Close a callback
+// @Description 	cierra la rellamada con su resultado, no se puede cerrar mientras se esta originando
+// @Tags 					Callbacks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la rellamada"
+// @Param 				close body models.CallbackCloseReq true "resultado de la rellamada"
+// @Success 			200 {object} models.SuccessResponse{record=models.Callback}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Failure 			409 {object} models.ErrorResponse
+// @Router 				/callbacks/{id}/close [post]
+func callbackClose(c *gin.Context) {
+	var idReq models.CallbackIdReq
+	if err := c.ShouldBindUri(&idReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	var closeReq models.CallbackCloseReq
+	if !bindJsonBody(c, &closeReq) {
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	callback, err := repo.CloseCallback(db, idReq.Id, closeReq, c.GetString("authUser"))
+	if err != nil {
+		callbackError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "formOK"),
+			Record: callback,
+		},
+	)
+}
+
+// bindJsonBody valida el json del body, si no es valido responde 400
+func bindJsonBody(c *gin.Context, req any) bool {
+	// validate if body exist
+	if c.Request.ContentLength == 0 {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorFailedBody")},
+		)
+		return false
+	}
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		if strings.Contains(err.Error(), "invalid character") || strings.Contains(err.Error(), "unmarshal") {
+			c.AbortWithStatusJSON(
+				http.StatusBadRequest,
+				models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "invalidJson")},
+			)
+			return false
+		}
+
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return false
+	}
+	return true
+}
+
+func callbackError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repo.ErrCallbackNotFound):
+		c.AbortWithStatusJSON(
+			http.StatusNotFound,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "recordDontExist")},
+		)
+	case errors.Is(err, repo.ErrCallbackNotAvailable):
+		c.AbortWithStatusJSON(
+			http.StatusConflict,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "callbackNotAvailable")},
+		)
+	default:
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorUpdateRecord")},
+		)
+	}
+}
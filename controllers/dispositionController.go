@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	ginI18n "github.com/gin-contrib/i18n"
+	"github.com/gin-gonic/gin"
+	"ired.com/callcenter/app"
+	"ired.com/callcenter/middlewares"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+)
+
+func DispositionRoutes(r *gin.Engine) {
+	dispositions := r.Group("/dispositions")
+	{
+		dispositions.GET("", middlewares.ApiRestAuth(), dispositionCodes)
+		dispositions.POST("", middlewares.ApiRestAuth(), saveDisposition)
+	}
+}
+
+// @Summary 			Get catalogue of disposition codes
+// @Description 	catalogo de codigos de tipificacion con sus subcodigos, los deshabilitados se muestran para los reportes pero no se aceptan
+// @Tags 					Dispositions
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=models.Dispositions}
+// @Router 				/dispositions [get]
+func dispositionCodes(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: repo.GetDispositions(),
+		},
+	)
+}
+
+// @Summary 			Save disposition of a call
+// @Description 	tipificacion de la llamada al terminar, la llamada se indica por uniqueid o por la extension del agente (su ultima llamada del dia). Si la llamada ya estaba tipificada se reemplaza
+// @Tags 					Dispositions
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				disposition body models.DispositionReq true "codigo, subcodigo y nota de la llamada"
+// @Success 			200 {object} models.SuccessResponse{record=models.CallDisposition}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Router 				/dispositions [post]
+func saveDisposition(c *gin.Context) {
+	var dispositionReq models.DispositionReq
+	if !bindJsonBody(c, &dispositionReq) {
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	disposition, err := repo.SaveDisposition(db, dispositionReq, c.GetString("authUser"))
+	if err != nil {
+		if errors.Is(err, repo.ErrCallNotFound) {
+			c.AbortWithStatusJSON(
+				http.StatusNotFound,
+				models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "recordDontExist")},
+			)
+			return
+		}
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorInsertRecord")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "formOK"),
+			Record: disposition,
+		},
+	)
+}
@@ -17,6 +17,11 @@ func GrafanaRoutes(r *gin.Engine) {
 	cron := r.Group("/grafana")
 	{
 		cron.GET("/get-extension-status", middlewares.GrafanaAuth(), extensionStatus)
+		cron.GET("/get-calls-report", middlewares.GrafanaAuth(), callsReport)
+		cron.GET("/get-call-transfers", middlewares.GrafanaAuth(), callTransfers)
+		cron.GET("/get-calls-outcome", middlewares.GrafanaAuth(), callsOutcome)
+		cron.GET("/get-queue-stats", middlewares.GrafanaAuth(), queueStats)
+		cron.GET("/get-dispositions-report", middlewares.GrafanaAuth(), dispositionsReport)
 	}
 }
 
@@ -52,3 +57,233 @@ func extensionStatus(c *gin.Context) {
 		},
 	)
 }
+
+// @Summary 			Get historical report of calls
+// @Description 	historico de llamadas entrantes y salientes en un rango de fechas, con tiempos de espera en cola, duracion, cantidad de esperas, segundos en espera, cantidad de transferencias, agente que resolvio la llamada, resultado y quien colgo
+// @Tags 					Grafana
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				date_from query string true "fecha desde (YYYY-MM-DD)"
+// @Param 				date_to query string true "fecha hasta (YYYY-MM-DD)"
+// @Param 				agent query string false "extension del agente"
+// @Param 				outcome query string false "resultado de la llamada" Enums(answered, busy, no_answer, rejected, congestion, unreachable, abandoned, cancelled, failed, system_closed, unknown)
+// @Success 			200 {object} models.SuccessResponse{record=[]models.CallReport}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/grafana/get-calls-report [get]
+func callsReport(c *gin.Context) {
+	// Bind and Validate the query params
+	var reportReq models.CallsReportReq
+	if err := c.ShouldBindQuery(&reportReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	calls, err := repo.CallsReport(db, reportReq)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: calls,
+		},
+	)
+}
+
+// @Summary 			Get transfer chain of a call
+// @Description 	cadena de transferencias de una llamada, cada pata con su origen, destino y quien la atendio
+// @Tags 					Grafana
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				uniqueid query string true "uniqueid de la llamada"
+// @Param 				pbx query string false "central de la llamada, por defecto la primera"
+// @Success 			200 {object} models.SuccessResponse{record=[]models.CallTransfer}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/grafana/get-call-transfers [get]
+func callTransfers(c *gin.Context) {
+	// Bind and Validate the query params
+	var transfersReq models.CallTransfersReq
+	if err := c.ShouldBindQuery(&transfersReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	transfers, err := repo.CallTransfers(db, transfersReq.Pbx, transfersReq.Uniqueid)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: transfers,
+		},
+	)
+}
+
+// @Summary 			Get calls grouped by outcome
+// @Description 	cantidad de llamadas y segundos hablados agrupados por direccion, resultado (answered, busy, no_answer, ...) y parte que colgo en un rango de fechas
+// @Tags 					Grafana
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				date_from query string true "fecha desde (YYYY-MM-DD)"
+// @Param 				date_to query string true "fecha hasta (YYYY-MM-DD)"
+// @Param 				agent query string false "extension del agente"
+// @Param 				outcome query string false "resultado de la llamada" Enums(answered, busy, no_answer, rejected, congestion, unreachable, abandoned, cancelled, failed, system_closed, unknown)
+// @Success 			200 {object} models.SuccessResponse{record=[]models.CallsOutcome}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/grafana/get-calls-outcome [get]
+func callsOutcome(c *gin.Context) {
+	// Bind and Validate the query params
+	var reportReq models.CallsReportReq
+	if err := c.ShouldBindQuery(&reportReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	outcomes, err := repo.CallsOutcome(db, reportReq)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: outcomes,
+		},
+	)
+}
+
+// @Summary 			Get real-time statistics of queues
+// @Description 	estado en tiempo real de las colas de todas las centrales: llamadas en espera, mayor espera, atendidas, abandonadas, nivel de servicio, promedios de espera y conversacion, miembros con su estado y pausa, y clientes en espera con su posicion
+// @Tags 					Grafana
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				queue query string false "cola, vacio todas"
+// @Success 			200 {object} models.SuccessResponse{record=[]models.QueueStats}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/grafana/get-queue-stats [get]
+func queueStats(c *gin.Context) {
+	// Bind and Validate the query params
+	var statsReq models.QueueStatsReq
+	if err := c.ShouldBindQuery(&statsReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	stats, err := repo.QueueStats(statsReq.Queue)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: stats,
+		},
+	)
+}
+
+// @Summary 			Get calls grouped by disposition
+// @Description 	cantidad de llamadas tipificadas y segundos hablados por dia de la llamada, agente, codigo y subcodigo en un rango de fechas, con las etiquetas del catalogo
+// @Tags 					Grafana
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				date_from query string true "fecha desde (YYYY-MM-DD)"
+// @Param 				date_to query string true "fecha hasta (YYYY-MM-DD)"
+// @Param 				agent query string false "extension del agente"
+// @Param 				code query string false "codigo de tipificacion"
+// @Success 			200 {object} models.SuccessResponse{record=[]models.DispositionsReport}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/grafana/get-dispositions-report [get]
+func dispositionsReport(c *gin.Context) {
+	// Bind and Validate the query params
+	var reportReq models.DispositionsReportReq
+	if err := c.ShouldBindQuery(&reportReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	report, err := repo.DispositionsReport(db, reportReq)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: report,
+		},
+	)
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"ired.com/callcenter/middlewares"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+	"ired.com/callcenter/utils"
+)
+
+const (
+	liveBuffer       = 256              // cola de cada cliente en el bus
+	liveHeartbeat    = 15 * time.Second // comentario SSE o ping WebSocket para mantener viva la conexion
+	liveWriteTimeout = 10 * time.Second
+)
+
+// origenes permitidos para el WebSocket, vacio solo el mismo host
+var liveUpgrader = websocket.Upgrader{CheckOrigin: checkLiveOrigin}
+
+func LiveRoutes(r *gin.Engine) {
+	live := r.Group("/live")
+	{
+		live.GET("/events", middlewares.GrafanaAuth(), liveEvents)
+		live.GET("/ws", middlewares.GrafanaAuth(), liveEventsWs)
+	}
+}
+
+// @Summary 			Live call and extension events (Server-Sent Events)
+// @Description 	stream de eventos de llamadas, extensiones y miembros de cola en tiempo real. Cada evento lleva su id, al reconectar se envian los eventos perdidos desde last_event_id o el header Last-Event-ID. Un cliente que no lee a tiempo es desconectado y debe reconectar con su cursor
+// @Tags 					Live
+// @Produce 			text/event-stream
+// @Security 			BasicAuth
+// @Param 				extension query string false "extensiones separadas por coma"
+// @Param 				queue query string false "colas separadas por coma"
+// @Param 				type query string false "tipos de evento separados por coma: call.started, call.answered, call.held, call.resumed, call.transferred, call.ended, extension.state, queue.member_paused, originate.response"
+// @Param 				last_event_id query int false "id del ultimo evento recibido"
+// @Success 			200 {object} models.BusEvent
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/live/events [get]
+func liveEvents(c *gin.Context) {
+	liveReq, filter, ok := bindLiveFilter(c)
+	if !ok {
+		return
+	}
+	if lastId, err := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64); err == nil {
+		liveReq.LastEventId = lastId
+	}
+
+	sub, backlog := subscribeLive(c, "sse", liveReq.LastEventId)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	send := func(ev models.BusEvent) bool {
+		data, _ := json.Marshal(ev)
+		_, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", ev.Id, ev.Type, data)
+		return err == nil
+	}
+
+	lastSent := liveReq.LastEventId
+	for _, ev := range backlog {
+		if filter.Match(ev) && !send(ev) {
+			return
+		}
+		lastSent = ev.Id
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if ev.Id <= lastSent || !filter.Match(ev) {
+				continue
+			}
+			if !send(ev) {
+				return
+			}
+			lastSent = ev.Id
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// @Summary 			Live call and extension events (WebSocket)
+// @Description 	mismos eventos y filtros que /live/events, cada mensaje es un evento en json. Para retomar se reconecta con last_event_id igual al id del ultimo evento recibido
+// @Tags 					Live
+// @Security 			BasicAuth
+// @Param 				extension query string false "extensiones separadas por coma"
+// @Param 				queue query string false "colas separadas por coma"
+// @Param 				type query string false "tipos de evento separados por coma"
+// @Param 				last_event_id query int false "id del ultimo evento recibido"
+// @Success 			101 {object} models.BusEvent
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/live/ws [get]
+func liveEventsWs(c *gin.Context) {
+	liveReq, filter, ok := bindLiveFilter(c)
+	if !ok {
+		return
+	}
+
+	conn, err := liveUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		utils.Logline("failed to upgrade live websocket", c.ClientIP(), err)
+		return
+	}
+	defer conn.Close()
+
+	sub, backlog := subscribeLive(c, "ws", liveReq.LastEventId)
+	defer sub.Close()
+
+	// el cliente no envia datos, se lee solo para procesar pong y close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(2 * liveHeartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * liveHeartbeat))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(ev models.BusEvent) bool {
+		conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		return conn.WriteJSON(ev) == nil
+	}
+
+	lastSent := liveReq.LastEventId
+	for _, ev := range backlog {
+		if filter.Match(ev) && !send(ev) {
+			return
+		}
+		lastSent = ev.Id
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(time.Second))
+				return
+			}
+			if ev.Id <= lastSent || !filter.Match(ev) {
+				continue
+			}
+			if !send(ev) {
+				return
+			}
+			lastSent = ev.Id
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// bindLiveFilter valida los filtros, si no son validos responde 400
+func bindLiveFilter(c *gin.Context) (models.LiveEventsReq, models.LiveFilter, bool) {
+	var liveReq models.LiveEventsReq
+	if err := c.ShouldBindQuery(&liveReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return liveReq, models.LiveFilter{}, false
+	}
+
+	filter, err := liveReq.Filter()
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return liveReq, filter, false
+	}
+	return liveReq, filter, true
+}
+
+// subscribeLive suscribe al cliente antes de leer el historial, los eventos repetidos
+// entre ambos se descartan por id
+func subscribeLive(c *gin.Context, kind string, lastEventId uint64) (*repo.BusSubscription, []models.BusEvent) {
+	name := fmt.Sprintf("live-%s %s@%s", kind, c.GetString("authUser"), c.ClientIP())
+	sub := repo.SubscribeEvents(name, liveBuffer, models.BusDisconnect)
+	return sub, repo.EventsSince(lastEventId)
+}
+
+func checkLiveOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	allowed := strings.Split(os.Getenv("LIVE_ALLOWED_ORIGINS"), ",")
+	if slices.Contains(allowed, origin) {
+		return true
+	}
+	// mismo host que el servicio
+	return strings.TrimPrefix(strings.TrimPrefix(origin, "https://"), "http://") == r.Host
+}
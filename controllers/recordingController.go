@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	ginI18n "github.com/gin-contrib/i18n"
+	"github.com/gin-gonic/gin"
+	"ired.com/callcenter/app"
+	"ired.com/callcenter/middlewares"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+)
+
+func RecordingRoutes(r *gin.Engine) {
+	recordings := r.Group("/recordings")
+	{
+		recordings.GET("/:id", middlewares.ApiRestAuth(), callRecording)
+	}
+}
+
+// @Summary 			Get recording of a call
+// @Description 	reproduce o descarga la grabacion de la llamada por su id en calls, soporta el header Range para adelantar el audio. Cada acceso queda registrado en recording_access_log
+// @Tags 					Recordings
+// @Produce 			octet-stream
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la llamada en calls"
+// @Param 				download query bool false "descargar el archivo en lugar de reproducirlo"
+// @Success 			200 {file} file
+// @Success 			206 {file} file
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Router 				/recordings/{id} [get]
+func callRecording(c *gin.Context) {
+	// Bind and Validate the params
+	var recordingReq models.RecordingReq
+	if err := c.ShouldBindUri(&recordingReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+	if err := c.ShouldBindQuery(&recordingReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	access := models.RecordingAccess{
+		IdCall:   recordingReq.Id,
+		Username: c.GetString("authUser"),
+		ClientIp: c.ClientIP(),
+		Range:    c.GetHeader("Range"),
+	}
+
+	recording, err := repo.GetCallRecording(db, recordingReq.Id)
+	if errors.Is(err, repo.ErrRecordingNotFound) {
+		access.Result = "not_found"
+		repo.LogRecordingAccess(db, access)
+		c.AbortWithStatusJSON(
+			http.StatusNotFound,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "recordDontExist")},
+		)
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	// open the file inside the recordings folder
+	var file *os.File
+	path, err := repo.RecordingPath(recording.File)
+	if err == nil {
+		file, err = os.Open(path)
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = file.Stat()
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		access.Result = "file_missing"
+		repo.LogRecordingAccess(db, access)
+		c.AbortWithStatusJSON(
+			http.StatusNotFound,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "recordDontExist")},
+		)
+		return
+	}
+	defer file.Close()
+
+	access.Result = "served"
+	repo.LogRecordingAccess(db, access)
+
+	disposition := "inline"
+	if recordingReq.Download {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filepath.Base(path)))
+
+	// ServeContent maneja Range, If-Range y responde 206 con el fragmento pedido
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), file)
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	ginI18n "github.com/gin-contrib/i18n"
+	"github.com/gin-gonic/gin"
+	"ired.com/callcenter/app"
+	"ired.com/callcenter/middlewares"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/repo"
+)
+
+func WebhookRoutes(r *gin.Engine) {
+	webhooks := r.Group("/admin/webhooks")
+	{
+		webhooks.GET("", middlewares.BasicAuth(), webhooksList)
+		webhooks.POST("/reload", middlewares.BasicAuth(), reloadWebhooks)
+		webhooks.GET("/dead-letters", middlewares.BasicAuth(), webhookDeadLetters)
+		webhooks.GET("/deliveries/:id", middlewares.BasicAuth(), webhookDelivery)
+		webhooks.POST("/deliveries/:id/redeliver", middlewares.BasicAuth(), redeliverWebhook)
+	}
+}
+
+// @Summary 			Get webhook subscriptions
+// @Description 	webhooks activos con sus filtros de tipo de evento, extension y cola, el secreto no se muestra
+// @Tags 					Webhooks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=[]models.Webhook}
+// @Router 				/admin/webhooks [get]
+func webhooksList(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: repo.GetWebhooks(),
+		},
+	)
+}
+
+// @Summary 			Reload webhook subscriptions
+// @Description 	vuelve a leer el archivo .webhooks, si es invalido se retorna el error de validacion y se mantienen los webhooks activos
+// @Tags 					Webhooks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Success 			200 {object} models.SuccessResponse{record=[]models.Webhook}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/webhooks/reload [post]
+func reloadWebhooks(c *gin.Context) {
+	webhooks, err := repo.LoadWebhooks(app.WebhooksFile)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: webhooks,
+		},
+	)
+}
+
+// @Summary 			Get dead-letter webhook deliveries
+// @Description 	entregas que agotaron los reintentos, las mas recientes primero. Se pueden reenviar con /admin/webhooks/deliveries/{id}/redeliver
+// @Tags 					Webhooks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				webhook query string false "nombre del webhook"
+// @Param 				type query string false "tipo de evento, ej: call.ended"
+// @Param 				limit query int false "cantidad maxima, por defecto 100, maximo 1000"
+// @Success 			200 {object} models.SuccessResponse{record=[]models.WebhookDelivery}
+// @Failure 			400 {object} models.ErrorResponse
+// @Router 				/admin/webhooks/dead-letters [get]
+func webhookDeadLetters(c *gin.Context) {
+	// Bind and Validate the query params
+	var deliveriesReq models.WebhookDeliveriesReq
+	if err := c.ShouldBindQuery(&deliveriesReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	deliveries, err := repo.WebhookDeliveries(db, models.WebhookDead, deliveriesReq)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: deliveries,
+		},
+	)
+}
+
+// @Summary 			Get a webhook delivery with its attempts
+// @Description 	entrega con el payload enviado y cada intento con el codigo de respuesta o el error
+// @Tags 					Webhooks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la entrega"
+// @Success 			200 {object} models.SuccessResponse{record=models.WebhookDelivery}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Router 				/admin/webhooks/deliveries/{id} [get]
+func webhookDelivery(c *gin.Context) {
+	var idReq models.WebhookDeliveryIdReq
+	if err := c.ShouldBindUri(&idReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	//set variables for handling mysql conn
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db := models.ConnMysql{Conn: app.PoolMysql, Ctx: ctx}
+
+	delivery, err := repo.GetWebhookDelivery(db, idReq.Id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: delivery,
+		},
+	)
+}
+
+// @Summary 			Redeliver a webhook delivery
+// @Description 	reenvia la entrega ahora con una firma nueva y retorna el resultado del intento, si falla solo se vuelve a reintentar si no agoto WEBHOOK_MAX_ATTEMPTS
+// @Tags 					Webhooks
+// @Accept 				json
+// @Produce 			json
+// @Security 			BasicAuth
+// @Param 				id path int true "id de la entrega"
+// @Success 			200 {object} models.SuccessResponse{record=models.WebhookDelivery}
+// @Failure 			400 {object} models.ErrorResponse
+// @Failure 			404 {object} models.ErrorResponse
+// @Failure 			409 {object} models.ErrorResponse
+// @Router 				/admin/webhooks/deliveries/{id}/redeliver [post]
+func redeliverWebhook(c *gin.Context) {
+	var idReq models.WebhookDeliveryIdReq
+	if err := c.ShouldBindUri(&idReq); err != nil {
+		errorFormJson := models.ParseError(err, c)
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: errorFormJson},
+		)
+		return
+	}
+
+	delivery, err := repo.RedeliverWebhook(idReq.Id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		models.SuccessResponse{
+			Notice: ginI18n.MustGetMessage(c, "queryOK"),
+			Record: delivery,
+		},
+	)
+}
+
+func webhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repo.ErrWebhookDeliveryNotFound):
+		c.AbortWithStatusJSON(
+			http.StatusNotFound,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "recordDontExist")},
+		)
+	case errors.Is(err, repo.ErrWebhookDeliveryInProgress):
+		c.AbortWithStatusJSON(
+			http.StatusConflict,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "webhookDeliveryInProgress")},
+		)
+	default:
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			models.ErrorResponse{Error: ginI18n.MustGetMessage(c, "errorGetData")},
+		)
+	}
+}
@@ -19,14 +19,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/ami/hangup-call": {
-            "post": {
+        "/admin/ami-events": {
+            "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "recibe un numero de extension y cuelga la llamada que tenga la extension abierta",
+                "description": "busca en el journal de eventos AMI la secuencia completa de eventos de una llamada por su Linkedid, incluye los eventos de transferencia donde aparece el Linkedid",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,25 +34,90 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Ami"
+                    "Admin"
                 ],
-                "summary": "Colgar llamada de una extension",
+                "summary": "Get raw AMI events of a call",
                 "parameters": [
                     {
-                        "description": "Extension Data",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "Linkedid de la llamada",
+                        "name": "linkedid",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "dias hacia atras a revisar, por defecto 1, maximo 30",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ExtensionReq"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.AmiJournalEntry"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/admin/ami-listener-status": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
                 ],
+                "description": "muestra el estado de la sesion AMI de cada central que escucha eventos: conectado desde, cantidad de reconexiones y ultimo error, ademas de las metricas de los workers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get status of the AMI events listener",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.AmiServiceStatus"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -64,14 +129,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/cron/chat-auto-opened": {
+        "/admin/call-janitor-report": {
             "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "run cron para cambiar estatus a por abrir de aquellos chats que fueron marcados como pendientes por un agente y el ultimo mensaje recibido fue del cliente",
+                "description": "muestra el resultado de la ultima ejecucion del janitor: llamadas que superaron CALL_MAX_AGE, cuantas siguen vivas en asterisk y cuales se cerraron como 'Cerrada por sistema'",
                 "consumes": [
                     "application/json"
                 ],
@@ -79,14 +144,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Crons"
+                    "Admin"
                 ],
-                "summary": "Run the task chat_auto_opened",
+                "summary": "Get report of the last stale calls cleanup",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CallJanitorReport"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -98,14 +175,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/cron/chat-autoresolve": {
+        "/admin/call-recovery-report": {
             "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "run cron to autoResolve chats",
+                "description": "muestra el resultado de la ultima reconciliacion de current_calls contra los canales vivos de cada central, llamadas retomadas y cerradas",
                 "consumes": [
                     "application/json"
                 ],
@@ -113,14 +190,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Crons"
+                    "Admin"
                 ],
-                "summary": "Run the task chat_auto_resolve",
+                "summary": "Get report of the calls recovered on startup",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.CallRecoveryReport"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -132,14 +224,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/grafana/get-extension-status": {
+        "/admin/call-rules": {
             "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "get the extension status of all extension of agents in call center using AMI connection to asterisk",
+                "description": "muestra las reglas activas de rastreo de llamadas: patrones de extensiones, contextos, colas y campañas",
                 "consumes": [
                     "application/json"
                 ],
@@ -147,14 +239,66 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Grafana"
+                    "Admin"
                 ],
-                "summary": "Get Extension Status from PBX",
+                "summary": "Get active call tracking rules",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CallRules"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/call-rules/reload": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "vuelve a leer el archivo .callrules, si es invalido se retorna el error de validacion y se mantienen las reglas activas",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reload call tracking rules",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CallRules"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -165,35 +309,3144 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {}
+        },
+        "/admin/cdr-reconcile": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "concilia calls contra el CDR de asterisk en el rango indicado, sin fechas usa la ventana de la tarea service_cdr_reconcile. Con dry_run solo reporta las diferencias sin corregir calls",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Run the CDR reconciliation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "desde (YYYY-MM-DD HH:MM:SS)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "hasta (YYYY-MM-DD HH:MM:SS)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "solo reportar",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CdrReconcileReport"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
             }
         },
-        "models.ExtensionReq": {
-            "type": "object",
-            "required": [
-                "extension"
-            ],
-            "properties": {
-                "extension": {
-                    "type": "string",
-                    "maxLength": 5,
-                    "minLength": 4
+        "/admin/cdr-reconcile-report": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "muestra el resultado de la ultima conciliacion de calls contra el CDR de asterisk: llamadas faltantes, abiertas, con estado final o duracion distinta y la accion tomada",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get report of the last CDR reconciliation",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CdrReconcileReport"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "models.SuccessResponse": {
-            "type": "object",
-            "properties": {
-                "notice": {
-                    "type": "string"
-                },
-                "record": {}
+        "/admin/dispositions/reload": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "vuelve a leer el archivo .dispositions, si es invalido se retorna el error de validacion y se mantiene el catalogo activo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reload catalogue of disposition codes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.Dispositions"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "webhooks activos con sus filtros de tipo de evento, extension y cola, el secreto no se muestra",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Webhook"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/dead-letters": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "entregas que agotaron los reintentos, las mas recientes primero. Se pueden reenviar con /admin/webhooks/deliveries/{id}/redeliver",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get dead-letter webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "nombre del webhook",
+                        "name": "webhook",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "tipo de evento, ej: call.ended",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "cantidad maxima, por defecto 100, maximo 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.WebhookDelivery"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "entrega con el payload enviado y cada intento con el codigo de respuesta o el error",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get a webhook delivery with its attempts",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la entrega",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.WebhookDelivery"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "reenvia la entrega ahora con una firma nueva y retorna el resultado del intento, si falla solo se vuelve a reintentar si no agoto WEBHOOK_MAX_ATTEMPTS",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Redeliver a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la entrega",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.WebhookDelivery"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/reload": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "vuelve a leer el archivo .webhooks, si es invalido se retorna el error de validacion y se mantienen los webhooks activos",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Reload webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Webhook"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ami/hangup-call": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "recibe un numero de extension y cuelga la llamada que tenga la extension abierta",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ami"
+                ],
+                "summary": "Colgar llamada de una extension",
+                "parameters": [
+                    {
+                        "description": "Extension Data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ExtensionReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ami/originate": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "repica la extension del agente y al contestar marca el numero. El numero debe cumplir ORIGINATE_ALLOWED_PATTERN y no puede estar marcado como dnc ni en call_blacklist. La respuesta llega al encolar el Originate con status queued, el resultado y el uniqueid llegan despues con el evento originate.response del bus (websocket, SSE y webhooks) o consultando /ami/originate/{id}. La llamada queda en calls como saliente",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ami"
+                ],
+                "summary": "Click-to-call desde la extension de un agente",
+                "parameters": [
+                    {
+                        "description": "extension del agente, numero y opciones del Originate",
+                        "name": "originate",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.OriginateReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CallOriginate"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ami/originate/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "estado de la llamada originada: queued hasta que llega el OriginateResponse, luego success con el uniqueid y canal del agente o failure con la razon",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ami"
+                ],
+                "summary": "Get a click-to-call",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la llamada originada",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CallOriginate"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/callbacks": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "lista de rellamadas de las llamadas entrantes no atendidas, una por telefono con la cantidad de abandonos. Sin status retorna las abiertas",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Callbacks"
+                ],
+                "summary": "List callbacks of abandoned calls",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "calling",
+                            "claimed",
+                            "closed"
+                        ],
+                        "type": "string",
+                        "description": "estado de la rellamada",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "cola donde abandono",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "telefono del cliente",
+                        "name": "phone",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Callback"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/callbacks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "rellamada con cada intento originado, cada intento indica la llamada abandonada original y el agente",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Callbacks"
+                ],
+                "summary": "Get a callback with its attempts",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la rellamada",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.Callback"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/callbacks/{id}/claim": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "el agente toma la rellamada pendiente para devolver la llamada",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Callbacks"
+                ],
+                "summary": "Claim a callback",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la rellamada",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "extension del agente",
+                        "name": "claim",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CallbackClaimReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.Callback"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/callbacks/{id}/close": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "cierra la rellamada con su resultado, no se puede cerrar mientras se esta originando",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Callbacks"
+                ],
+                "summary": "Close a callback",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la rellamada",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "resultado de la rellamada",
+                        "name": "close",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CallbackCloseReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.Callback"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/cron/chat-auto-opened": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "run cron para cambiar estatus a por abrir de aquellos chats que fueron marcados como pendientes por un agente y el ultimo mensaje recibido fue del cliente",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Crons"
+                ],
+                "summary": "Run the task chat_auto_opened",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/cron/chat-autoresolve": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "run cron to autoResolve chats",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Crons"
+                ],
+                "summary": "Run the task chat_auto_resolve",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/dispositions": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "catalogo de codigos de tipificacion con sus subcodigos, los deshabilitados se muestran para los reportes pero no se aceptan",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Dispositions"
+                ],
+                "summary": "Get catalogue of disposition codes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.Dispositions"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "tipificacion de la llamada al terminar, la llamada se indica por uniqueid o por la extension del agente (su ultima llamada del dia). Si la llamada ya estaba tipificada se reemplaza",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Dispositions"
+                ],
+                "summary": "Save disposition of a call",
+                "parameters": [
+                    {
+                        "description": "codigo, subcodigo y nota de la llamada",
+                        "name": "disposition",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DispositionReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "$ref": "#/definitions/models.CallDisposition"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/get-call-transfers": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "cadena de transferencias de una llamada, cada pata con su origen, destino y quien la atendio",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Get transfer chain of a call",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "uniqueid de la llamada",
+                        "name": "uniqueid",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "central de la llamada, por defecto la primera",
+                        "name": "pbx",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.CallTransfer"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/get-calls-outcome": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "cantidad de llamadas y segundos hablados agrupados por direccion, resultado (answered, busy, no_answer, ...) y parte que colgo en un rango de fechas",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Get calls grouped by outcome",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "fecha desde (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "fecha hasta (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "extension del agente",
+                        "name": "agent",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "answered",
+                            "busy",
+                            "no_answer",
+                            "rejected",
+                            "congestion",
+                            "unreachable",
+                            "abandoned",
+                            "cancelled",
+                            "failed",
+                            "system_closed",
+                            "unknown"
+                        ],
+                        "type": "string",
+                        "description": "resultado de la llamada",
+                        "name": "outcome",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.CallsOutcome"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/get-calls-report": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "historico de llamadas entrantes y salientes en un rango de fechas, con tiempos de espera en cola, duracion, cantidad de esperas, segundos en espera, cantidad de transferencias, agente que resolvio la llamada, resultado y quien colgo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Get historical report of calls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "fecha desde (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "fecha hasta (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "extension del agente",
+                        "name": "agent",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "answered",
+                            "busy",
+                            "no_answer",
+                            "rejected",
+                            "congestion",
+                            "unreachable",
+                            "abandoned",
+                            "cancelled",
+                            "failed",
+                            "system_closed",
+                            "unknown"
+                        ],
+                        "type": "string",
+                        "description": "resultado de la llamada",
+                        "name": "outcome",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.CallReport"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/get-dispositions-report": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "cantidad de llamadas tipificadas y segundos hablados por dia de la llamada, agente, codigo y subcodigo en un rango de fechas, con las etiquetas del catalogo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Get calls grouped by disposition",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "fecha desde (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "fecha hasta (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "extension del agente",
+                        "name": "agent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "codigo de tipificacion",
+                        "name": "code",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.DispositionsReport"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/get-extension-status": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "get the extension status of all extension of agents in call center using AMI connection to asterisk",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Get Extension Status from PBX",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/get-queue-stats": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "estado en tiempo real de las colas de todas las centrales: llamadas en espera, mayor espera, atendidas, abandonadas, nivel de servicio, promedios de espera y conversacion, miembros con su estado y pausa, y clientes en espera con su posicion",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Get real-time statistics of queues",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "cola, vacio todas",
+                        "name": "queue",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "record": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.QueueStats"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/live/events": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "stream de eventos de llamadas, extensiones y miembros de cola en tiempo real. Cada evento lleva su id, al reconectar se envian los eventos perdidos desde last_event_id o el header Last-Event-ID. Un cliente que no lee a tiempo es desconectado y debe reconectar con su cursor",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Live"
+                ],
+                "summary": "Live call and extension events (Server-Sent Events)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "extensiones separadas por coma",
+                        "name": "extension",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "colas separadas por coma",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "tipos de evento separados por coma: call.started, call.answered, call.held, call.resumed, call.transferred, call.ended, extension.state, queue.member_paused, originate.response",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "id del ultimo evento recibido",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BusEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/live/ws": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "mismos eventos y filtros que /live/events, cada mensaje es un evento en json. Para retomar se reconecta con last_event_id igual al id del ultimo evento recibido",
+                "tags": [
+                    "Live"
+                ],
+                "summary": "Live call and extension events (WebSocket)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "extensiones separadas por coma",
+                        "name": "extension",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "colas separadas por coma",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "tipos de evento separados por coma",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "id del ultimo evento recibido",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/models.BusEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/recordings/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "reproduce o descarga la grabacion de la llamada por su id en calls, soporta el header Range para adelantar el audio. Cada acceso queda registrado en recording_access_log",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Recordings"
+                ],
+                "summary": "Get recording of a call",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id de la llamada en calls",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "descargar el archivo en lugar de reproducirlo",
+                        "name": "download",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.AmiClientStatus": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "integer"
+                },
+                "connected": {
+                    "type": "boolean"
+                },
+                "connected_since": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_error_at": {
+                    "type": "string"
+                },
+                "logins": {
+                    "type": "integer"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "server": {
+                    "type": "string"
+                },
+                "timeouts": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AmiDispatchStats": {
+            "type": "object",
+            "properties": {
+                "avg_handle_ms": {
+                    "description": "duracion de handleEvent",
+                    "type": "number"
+                },
+                "avg_wait_ms": {
+                    "description": "recibido -\u003e inicio de proceso",
+                    "type": "number"
+                },
+                "dropped": {
+                    "type": "integer"
+                },
+                "max_handle_ms": {
+                    "type": "number"
+                },
+                "panics": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "queue_capacity": {
+                    "description": "por worker",
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "queue_total": {
+                    "type": "integer"
+                },
+                "running_since": {
+                    "type": "string"
+                },
+                "workers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AmiJournalEntry": {
+            "type": "object",
+            "properties": {
+                "event": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "received_at": {
+                    "type": "string"
+                },
+                "server": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AmiListenerStatus": {
+            "type": "object",
+            "properties": {
+                "connected": {
+                    "type": "boolean"
+                },
+                "connected_since": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_error_at": {
+                    "type": "string"
+                },
+                "last_event_at": {
+                    "type": "string"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "reconnect_count": {
+                    "type": "integer"
+                },
+                "server": {
+                    "type": "string"
+                },
+                "session_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AmiServiceStatus": {
+            "type": "object",
+            "properties": {
+                "clients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AmiClientStatus"
+                    }
+                },
+                "dispatch": {
+                    "$ref": "#/definitions/models.AmiDispatchStats"
+                },
+                "journal_dropped": {
+                    "type": "integer"
+                },
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AmiListenerStatus"
+                    }
+                },
+                "subscribers": {
+                    "description": "suscriptores del bus de eventos",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BusSubscriberStats"
+                    }
+                },
+                "webhooks": {
+                    "$ref": "#/definitions/models.WebhookStats"
+                }
+            }
+        },
+        "models.BusEvent": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "call": {
+                    "$ref": "#/definitions/models.TrackedCall"
+                },
+                "extension": {
+                    "$ref": "#/definitions/models.ExtensionState"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "member": {
+                    "$ref": "#/definitions/models.QueueMemberPause"
+                },
+                "originate": {
+                    "$ref": "#/definitions/models.CallOriginate"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "transfer": {
+                    "$ref": "#/definitions/models.CallTransfer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BusPolicy": {
+            "type": "string",
+            "enum": [
+                "drop_newest",
+                "drop_oldest",
+                "disconnect"
+            ],
+            "x-enum-comments": {
+                "BusDisconnect": "se cierra la suscripcion",
+                "BusDropNewest": "se descarta el evento nuevo",
+                "BusDropOldest": "se descarta el evento mas viejo de la cola"
+            },
+            "x-enum-varnames": [
+                "BusDropNewest",
+                "BusDropOldest",
+                "BusDisconnect"
+            ]
+        },
+        "models.BusSubscriberStats": {
+            "type": "object",
+            "properties": {
+                "buffer": {
+                    "type": "integer"
+                },
+                "delivered": {
+                    "type": "integer"
+                },
+                "depth": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "policy": {
+                    "$ref": "#/definitions/models.BusPolicy"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "types": {
+                    "description": "vacio recibe todos",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CallDisposition": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "datetime_disposition": {
+                    "type": "string"
+                },
+                "datetime_update": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "id_call": {
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "subcode": {
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CallHangup": {
+            "type": "object",
+            "properties": {
+                "by": {
+                    "type": "string"
+                },
+                "cause": {
+                    "type": "integer"
+                },
+                "cause_txt": {
+                    "type": "string"
+                },
+                "channel": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CallJanitorReport": {
+            "type": "object",
+            "properties": {
+                "checked": {
+                    "type": "integer"
+                },
+                "closed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StaleCall"
+                    }
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "max_age": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "still_alive": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CallOriginate": {
+            "type": "object",
+            "properties": {
+                "account_code": {
+                    "type": "string"
+                },
+                "action_id": {
+                    "type": "string"
+                },
+                "agent": {
+                    "type": "string"
+                },
+                "caller_id": {
+                    "type": "string"
+                },
+                "channel": {
+                    "type": "string"
+                },
+                "datetime_request": {
+                    "type": "string"
+                },
+                "datetime_response": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CallRecoveryReport": {
+            "type": "object",
+            "properties": {
+                "closed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RecoveredCall"
+                    }
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "kept": {
+                    "description": "ya rastreadas en memoria, no se tocan",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RecoveredCall"
+                    }
+                },
+                "live_channels": {
+                    "type": "integer"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "reconnect": {
+                    "description": "false en la primera sesion del proceso",
+                    "type": "boolean"
+                },
+                "resumed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RecoveredCall"
+                    }
+                },
+                "rows_checked": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CallReport": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "type": "string"
+                },
+                "call_date": {
+                    "type": "string"
+                },
+                "direction": {
+                    "type": "string"
+                },
+                "disposition": {
+                    "type": "string"
+                },
+                "disposition_subcode": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "integer"
+                },
+                "duration_wait": {
+                    "type": "integer"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "hangup_by": {
+                    "type": "string"
+                },
+                "hangup_cause": {
+                    "type": "integer"
+                },
+                "hangup_cause_txt": {
+                    "type": "string"
+                },
+                "hold_count": {
+                    "type": "integer"
+                },
+                "hold_seconds": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "resolved_by": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "transfers": {
+                    "type": "integer"
+                },
+                "uniqueid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CallRules": {
+            "type": "object",
+            "properties": {
+                "inbound": {
+                    "$ref": "#/definitions/models.InboundRules"
+                },
+                "outbound": {
+                    "$ref": "#/definitions/models.OutboundRules"
+                }
+            }
+        },
+        "models.CallState": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4,
+                5,
+                6
+            ],
+            "x-enum-varnames": [
+                "CallDialing",
+                "CallRinging",
+                "CallActive",
+                "CallOnHold",
+                "CallTransferred",
+                "CallCompleted",
+                "CallNoAnswer"
+            ]
+        },
+        "models.CallTransfer": {
+            "type": "object",
+            "properties": {
+                "answered_at": {
+                    "type": "string"
+                },
+                "answered_by": {
+                    "type": "string"
+                },
+                "from_agent": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "target": {
+                    "type": "string"
+                },
+                "target_type": {
+                    "description": "agent | queue | external",
+                    "type": "string"
+                },
+                "transferred_at": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "blind | attended",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Callback": {
+            "type": "object",
+            "properties": {
+                "abandon_count": {
+                    "type": "integer"
+                },
+                "attempt_list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CallbackAttempt"
+                    }
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "claimed_by": {
+                    "type": "string"
+                },
+                "closed_by": {
+                    "type": "string"
+                },
+                "datetime_claimed": {
+                    "type": "string"
+                },
+                "datetime_closed": {
+                    "type": "string"
+                },
+                "datetime_first_abandon": {
+                    "type": "string"
+                },
+                "datetime_last_abandon": {
+                    "type": "string"
+                },
+                "datetime_last_attempt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "id_call": {
+                    "description": "primera llamada abandonada",
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "description": "ultima llamada abandonada",
+                    "type": "string"
+                }
+            }
+        },
+        "models.CallbackAttempt": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "type": "string"
+                },
+                "datetime_attempt": {
+                    "type": "string"
+                },
+                "datetime_result": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "id_call": {
+                    "description": "llamada abandonada original",
+                    "type": "integer"
+                },
+                "id_callback": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "result": {
+                    "description": "calling | answered | failed",
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "description": "ChannelId del Originate",
+                    "type": "string"
+                }
+            }
+        },
+        "models.CallbackClaimReq": {
+            "type": "object",
+            "required": [
+                "agent"
+            ],
+            "properties": {
+                "agent": {
+                    "type": "string",
+                    "maxLength": 5,
+                    "minLength": 4
+                }
+            }
+        },
+        "models.CallbackCloseReq": {
+            "type": "object",
+            "required": [
+                "result"
+            ],
+            "properties": {
+                "notes": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "result": {
+                    "type": "string",
+                    "enum": [
+                        "contacted",
+                        "unreachable",
+                        "wrong_number",
+                        "duplicate",
+                        "other"
+                    ]
+                }
+            }
+        },
+        "models.CallsOutcome": {
+            "type": "object",
+            "properties": {
+                "direction": {
+                    "type": "string"
+                },
+                "hangup_by": {
+                    "type": "string"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "seconds": {
+                    "description": "suma de duration",
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CdrIssue": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "calls_value": {
+                    "type": "string"
+                },
+                "cdr_value": {
+                    "type": "string"
+                },
+                "issue": {
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CdrReconcileReport": {
+            "type": "object",
+            "properties": {
+                "cdr_calls": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "duration_fixed": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CdrIssue"
+                    }
+                },
+                "matched": {
+                    "type": "integer"
+                },
+                "missing": {
+                    "type": "integer"
+                },
+                "open_fixed": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status_fixed": {
+                    "type": "integer"
+                },
+                "untracked": {
+                    "description": "llamadas del CDR que no cumplen las reglas de rastreo",
+                    "type": "integer"
+                },
+                "window_from": {
+                    "type": "string"
+                },
+                "window_to": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DispositionCode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "type": "boolean"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "note_required": {
+                    "description": "el agente debe escribir una nota, ej: otro",
+                    "type": "boolean"
+                },
+                "subcodes": {
+                    "description": "si tiene subcodigos es obligatorio elegir uno",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DispositionSubcode"
+                    }
+                }
+            }
+        },
+        "models.DispositionReq": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "maxLength": 32
+                },
+                "extension": {
+                    "type": "string",
+                    "maxLength": 5,
+                    "minLength": 4
+                },
+                "notes": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "pbx": {
+                    "type": "string",
+                    "maxLength": 40
+                },
+                "subcode": {
+                    "type": "string",
+                    "maxLength": 32
+                },
+                "uniqueid": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "models.DispositionSubcode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "type": "boolean"
+                },
+                "label": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Dispositions": {
+            "type": "object",
+            "properties": {
+                "codes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DispositionCode"
+                    }
+                }
+            }
+        },
+        "models.DispositionsReport": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "day": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "seconds": {
+                    "description": "suma de duration de las llamadas",
+                    "type": "integer"
+                },
+                "subcode": {
+                    "type": "string"
+                },
+                "subcode_label": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {}
+            }
+        },
+        "models.ExtensionReq": {
+            "type": "object",
+            "required": [
+                "extension"
+            ],
+            "properties": {
+                "extension": {
+                    "type": "string",
+                    "maxLength": 5,
+                    "minLength": 4
+                }
+            }
+        },
+        "models.ExtensionState": {
+            "type": "object",
+            "properties": {
+                "device_state": {
+                    "type": "string"
+                },
+                "exten": {
+                    "type": "string"
+                },
+                "hint": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "status_text": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.InboundRules": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "queues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.QueueRule"
+                    }
+                }
+            }
+        },
+        "models.OriginateReq": {
+            "type": "object",
+            "required": [
+                "extension",
+                "phone"
+            ],
+            "properties": {
+                "account_code": {
+                    "type": "string",
+                    "maxLength": 80
+                },
+                "caller_id": {
+                    "type": "string",
+                    "maxLength": 80
+                },
+                "extension": {
+                    "type": "string",
+                    "maxLength": 5,
+                    "minLength": 4
+                },
+                "phone": {
+                    "type": "string",
+                    "maxLength": 15,
+                    "minLength": 7
+                },
+                "timeout": {
+                    "description": "segundos de repique del agente",
+                    "type": "integer",
+                    "maximum": 120,
+                    "minimum": 5
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.OutboundRules": {
+            "type": "object",
+            "properties": {
+                "agent_patterns": {
+                    "description": "regex sobre CallerIDNum del canal que origina",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "campaign_id": {
+                    "description": "valor de calls.id_campaign",
+                    "type": "integer"
+                },
+                "contexts": {
+                    "description": "si no esta vacio solo se rastrean estos contextos",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "dialed_number_context": {
+                    "description": "contexto donde Exten es el numero marcado -\u003e calls.trunk",
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "excluded_contexts": {
+                    "description": "contextos ignorados en el hangup",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "queue": {
+                    "description": "valor de current_calls.queue",
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueueCaller": {
+            "type": "object",
+            "properties": {
+                "calleridname": {
+                    "type": "string"
+                },
+                "calleridnum": {
+                    "type": "string"
+                },
+                "channel": {
+                    "type": "string"
+                },
+                "position": {
+                    "type": "integer"
+                },
+                "uniqueid": {
+                    "type": "string"
+                },
+                "wait_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.QueueMemberPause": {
+            "type": "object",
+            "properties": {
+                "exten": {
+                    "type": "string"
+                },
+                "interface": {
+                    "type": "string"
+                },
+                "paused": {
+                    "type": "boolean"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueueMemberStats": {
+            "type": "object",
+            "properties": {
+                "calls_taken": {
+                    "type": "integer"
+                },
+                "extension": {
+                    "type": "string"
+                },
+                "in_call": {
+                    "type": "boolean"
+                },
+                "interface": {
+                    "type": "string"
+                },
+                "last_call": {
+                    "type": "string"
+                },
+                "membership": {
+                    "description": "static | dynamic | realtime",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "paused": {
+                    "type": "boolean"
+                },
+                "paused_reason": {
+                    "type": "string"
+                },
+                "penalty": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueueRule": {
+            "type": "object",
+            "properties": {
+                "campaign_id": {
+                    "description": "valor de calls.id_campaign",
+                    "type": "integer"
+                },
+                "queue": {
+                    "description": "numero de la cola en asterisk -\u003e current_calls.queue",
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueueStats": {
+            "type": "object",
+            "properties": {
+                "abandoned": {
+                    "type": "integer"
+                },
+                "callers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.QueueCaller"
+                    }
+                },
+                "calls_waiting": {
+                    "type": "integer"
+                },
+                "completed": {
+                    "type": "integer"
+                },
+                "holdtime_avg": {
+                    "description": "segundos promedio de espera",
+                    "type": "integer"
+                },
+                "longest_wait_seconds": {
+                    "type": "integer"
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.QueueMemberStats"
+                    }
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "service_level": {
+                    "description": "segundos del objetivo de servicio",
+                    "type": "integer"
+                },
+                "service_level_perf": {
+                    "description": "% atendidas dentro del objetivo",
+                    "type": "number"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "talktime_avg": {
+                    "description": "segundos promedio de conversacion",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.RecoveredCall": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "type": "string"
+                },
+                "direction": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "hold": {
+                    "type": "string"
+                },
+                "hold_count": {
+                    "type": "integer"
+                },
+                "hold_seconds": {
+                    "type": "integer"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.StaleCall": {
+            "type": "object",
+            "properties": {
+                "age_seconds": {
+                    "type": "integer"
+                },
+                "agent": {
+                    "type": "string"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "memory | current_calls | both",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "estado en memoria o event de current_calls",
+                    "type": "string"
+                },
+                "uniqueid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SuccessResponse": {
+            "type": "object",
+            "properties": {
+                "notice": {
+                    "type": "string"
+                },
+                "record": {}
+            }
+        },
+        "models.TrackedCall": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "type": "string"
+                },
+                "answered_at": {
+                    "type": "string"
+                },
+                "direction": {
+                    "type": "string"
+                },
+                "ended_at": {
+                    "type": "string"
+                },
+                "hangup": {
+                    "$ref": "#/definitions/models.CallHangup"
+                },
+                "hold_count": {
+                    "type": "integer"
+                },
+                "hold_seconds": {
+                    "type": "integer"
+                },
+                "hold_started": {
+                    "type": "string"
+                },
+                "linkedid": {
+                    "type": "string"
+                },
+                "originated": {
+                    "description": "click-to-call, el canal propio es del agente aunque lleve el caller id del cliente",
+                    "type": "boolean"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "pbx": {
+                    "type": "string"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "ring_no_answer": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.CallState"
+                },
+                "transfers": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "disabled": {
+                    "type": "boolean"
+                },
+                "extensions": {
+                    "description": "extensiones de agente, vacio todas",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "queues": {
+                    "description": "colas, vacio todas",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "description": "llave del HMAC de la firma",
+                    "type": "string"
+                },
+                "types": {
+                    "description": "tipos de evento, vacio todos",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempt_list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WebhookDeliveryAttempt"
+                    }
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "datetime_created": {
+                    "type": "string"
+                },
+                "datetime_delivered": {
+                    "type": "string"
+                },
+                "datetime_next_attempt": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "integer"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "webhook": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookDeliveryAttempt": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "datetime_attempt": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "manual": {
+                    "description": "reenvio desde el endpoint de administracion",
+                    "type": "boolean"
+                },
+                "status_code": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.WebhookStats": {
+            "type": "object",
+            "properties": {
+                "dead": {
+                    "type": "integer"
+                },
+                "delivered": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "description": "eventos descartados por el bus con la cola llena",
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "lost": {
+                    "description": "entregas que no se pudieron guardar en mysql",
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "integer"
+                },
+                "running_since": {
+                    "type": "string"
+                },
+                "sent": {
+                    "type": "integer"
+                },
+                "webhooks": {
+                    "type": "integer"
+                },
+                "workers": {
+                    "type": "integer"
+                }
             }
         }
     },
@@ -17,10 +17,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func init() {
+// startService inicializa conexiones, crons y el listener AMI del servicio http
+func startService() {
 	app.LoadEnvVariables()
 	app.InitDbPgsql()
 	app.InitDbMysql()
+	app.InitDbCdr()
+	app.LoadPbxConfig()
 	app.LoadCrontab()
 
 	gin.SetMode(os.Getenv("GIN_MODE"))
@@ -40,6 +43,12 @@ func init() {
 			logfile.Rotate()
 		}
 	}()
+
+	// keep a single AMI session open to track calls, it reconnects by itself
+	app.LoadCallRules()
+	app.LoadDispositions()
+	app.LoadWebhooks()
+	app.StartAmiListener()
 }
 
 // @Title								CallCenter Service API
@@ -53,6 +62,17 @@ func init() {
 // @securityDefinitions.basic.description Basic Authentication
 // @BasePath /
 func main() {
+	// modo cli, reproduce eventos AMI grabados sin levantar el servicio
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(app.Replay(os.Args[2:]))
+	}
+
+	// modo cli, receptor local de webhooks para probar las entregas
+	if len(os.Args) > 1 && os.Args[1] == "webhook-sink" {
+		os.Exit(app.WebhookSink(os.Args[2:]))
+	}
+
+	startService()
 	r := gin.Default()
 
 	// aply startTimer middleware
@@ -85,6 +105,12 @@ func main() {
 	controllers.CronRoutes(r)
 	controllers.GrafanaRoutes(r)
 	controllers.AmiRoutes(r)
+	controllers.AdminRoutes(r)
+	controllers.RecordingRoutes(r)
+	controllers.LiveRoutes(r)
+	controllers.CallbackRoutes(r)
+	controllers.DispositionRoutes(r)
+	controllers.WebhookRoutes(r)
 
 	// load docs
 	controllers.SwaggerRoutes(r)
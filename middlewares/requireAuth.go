@@ -36,6 +36,8 @@ func BasicAuth() gin.HandlerFunc {
 			return
 		}
 
+		// usuario autenticado, se usa en los registros de auditoria
+		c.Set("authUser", credentials[0])
 		c.Next()
 	}
 }
@@ -67,6 +69,8 @@ func GrafanaAuth() gin.HandlerFunc {
 			return
 		}
 
+		// usuario autenticado, se usa en los registros de auditoria
+		c.Set("authUser", credentials[0])
 		c.Next()
 	}
 }
@@ -98,6 +102,8 @@ func ApiRestAuth() gin.HandlerFunc {
 			return
 		}
 
+		// usuario autenticado, se usa en los registros de auditoria
+		c.Set("authUser", credentials[0])
 		c.Next()
 	}
 }
@@ -0,0 +1,7 @@
+-- llamadas entrantes de la cola rastreadas en la misma tabla calls de issabel
+-- direction distingue salientes (extensiones 80*) de entrantes (cola 8000)
+ALTER TABLE call_center.calls
+  ADD COLUMN direction ENUM('outbound', 'inbound') NOT NULL DEFAULT 'outbound',
+  ADD COLUMN ring_no_answer INT UNSIGNED NOT NULL DEFAULT 0,
+  ADD COLUMN abandon_position INT UNSIGNED NULL,
+  ADD INDEX idx_calls_direction (direction, fecha_llamada);
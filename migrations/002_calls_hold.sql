@@ -0,0 +1,4 @@
+-- cantidad de esperas y segundos totales en espera por llamada
+ALTER TABLE call_center.calls
+  ADD COLUMN hold_count INT UNSIGNED NOT NULL DEFAULT 0,
+  ADD COLUMN hold_seconds INT UNSIGNED NOT NULL DEFAULT 0;
@@ -0,0 +1,20 @@
+-- cada pata de transferencia de una llamada (BlindTransfer / AttendedTransfer)
+CREATE TABLE IF NOT EXISTS call_center.call_transfers (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  id_call INT UNSIGNED NOT NULL,
+  uniqueid VARCHAR(32) NOT NULL,
+  transfer_type ENUM('blind', 'attended') NOT NULL,
+  from_agent VARCHAR(40) NOT NULL DEFAULT '',
+  target VARCHAR(80) NOT NULL DEFAULT '',
+  target_type ENUM('agent', 'queue', 'external') NOT NULL,
+  answered_by VARCHAR(40) NULL,
+  datetime_transfer DATETIME NOT NULL,
+  datetime_answer DATETIME NULL,
+  PRIMARY KEY (id),
+  KEY idx_call_transfers_call (id_call),
+  KEY idx_call_transfers_uniqueid (uniqueid)
+);
+
+-- agente que finalmente atendio la llamada despues de las transferencias
+ALTER TABLE call_center.calls
+  ADD COLUMN resolved_by VARCHAR(40) NULL;
@@ -0,0 +1,16 @@
+-- archivo de MixMonitor de la llamada
+ALTER TABLE call_center.calls
+  ADD COLUMN recording_file VARCHAR(255) NULL;
+
+-- auditoria de accesos a las grabaciones
+CREATE TABLE IF NOT EXISTS call_center.recording_access_log (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  id_call INT UNSIGNED NOT NULL,
+  username VARCHAR(80) NOT NULL DEFAULT '',
+  client_ip VARCHAR(45) NOT NULL DEFAULT '',
+  range_header VARCHAR(100) NOT NULL DEFAULT '',
+  result ENUM('served', 'not_found', 'file_missing') NOT NULL,
+  datetime_access DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_recording_access_call (id_call)
+);
@@ -0,0 +1,12 @@
+-- llamadas huerfanas cerradas por el janitor (task service_call_janitor)
+CREATE TABLE IF NOT EXISTS call_center.call_janitor_log (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  uniqueid VARCHAR(32) NOT NULL,
+  source ENUM('memory', 'current_calls', 'both') NOT NULL,
+  agent VARCHAR(40) NOT NULL DEFAULT '',
+  state VARCHAR(20) NOT NULL DEFAULT '',
+  age_seconds INT UNSIGNED NOT NULL DEFAULT 0,
+  datetime_cleanup DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_call_janitor_cleanup (datetime_cleanup)
+);
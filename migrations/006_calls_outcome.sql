@@ -0,0 +1,8 @@
+-- causa del Hangup, quien colgo y resultado normalizado de la llamada
+ALTER TABLE call_center.calls
+  ADD COLUMN hangup_cause SMALLINT UNSIGNED NULL,
+  ADD COLUMN hangup_cause_txt VARCHAR(64) NULL,
+  ADD COLUMN hangup_channel VARCHAR(80) NULL,
+  ADD COLUMN hangup_by ENUM('agent', 'customer', 'system') NULL,
+  ADD COLUMN outcome ENUM('answered', 'busy', 'no_answer', 'rejected', 'congestion', 'unreachable', 'abandoned', 'cancelled', 'failed', 'system_closed') NULL,
+  ADD INDEX idx_calls_outcome (outcome);
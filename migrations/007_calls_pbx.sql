@@ -0,0 +1,13 @@
+-- central asterisk donde ocurrio la llamada. Las llamadas anteriores quedan en la central
+-- por defecto: cambiar @default_pbx por el nombre de la primera central de .pbx ('default'
+-- cuando se usan las variables AMI_* del .env)
+SET @default_pbx = 'default';
+
+ALTER TABLE call_center.calls
+  ADD COLUMN pbx VARCHAR(40) NULL;
+
+UPDATE call_center.calls SET pbx = @default_pbx WHERE pbx IS NULL;
+
+ALTER TABLE call_center.calls
+  MODIFY COLUMN pbx VARCHAR(40) NOT NULL,
+  ADD INDEX idx_calls_pbx (pbx);
@@ -0,0 +1,40 @@
+-- lista de rellamadas de las llamadas entrantes abandonadas, una pendiente por telefono
+CREATE TABLE IF NOT EXISTS call_center.call_callbacks (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  phone VARCHAR(50) NOT NULL,
+  id_call INT UNSIGNED NOT NULL,
+  uniqueid VARCHAR(32) NOT NULL,
+  queue VARCHAR(40) NOT NULL DEFAULT '',
+  pbx VARCHAR(40) NOT NULL DEFAULT '',
+  abandon_count INT UNSIGNED NOT NULL DEFAULT 1,
+  status ENUM('pending', 'calling', 'claimed', 'closed') NOT NULL DEFAULT 'pending',
+  claimed_by VARCHAR(40) NULL,
+  attempts INT UNSIGNED NOT NULL DEFAULT 0,
+  result VARCHAR(20) NULL,
+  notes VARCHAR(255) NULL,
+  closed_by VARCHAR(40) NULL,
+  datetime_first_abandon DATETIME NOT NULL,
+  datetime_last_abandon DATETIME NOT NULL,
+  datetime_claimed DATETIME NULL,
+  datetime_last_attempt DATETIME NULL,
+  datetime_closed DATETIME NULL,
+  PRIMARY KEY (id),
+  KEY idx_call_callbacks_status (status, datetime_first_abandon),
+  KEY idx_call_callbacks_phone (phone, status)
+);
+
+-- cada intento de rellamada, id_call es la llamada abandonada original
+CREATE TABLE IF NOT EXISTS call_center.call_callback_attempts (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  id_callback INT UNSIGNED NOT NULL,
+  id_call INT UNSIGNED NOT NULL,
+  agent VARCHAR(40) NOT NULL,
+  uniqueid VARCHAR(64) NOT NULL,
+  result ENUM('calling', 'answered', 'failed') NOT NULL DEFAULT 'calling',
+  reason VARCHAR(255) NULL,
+  datetime_attempt DATETIME NOT NULL,
+  datetime_result DATETIME NULL,
+  PRIMARY KEY (id),
+  KEY idx_callback_attempts_callback (id_callback),
+  KEY idx_callback_attempts_call (id_call)
+);
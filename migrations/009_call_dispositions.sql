@@ -0,0 +1,17 @@
+-- tipificacion de cada llamada enviada por el agente, el catalogo de codigos esta en .dispositions
+CREATE TABLE IF NOT EXISTS call_center.call_dispositions (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  id_call INT UNSIGNED NOT NULL,
+  uniqueid VARCHAR(64) NOT NULL,
+  agent VARCHAR(40) NOT NULL,
+  code VARCHAR(32) NOT NULL,
+  subcode VARCHAR(32) NULL,
+  notes VARCHAR(500) NULL,
+  created_by VARCHAR(40) NOT NULL,
+  datetime_disposition DATETIME NOT NULL,
+  datetime_update DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_call_dispositions_call (id_call),
+  KEY idx_call_dispositions_code (code, subcode),
+  KEY idx_call_dispositions_agent (agent)
+);
@@ -0,0 +1,34 @@
+-- entregas de los eventos del bus a los webhooks declarados en .webhooks
+CREATE TABLE IF NOT EXISTS call_center.webhook_deliveries (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  webhook VARCHAR(60) NOT NULL,
+  url VARCHAR(500) NOT NULL,
+  event_type VARCHAR(40) NOT NULL,
+  event_id BIGINT UNSIGNED NOT NULL,
+  payload MEDIUMTEXT NOT NULL,
+  status ENUM('pending', 'sending', 'retrying', 'delivered', 'dead') NOT NULL DEFAULT 'pending',
+  attempts INT UNSIGNED NOT NULL DEFAULT 0,
+  last_status_code SMALLINT UNSIGNED NULL,
+  last_error VARCHAR(255) NULL,
+  datetime_created DATETIME NOT NULL,
+  datetime_next_attempt DATETIME NULL,
+  datetime_sending DATETIME NULL,
+  datetime_delivered DATETIME NULL,
+  PRIMARY KEY (id),
+  KEY idx_webhook_deliveries_due (status, datetime_next_attempt),
+  KEY idx_webhook_deliveries_webhook (webhook, status)
+);
+
+-- cada intento de entrega con la respuesta del receptor
+CREATE TABLE IF NOT EXISTS call_center.webhook_delivery_attempts (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  id_delivery INT UNSIGNED NOT NULL,
+  attempt INT UNSIGNED NOT NULL,
+  status_code SMALLINT UNSIGNED NULL,
+  error VARCHAR(255) NULL,
+  duration_ms INT UNSIGNED NOT NULL DEFAULT 0,
+  manual TINYINT(1) NOT NULL DEFAULT 0,
+  datetime_attempt DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_webhook_attempts_delivery (id_delivery)
+);
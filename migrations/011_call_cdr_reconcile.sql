@@ -0,0 +1,33 @@
+-- resumen de cada conciliacion de calls contra el CDR de asterisk
+CREATE TABLE IF NOT EXISTS call_center.call_cdr_reconcile_runs (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  datetime_start DATETIME NOT NULL,
+  datetime_end DATETIME NOT NULL,
+  dry_run TINYINT(1) NOT NULL DEFAULT 0,
+  window_from DATETIME NULL,
+  window_to DATETIME NULL,
+  cdr_calls INT UNSIGNED NOT NULL DEFAULT 0,
+  matched INT UNSIGNED NOT NULL DEFAULT 0,
+  untracked INT UNSIGNED NOT NULL DEFAULT 0,
+  missing INT UNSIGNED NOT NULL DEFAULT 0,
+  open_fixed INT UNSIGNED NOT NULL DEFAULT 0,
+  status_fixed INT UNSIGNED NOT NULL DEFAULT 0,
+  duration_fixed INT UNSIGNED NOT NULL DEFAULT 0,
+  errors VARCHAR(1000) NULL,
+  PRIMARY KEY (id),
+  KEY idx_cdr_reconcile_runs_start (datetime_start)
+);
+
+-- diferencias encontradas en cada conciliacion y la accion tomada
+CREATE TABLE IF NOT EXISTS call_center.call_cdr_reconcile_issues (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  id_run INT UNSIGNED NOT NULL,
+  uniqueid VARCHAR(64) NOT NULL,
+  issue ENUM('missing_call', 'open_call', 'wrong_status', 'wrong_duration') NOT NULL,
+  calls_value VARCHAR(255) NOT NULL DEFAULT '',
+  cdr_value VARCHAR(255) NOT NULL DEFAULT '',
+  action ENUM('fixed', 'flagged', 'dry_run') NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_cdr_reconcile_issues_run (id_run),
+  KEY idx_cdr_reconcile_issues_uniqueid (uniqueid)
+);
@@ -0,0 +1,31 @@
+-- llamadas originadas desde la intranet (click-to-call), el resultado llega con OriginateResponse
+CREATE TABLE IF NOT EXISTS call_center.call_originates (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  action_id VARCHAR(32) NOT NULL DEFAULT '',
+  pbx VARCHAR(40) NOT NULL DEFAULT '',
+  agent VARCHAR(40) NOT NULL,
+  phone VARCHAR(50) NOT NULL,
+  caller_id VARCHAR(80) NOT NULL DEFAULT '',
+  account_code VARCHAR(80) NOT NULL DEFAULT '',
+  variables TEXT NULL,
+  status ENUM('queued', 'success', 'failure') NOT NULL DEFAULT 'queued',
+  reason VARCHAR(255) NULL,
+  uniqueid VARCHAR(32) NULL,
+  channel VARCHAR(80) NULL,
+  requested_by VARCHAR(40) NOT NULL DEFAULT '',
+  datetime_request DATETIME NOT NULL,
+  datetime_response DATETIME NULL,
+  PRIMARY KEY (id),
+  KEY idx_call_originates_action (action_id),
+  KEY idx_call_originates_agent (agent, datetime_request)
+);
+
+-- numeros que nunca se deben marcar, ademas de los marcados con calls.dnc
+CREATE TABLE IF NOT EXISTS call_center.call_blacklist (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  phone VARCHAR(50) NOT NULL,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  datetime_entry DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY idx_call_blacklist_phone (phone)
+);
@@ -0,0 +1,4 @@
+-- el uniqueid solo es unico dentro de cada central, dos centrales con el mismo systemname
+-- de asterisk.conf pueden repetirlo
+ALTER TABLE call_center.calls
+  ADD UNIQUE INDEX idx_calls_pbx_uniqueid (pbx, uniqueid);
@@ -0,0 +1,10 @@
+-- una sola rellamada abierta por telefono, open_phone solo tiene valor mientras no se cierra.
+-- Antes de crear el indice se cierran las abiertas repetidas dejando la mas nueva
+UPDATE call_center.call_callbacks AS cb
+  INNER JOIN call_center.call_callbacks AS newer ON newer.phone = cb.phone AND newer.status <> 'closed' AND newer.id > cb.id
+  SET cb.status = 'closed', cb.result = 'duplicate', cb.closed_by = 'system', cb.datetime_closed = NOW()
+  WHERE cb.status <> 'closed';
+
+ALTER TABLE call_center.call_callbacks
+  ADD COLUMN open_phone VARCHAR(50) AS (IF(status = 'closed', NULL, phone)) STORED,
+  ADD UNIQUE INDEX idx_call_callbacks_open_phone (open_phone);
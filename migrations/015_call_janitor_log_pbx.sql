@@ -0,0 +1,4 @@
+-- central de la llamada cerrada por el janitor, el uniqueid solo es unico dentro de cada central
+ALTER TABLE call_center.call_janitor_log
+  ADD COLUMN pbx VARCHAR(40) NOT NULL DEFAULT '' AFTER id,
+  ADD INDEX idx_call_janitor_pbx_uniqueid (pbx, uniqueid);
@@ -1,5 +1,129 @@
 package models
 
+import "time"
+
 type ExtensionReq struct {
 	Extension string `json:"extension" binding:"required,number,min=4,max=5"`
 }
+
+// Pbx central asterisk, se cargan desde el archivo .pbx
+type Pbx struct {
+	Name       string        `json:"name"`
+	Server     string        `json:"server"` // ip:puerto del AMI
+	User       string        `json:"user"`
+	Password   string        `json:"password"`
+	Extensions []string      `json:"extensions"` // regex de las extensiones de la central, vacio para la central por defecto
+	Technology string        `json:"technology"` // tecnologia por defecto de las extensiones, SIP si esta vacio
+	Channels   []ChannelRule `json:"channels"`   // tecnologia por rango de extensiones, ej: las migradas a PJSIP
+}
+
+// Originate llamada originada desde la extension del agente hacia un numero
+type Originate struct {
+	Agent       string
+	Phone       string
+	Context     string // contexto donde se marca Phone al contestar el agente
+	CallerId    string // lo que ve el agente en su telefono
+	ChannelId   string // uniqueid del canal del agente, asi se encuentra la llamada en los eventos
+	RingTimeout time.Duration
+	AccountCode string
+	Variables   map[string]string // variables de canal
+}
+
+// AmiServiceStatus estado de las sesiones AMI de todas las centrales y de sus workers
+type AmiServiceStatus struct {
+	Sessions       []AmiListenerStatus  `json:"sessions"`
+	Clients        []AmiClientStatus    `json:"clients"`
+	Subscribers    []BusSubscriberStats `json:"subscribers"` // suscriptores del bus de eventos
+	JournalDropped int64                `json:"journal_dropped"`
+	Dispatch       *AmiDispatchStats    `json:"dispatch,omitempty"`
+	Webhooks       *WebhookStats        `json:"webhooks,omitempty"`
+}
+
+type AmiListenerStatus struct {
+	Pbx            string     `json:"pbx"`
+	Server         string     `json:"server"`
+	Connected      bool       `json:"connected"`
+	ConnectedSince *time.Time `json:"connected_since"`
+	SessionCount   int        `json:"session_count"`
+	ReconnectCount int        `json:"reconnect_count"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	LastEventAt    *time.Time `json:"last_event_at,omitempty"`
+}
+
+// AmiClientStatus cliente compartido de cada central para las acciones AMI
+type AmiClientStatus struct {
+	Pbx            string     `json:"pbx"`
+	Server         string     `json:"server"`
+	Connected      bool       `json:"connected"`
+	ConnectedSince *time.Time `json:"connected_since"`
+	Logins         int        `json:"logins"`
+	Actions        int64      `json:"actions"`
+	Timeouts       int64      `json:"timeouts"`
+	Pending        int        `json:"pending"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+}
+
+type CallRecoveryReport struct {
+	Pbx          string          `json:"pbx"`
+	StartedAt    time.Time       `json:"started_at"`
+	FinishedAt   time.Time       `json:"finished_at"`
+	LiveChannels int             `json:"live_channels"`
+	RowsChecked  int             `json:"rows_checked"`
+	Reconnect    bool            `json:"reconnect"` // false en la primera sesion del proceso
+	Resumed      []RecoveredCall `json:"resumed"`
+	Kept         []RecoveredCall `json:"kept"` // ya rastreadas en memoria, no se tocan
+	Closed       []RecoveredCall `json:"closed"`
+	Errors       []string        `json:"errors,omitempty"`
+}
+
+type RecoveredCall struct {
+	Uniqueid    string `json:"uniqueid"`
+	Direction   string `json:"direction"`
+	Queue       string `json:"queue"`
+	Agent       string `json:"agent"`
+	Event       string `json:"event"`
+	Status      string `json:"status"`
+	Hold        string `json:"hold"`
+	HoldCount   int    `json:"hold_count"`
+	HoldSeconds int    `json:"hold_seconds"`
+}
+
+type AmiEventsReq struct {
+	Linkedid string `form:"linkedid" binding:"required"`
+	Days     int    `form:"days" binding:"omitempty,gte=1,lte=30"`
+}
+
+// AmiJournalEntry evento AMI tal como se recibio, las claves del evento van en minusculas
+type AmiJournalEntry struct {
+	ReceivedAt time.Time      `json:"received_at"`
+	Pbx        string         `json:"pbx"`
+	Server     string         `json:"server"`
+	Event      map[string]any `json:"event"`
+}
+
+// AmiReplayReport resultado de reproducir eventos grabados con el comando replay
+type AmiReplayReport struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Events     int            `json:"events"`
+	Skipped    int            `json:"skipped"`
+	ByEvent    map[string]int `json:"by_event"`
+	Calls      []TrackedCall  `json:"calls"`
+}
+
+// AmiDispatchStats metricas de los workers que procesan los eventos AMI
+type AmiDispatchStats struct {
+	Workers       int       `json:"workers"`
+	QueueCapacity int       `json:"queue_capacity"` // por worker
+	QueueDepth    []int     `json:"queue_depth"`
+	QueueTotal    int       `json:"queue_total"`
+	Processed     int64     `json:"processed"`
+	Dropped       int64     `json:"dropped"`
+	Panics        int64     `json:"panics"`
+	AvgWaitMs     float64   `json:"avg_wait_ms"`   // recibido -> inicio de proceso
+	AvgHandleMs   float64   `json:"avg_handle_ms"` // duracion de handleEvent
+	MaxHandleMs   float64   `json:"max_handle_ms"`
+	RunningSince  time.Time `json:"running_since"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// tipos de evento del bus interno, ver repo/eventBusRepo.go
+const (
+	BusCallStarted       = "call.started"
+	BusCallAnswered      = "call.answered"
+	BusCallHeld          = "call.held"
+	BusCallResumed       = "call.resumed"
+	BusCallTransferred   = "call.transferred"
+	BusCallEnded         = "call.ended"
+	BusExtensionState    = "extension.state"
+	BusQueueMemberPaused = "queue.member_paused"
+	BusOriginateResponse = "originate.response"
+)
+
+// BusPolicy que hacer con un suscriptor lento cuando su cola esta llena
+type BusPolicy string
+
+const (
+	BusDropNewest BusPolicy = "drop_newest" // se descarta el evento nuevo
+	BusDropOldest BusPolicy = "drop_oldest" // se descarta el evento mas viejo de la cola
+	BusDisconnect BusPolicy = "disconnect"  // se cierra la suscripcion
+)
+
+// BusEvent evento del bus, segun Type viene la llamada, la extension, el miembro de cola
+// o la llamada originada
+type BusEvent struct {
+	Id        uint64            `json:"id"`
+	Type      string            `json:"type"`
+	At        time.Time         `json:"at"`
+	Pbx       string            `json:"pbx"`
+	Call      *TrackedCall      `json:"call,omitempty"`
+	Transfer  *CallTransfer     `json:"transfer,omitempty"`
+	Extension *ExtensionState   `json:"extension,omitempty"`
+	Member    *QueueMemberPause `json:"member,omitempty"`
+	Originate *CallOriginate    `json:"originate,omitempty"`
+}
+
+// ExtensionState estado de una extension (evento ExtensionStatus), tambien es la
+// entrada de la cache de estados donde DeviceState viene de DeviceStateChange
+type ExtensionState struct {
+	Exten       string     `json:"exten"`
+	Status      string     `json:"status"`
+	StatusText  string     `json:"status_text"`
+	Hint        string     `json:"hint,omitempty"`
+	DeviceState string     `json:"device_state,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// QueueMemberPause pausa o despausa de un miembro de cola (evento QueueMemberPause)
+type QueueMemberPause struct {
+	Queue     string `json:"queue"`
+	Exten     string `json:"exten"`
+	Interface string `json:"interface"`
+	Paused    bool   `json:"paused"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// BusSubscriberStats estado de la cola de cada suscriptor del bus
+type BusSubscriberStats struct {
+	Name      string    `json:"name"`
+	Policy    BusPolicy `json:"policy"`
+	Types     []string  `json:"types"` // vacio recibe todos
+	Buffer    int       `json:"buffer"`
+	Depth     int       `json:"depth"`
+	Delivered int64     `json:"delivered"`
+	Dropped   int64     `json:"dropped"`
+	Since     time.Time `json:"since"`
+}
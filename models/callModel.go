@@ -0,0 +1,149 @@
+package models
+
+import "time"
+
+// CallState estado del ciclo de vida de una llamada rastreada por el listener
+type CallState int
+
+const (
+	CallDialing CallState = iota
+	CallRinging
+	CallActive
+	CallOnHold
+	CallTransferred
+	CallCompleted
+	CallNoAnswer
+)
+
+func (s CallState) String() string {
+	switch s {
+	case CallDialing:
+		return "Dialing"
+	case CallRinging:
+		return "Ringing"
+	case CallActive:
+		return "Active"
+	case CallOnHold:
+		return "OnHold"
+	case CallTransferred:
+		return "Transferred"
+	case CallCompleted:
+		return "Completed"
+	case CallNoAnswer:
+		return "NoAnswer"
+	default:
+		return "Unknown"
+	}
+}
+
+func (s CallState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// IsFinal indica si la llamada ya termino
+func (s CallState) IsFinal() bool {
+	return s == CallCompleted || s == CallNoAnswer
+}
+
+// CallEvent eventos AMI que disparan transiciones de estado
+type CallEvent string
+
+const (
+	EventDial         CallEvent = "Dial"
+	EventRingNoAnswer CallEvent = "RingNoAnswer"
+	EventAnswer       CallEvent = "Answer"
+	EventAbandon      CallEvent = "Abandon"
+	EventBridgeEnter  CallEvent = "BridgeEnter"
+	EventBridgeLeave  CallEvent = "BridgeLeave"
+	EventHold         CallEvent = "Hold"
+	EventUnhold       CallEvent = "Unhold"
+	EventTransfer     CallEvent = "Transfer"
+	EventHangup       CallEvent = "Hangup"
+	EventChannelsGone CallEvent = "ChannelsGone" // colgo el ultimo canal de la llamada
+)
+
+// direccion de la llamada, se guarda tal cual en calls.direction
+const (
+	DirectionOutbound = "outbound"
+	DirectionInbound  = "inbound"
+)
+
+// resultado normalizado de la llamada, se guarda en calls.outcome
+const (
+	OutcomeAnswered     = "answered"
+	OutcomeBusy         = "busy"
+	OutcomeNoAnswer     = "no_answer"
+	OutcomeRejected     = "rejected"
+	OutcomeCongestion   = "congestion"
+	OutcomeUnreachable  = "unreachable"
+	OutcomeAbandoned    = "abandoned" // el cliente colgo esperando en la cola
+	OutcomeCancelled    = "cancelled" // el agente colgo antes de que atendieran
+	OutcomeFailed       = "failed"
+	OutcomeSystemClosed = "system_closed" // cerrada por el janitor
+)
+
+// parte que colgo la llamada, se guarda en calls.hangup_by
+const (
+	HangupByAgent    = "agent"
+	HangupByCustomer = "customer"
+	HangupBySystem   = "system"
+)
+
+type TrackedCall struct {
+	Linkedid     string      `json:"linkedid"`
+	Pbx          string      `json:"pbx"`
+	Direction    string      `json:"direction"`
+	Agent        string      `json:"agent"`
+	Queue        string      `json:"queue,omitempty"`
+	Originated   bool        `json:"originated,omitempty"` // click-to-call, el canal propio es del agente aunque lleve el caller id del cliente
+	RingNoAnswer int         `json:"ring_no_answer"`
+	Transfers    int         `json:"transfers"`
+	HoldCount    int         `json:"hold_count"`
+	HoldSeconds  int         `json:"hold_seconds"`
+	HoldStarted  *time.Time  `json:"hold_started,omitempty"`
+	Hangup       *CallHangup `json:"hangup,omitempty"`
+	Outcome      string      `json:"outcome,omitempty"`
+	State        CallState   `json:"state"`
+	StartedAt    time.Time   `json:"started_at"`
+	AnsweredAt   *time.Time  `json:"answered_at,omitempty"`
+	EndedAt      *time.Time  `json:"ended_at,omitempty"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// CallHangup primer Hangup de la llamada, indica la causa y quien colgo
+type CallHangup struct {
+	Cause    int    `json:"cause"`
+	CauseTxt string `json:"cause_txt"`
+	Channel  string `json:"channel"`
+	By       string `json:"by"`
+}
+
+// TotalHoldSeconds segundos en espera incluyendo la espera en curso
+func (c TrackedCall) TotalHoldSeconds() int {
+	total := c.HoldSeconds
+	if c.HoldStarted != nil {
+		total += int(time.Since(*c.HoldStarted).Seconds())
+	}
+	return total
+}
+
+// CallJanitorReport resultado de una ejecucion del janitor de llamadas
+type CallJanitorReport struct {
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+	MaxAge     string      `json:"max_age"`
+	Checked    int         `json:"checked"`
+	StillAlive int         `json:"still_alive"`
+	Closed     []StaleCall `json:"closed"`
+	Errors     []string    `json:"errors,omitempty"`
+}
+
+type StaleCall struct {
+	Uniqueid   string    `json:"uniqueid"`
+	Pbx        string    `json:"pbx"`
+	Source     string    `json:"source"` // memory | current_calls | both
+	Agent      string    `json:"agent"`
+	State      string    `json:"state"` // estado en memoria o event de current_calls
+	StartedAt  time.Time `json:"started_at"`
+	AgeSeconds int       `json:"age_seconds"`
+}
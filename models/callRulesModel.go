@@ -0,0 +1,29 @@
+package models
+
+// CallRules reglas de rastreo de llamadas, se cargan desde el archivo .callrules
+type CallRules struct {
+	Outbound OutboundRules `json:"outbound"`
+	Inbound  InboundRules  `json:"inbound"`
+}
+
+// OutboundRules llamadas que inician en las extensiones de los agentes
+type OutboundRules struct {
+	Enabled             bool     `json:"enabled"`
+	AgentPatterns       []string `json:"agent_patterns"`        // regex sobre CallerIDNum del canal que origina
+	Contexts            []string `json:"contexts"`              // si no esta vacio solo se rastrean estos contextos
+	ExcludedContexts    []string `json:"excluded_contexts"`     // contextos ignorados en el hangup
+	DialedNumberContext string   `json:"dialed_number_context"` // contexto donde Exten es el numero marcado -> calls.trunk
+	Queue               string   `json:"queue"`                 // valor de current_calls.queue
+	CampaignId          int      `json:"campaign_id"`           // valor de calls.id_campaign
+}
+
+// InboundRules llamadas que entran por las colas
+type InboundRules struct {
+	Enabled bool        `json:"enabled"`
+	Queues  []QueueRule `json:"queues"`
+}
+
+type QueueRule struct {
+	Queue      string `json:"queue"`       // numero de la cola en asterisk -> current_calls.queue
+	CampaignId int    `json:"campaign_id"` // valor de calls.id_campaign
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// estado de la rellamada, se guarda en call_callbacks.status
+const (
+	CallbackPending = "pending" // esperando agente
+	CallbackCalling = "calling" // el job la esta originando
+	CallbackClaimed = "claimed" // un agente la tomo
+	CallbackClosed  = "closed"
+)
+
+type CallbacksReq struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending calling claimed closed"`
+	Queue  string `form:"queue"`
+	Phone  string `form:"phone"`
+}
+
+type CallbackIdReq struct {
+	Id int `uri:"id" binding:"required,gte=1"`
+}
+
+type CallbackClaimReq struct {
+	Agent string `json:"agent" binding:"required,number,min=4,max=5"`
+}
+
+type CallbackCloseReq struct {
+	Result string `json:"result" binding:"required,oneof=contacted unreachable wrong_number duplicate other"`
+	Notes  string `json:"notes" binding:"max=255"`
+}
+
+// Callback llamada abandonada pendiente de devolver, agrupa los abandonos del mismo telefono
+type Callback struct {
+	Id                   int               `json:"id"`
+	Phone                string            `json:"phone"`
+	IdCall               int               `json:"id_call"`  // primera llamada abandonada
+	Uniqueid             string            `json:"uniqueid"` // ultima llamada abandonada
+	Queue                string            `json:"queue"`
+	Pbx                  string            `json:"pbx"`
+	AbandonCount         int               `json:"abandon_count"`
+	Status               string            `json:"status"`
+	ClaimedBy            *string           `json:"claimed_by"`
+	Attempts             int               `json:"attempts"`
+	Result               *string           `json:"result"`
+	Notes                *string           `json:"notes"`
+	ClosedBy             *string           `json:"closed_by"`
+	DatetimeFirstAbandon string            `json:"datetime_first_abandon"`
+	DatetimeLastAbandon  string            `json:"datetime_last_abandon"`
+	DatetimeClaimed      *string           `json:"datetime_claimed"`
+	DatetimeLastAttempt  *string           `json:"datetime_last_attempt"`
+	DatetimeClosed       *string           `json:"datetime_closed"`
+	AttemptList          []CallbackAttempt `json:"attempt_list,omitempty"`
+}
+
+// CallbackAttempt intento de rellamada originado hacia un agente
+type CallbackAttempt struct {
+	Id              int     `json:"id"`
+	IdCallback      int     `json:"id_callback"`
+	IdCall          int     `json:"id_call"` // llamada abandonada original
+	Agent           string  `json:"agent"`
+	Uniqueid        string  `json:"uniqueid"` // ChannelId del Originate
+	Result          string  `json:"result"`   // calling | answered | failed
+	Reason          *string `json:"reason"`
+	DatetimeAttempt string  `json:"datetime_attempt"`
+	DatetimeResult  *string `json:"datetime_result"`
+}
+
+// CallbackDialerReport resultado de una ejecucion del job de rellamadas
+type CallbackDialerReport struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Due        int               `json:"due"`
+	FreeAgents int               `json:"free_agents"`
+	Attempts   []CallbackAttempt `json:"attempts"`
+	Errors     []string          `json:"errors,omitempty"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// problema encontrado al comparar calls contra el CDR de asterisk
+const (
+	CdrMissingCall   = "missing_call"   // la llamada esta en el CDR y cumple las reglas pero no en calls
+	CdrOpenCall      = "open_call"      // el CDR ya la termino pero calls sigue abierta
+	CdrWrongStatus   = "wrong_status"   // atendida en uno y sin atender en el otro
+	CdrWrongDuration = "wrong_duration" // duration difiere de lo hablado segun el CDR
+)
+
+// accion tomada sobre el problema
+const (
+	CdrFixed   = "fixed"
+	CdrFlagged = "flagged" // queda registrado para revision, no se corrige
+	CdrDryRun  = "dry_run" // se hubiera corregido
+)
+
+// CdrReconcileReq ejecucion manual de la conciliacion, sin fechas se usa la ventana por defecto
+type CdrReconcileReq struct {
+	DateFrom string `form:"date_from" binding:"required_with=DateTo,omitempty,datetime=2006-01-02 15:04:05"`
+	DateTo   string `form:"date_to" binding:"required_with=DateFrom,omitempty,datetime=2006-01-02 15:04:05"`
+	DryRun   bool   `form:"dry_run"`
+}
+
+// CdrCall registros del CDR de una misma llamada agrupados por linkedid
+type CdrCall struct {
+	Linkedid    string    `json:"linkedid"`
+	Uniqueids   []string  `json:"uniqueids"`
+	Src         string    `json:"src"`      // del primer registro
+	Dst         string    `json:"dst"`      // del primer registro
+	Context     string    `json:"dcontext"` // del primer registro
+	Queue       string    `json:"queue"`    // dst del registro con lastapp Queue
+	StartedAt   time.Time `json:"started_at"`
+	AnsweredAt  time.Time `json:"answered_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	Answered    bool      `json:"answered"` // algun tramo con dstchannel fue contestado
+	Talk        int       `json:"talk"`     // segundos desde la primera contestacion hasta el final del ultimo tramo contestado
+	Disposition string    `json:"disposition"`
+}
+
+// CdrIssue diferencia entre calls y el CDR
+type CdrIssue struct {
+	Uniqueid   string `json:"uniqueid"`
+	Issue      string `json:"issue"`
+	CallsValue string `json:"calls_value"`
+	CdrValue   string `json:"cdr_value"`
+	Action     string `json:"action"`
+}
+
+// CdrReconcileReport resumen de cada ejecucion de la conciliacion
+type CdrReconcileReport struct {
+	Id            int64      `json:"id"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    time.Time  `json:"finished_at"`
+	DryRun        bool       `json:"dry_run"`
+	WindowFrom    string     `json:"window_from"`
+	WindowTo      string     `json:"window_to"`
+	CdrCalls      int        `json:"cdr_calls"`
+	Matched       int        `json:"matched"`
+	Untracked     int        `json:"untracked"` // llamadas del CDR que no cumplen las reglas de rastreo
+	Missing       int        `json:"missing"`
+	OpenFixed     int        `json:"open_fixed"`
+	StatusFixed   int        `json:"status_fixed"`
+	DurationFixed int        `json:"duration_fixed"`
+	Issues        []CdrIssue `json:"issues"`
+	Errors        []string   `json:"errors,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// tecnologias de canal soportadas
+const (
+	TechSIP   = "SIP"
+	TechPJSIP = "PJSIP"
+	TechIAX2  = "IAX2"
+	TechLocal = "Local"
+)
+
+// ChannelRule tecnologia de un rango de extensiones de la central
+type ChannelRule struct {
+	Extensions string `json:"extensions"` // regex de las extensiones
+	Technology string `json:"technology"` // SIP, PJSIP, IAX2 o Local
+	Context    string `json:"context"`    // solo Local, por defecto from-internal
+}
+
+// Channel extension direccionada con su tecnologia
+type Channel struct {
+	Tech    string `json:"tech"`
+	Exten   string `json:"exten"`
+	Context string `json:"context,omitempty"`
+}
+
+// Dial nombre del endpoint para Originate o Dial, ej: PJSIP/8001, Local/8001@from-internal
+func (c Channel) Dial() string {
+	if c.Tech == TechLocal {
+		return fmt.Sprintf("%s/%s@%s", c.Tech, c.Exten, c.Context)
+	}
+	return fmt.Sprintf("%s/%s", c.Tech, c.Exten)
+}
+
+// Regex expresion para las acciones AMI que aceptan /regex/ en Channel, coincide con
+// todos los canales abiertos del endpoint, ej: /^SIP/8001-.*$/
+func (c Channel) Regex() string {
+	return fmt.Sprintf("/^%s-.*$/", regexp.QuoteMeta(c.Dial()))
+}
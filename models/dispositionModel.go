@@ -0,0 +1,66 @@
+package models
+
+// Dispositions catalogo de tipificaciones de llamadas, se carga desde el archivo .dispositions
+type Dispositions struct {
+	Codes []DispositionCode `json:"codes"`
+}
+
+// DispositionCode motivo de la llamada, los deshabilitados se mantienen para los reportes
+type DispositionCode struct {
+	Code         string               `json:"code"`
+	Label        string               `json:"label"`
+	Disabled     bool                 `json:"disabled"`
+	NoteRequired bool                 `json:"note_required"` // el agente debe escribir una nota, ej: otro
+	Subcodes     []DispositionSubcode `json:"subcodes"`      // si tiene subcodigos es obligatorio elegir uno
+}
+
+type DispositionSubcode struct {
+	Code     string `json:"code"`
+	Label    string `json:"label"`
+	Disabled bool   `json:"disabled"`
+}
+
+// DispositionReq tipificacion enviada por el softphone o la intranet, la llamada se indica
+// por uniqueid o por la extension del agente, en ese caso se usa su ultima llamada. El
+// uniqueid se busca en la central indicada, sin central en la de la extension
+type DispositionReq struct {
+	Uniqueid  string `json:"uniqueid" binding:"required_without=Extension,max=64"`
+	Pbx       string `json:"pbx" binding:"max=40"`
+	Extension string `json:"extension" binding:"required_without=Uniqueid,omitempty,number,min=4,max=5"`
+	Code      string `json:"code" binding:"required,max=32"`
+	Subcode   string `json:"subcode" binding:"max=32"`
+	Notes     string `json:"notes" binding:"max=500"`
+}
+
+// CallDisposition tipificacion guardada de una llamada, una por llamada
+type CallDisposition struct {
+	Id           int     `json:"id"`
+	IdCall       int     `json:"id_call"`
+	Uniqueid     string  `json:"uniqueid"`
+	Agent        string  `json:"agent"`
+	Code         string  `json:"code"`
+	Subcode      *string `json:"subcode"`
+	Notes        *string `json:"notes"`
+	CreatedBy    string  `json:"created_by"`
+	DateCreated  string  `json:"datetime_disposition"`
+	DateModified *string `json:"datetime_update"`
+}
+
+type DispositionsReportReq struct {
+	DateFrom string `form:"date_from" binding:"required,datetime=2006-01-02"`
+	DateTo   string `form:"date_to" binding:"required,datetime=2006-01-02"`
+	Agent    string `form:"agent" binding:"omitempty,number"`
+	Code     string `form:"code" binding:"max=32"`
+}
+
+// DispositionsReport cantidad de llamadas tipificadas por dia de la llamada, agente y codigo
+type DispositionsReport struct {
+	Day          string `json:"day"`
+	Agent        string `json:"agent"`
+	Code         string `json:"code"`
+	Label        string `json:"label"`
+	Subcode      string `json:"subcode"`
+	SubcodeLabel string `json:"subcode_label"`
+	Total        int    `json:"total"`
+	Seconds      int    `json:"seconds"` // suma de duration de las llamadas
+}
@@ -1,13 +1,62 @@
 package models
 
+import "time"
+
 type ExtensionStatus struct {
-	Extension string `json:"extension"`
-	Status    string `json:"status"`
-	OnQueue   bool   `json:"on_queue"`
+	Extension        string     `json:"extension"`
+	Pbx              string     `json:"pbx"`
+	Status           string     `json:"status"`
+	StatusSource     string     `json:"status_source"` // cache | live, vacio si no se pudo obtener
+	StatusUpdatedAt  *time.Time `json:"status_updated_at,omitempty"`
+	StatusAgeSeconds int        `json:"status_age_seconds"` // antiguedad del estado de la cache
+	DeviceState      string     `json:"device_state,omitempty"`
+	OnQueue          bool       `json:"on_queue"`
+	OnHold           bool       `json:"on_hold"`
+	HoldCount        int        `json:"hold_count"`
+	HoldSeconds      int        `json:"hold_seconds"`
+}
+
+type QueueStatsReq struct {
+	Queue string `form:"queue"` // vacio todas las colas
+}
+
+// QueueStats estado en tiempo real de una cola segun QueueStatus (QueueParams, QueueMember y QueueEntry)
+type QueueStats struct {
+	Queue              string             `json:"queue"`
+	Pbx                string             `json:"pbx"`
+	Strategy           string             `json:"strategy"`
+	CallsWaiting       int                `json:"calls_waiting"`
+	LongestWaitSeconds int                `json:"longest_wait_seconds"`
+	Completed          int                `json:"completed"`
+	Abandoned          int                `json:"abandoned"`
+	ServiceLevel       int                `json:"service_level"`      // segundos del objetivo de servicio
+	ServiceLevelPerf   float64            `json:"service_level_perf"` // % atendidas dentro del objetivo
+	HoldTimeAvg        int                `json:"holdtime_avg"`       // segundos promedio de espera
+	TalkTimeAvg        int                `json:"talktime_avg"`       // segundos promedio de conversacion
+	Members            []QueueMemberStats `json:"members"`
+	Callers            []QueueCaller      `json:"callers"`
+}
+
+type QueueMemberStats struct {
+	Extension    string     `json:"extension"`
+	Name         string     `json:"name"`
+	Interface    string     `json:"interface"`
+	Membership   string     `json:"membership"` // static | dynamic | realtime
+	Penalty      int        `json:"penalty"`
+	Status       string     `json:"status"`
+	InCall       bool       `json:"in_call"`
+	Paused       bool       `json:"paused"`
+	PausedReason string     `json:"paused_reason"`
+	CallsTaken   int        `json:"calls_taken"`
+	LastCall     *time.Time `json:"last_call"`
 }
 
-type QueueMember struct {
-	QueueName string `json:"queue_name"`
-	Extension string `json:"extension"`
-	Status    string `json:"status"`
+// QueueCaller cliente esperando en la cola
+type QueueCaller struct {
+	Position     int    `json:"position"`
+	Uniqueid     string `json:"uniqueid"`
+	Channel      string `json:"channel"`
+	CallerIDNum  string `json:"calleridnum"`
+	CallerIDName string `json:"calleridname"`
+	WaitSeconds  int    `json:"wait_seconds"`
 }
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// LiveEventsReq filtros de los eventos en vivo, las listas van separadas por coma
+type LiveEventsReq struct {
+	Extension   string `form:"extension"`     // ej: 8001,8002
+	Queue       string `form:"queue"`         // ej: 8000
+	Type        string `form:"type"`          // ej: call.started,call.ended
+	LastEventId uint64 `form:"last_event_id"` // cursor, se envian los eventos posteriores a este id
+}
+
+// LiveFilter filtros ya separados de LiveEventsReq
+type LiveFilter struct {
+	Extensions []string
+	Queues     []string
+	Types      []string
+}
+
+var busEventTypes = []string{BusCallStarted, BusCallAnswered, BusCallHeld, BusCallResumed, BusCallTransferred, BusCallEnded,
+	BusExtensionState, BusQueueMemberPaused, BusOriginateResponse}
+
+// Filter separa las listas y valida los tipos de evento
+func (r LiveEventsReq) Filter() (LiveFilter, error) {
+	filter := LiveFilter{Extensions: splitList(r.Extension), Queues: splitList(r.Queue), Types: splitList(r.Type)}
+	return filter, ValidBusEventTypes(filter.Types)
+}
+
+// ValidBusEventTypes valida que cada tipo sea un evento del bus
+func ValidBusEventTypes(types []string) error {
+	for _, t := range types {
+		if !slices.Contains(busEventTypes, t) {
+			return fmt.Errorf("type %q must be one of %s", t, strings.Join(busEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// Match indica si el evento pasa los filtros, la extension puede ser el agente de la
+// llamada, el origen o destino de una transferencia, la extension, el miembro de cola o
+// el agente de la llamada originada
+func (f LiveFilter) Match(ev BusEvent) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, ev.Type) {
+		return false
+	}
+
+	if len(f.Extensions) > 0 {
+		var extens []string
+		if ev.Call != nil {
+			extens = append(extens, ev.Call.Agent)
+		}
+		if ev.Transfer != nil {
+			extens = append(extens, ev.Transfer.FromAgent, ev.Transfer.Target)
+		}
+		if ev.Extension != nil {
+			extens = append(extens, ev.Extension.Exten)
+		}
+		if ev.Member != nil {
+			extens = append(extens, ev.Member.Exten)
+		}
+		if ev.Originate != nil {
+			extens = append(extens, ev.Originate.Agent)
+		}
+		if !slices.ContainsFunc(extens, func(exten string) bool { return slices.Contains(f.Extensions, exten) }) {
+			return false
+		}
+	}
+
+	if len(f.Queues) > 0 {
+		var queue string
+		if ev.Call != nil {
+			queue = ev.Call.Queue
+		}
+		if ev.Member != nil {
+			queue = ev.Member.Queue
+		}
+		if !slices.Contains(f.Queues, queue) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
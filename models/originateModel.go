@@ -0,0 +1,41 @@
+package models
+
+// estado de la llamada originada, se guarda en call_originates.status
+const (
+	OriginateQueued  = "queued"  // asterisk acepto la accion, falta el OriginateResponse
+	OriginateSuccess = "success" // el agente contesto y se esta marcando el numero
+	OriginateFailure = "failure"
+)
+
+// OriginateReq click-to-call, repica la extension del agente y al contestar marca el numero
+type OriginateReq struct {
+	Extension   string            `json:"extension" binding:"required,number,min=4,max=5"`
+	Phone       string            `json:"phone" binding:"required,number,min=7,max=15"`
+	CallerId    string            `json:"caller_id" binding:"omitempty,printascii,max=80"`
+	Timeout     int               `json:"timeout" binding:"omitempty,gte=5,lte=120"` // segundos de repique del agente
+	AccountCode string            `json:"account_code" binding:"omitempty,printascii,max=80"`
+	Variables   map[string]string `json:"variables" binding:"omitempty,max=20,dive,keys,max=40,endkeys,printascii,max=255"`
+}
+
+type OriginateIdReq struct {
+	Id int `uri:"id" binding:"required,gte=1"`
+}
+
+// CallOriginate llamada originada, Uniqueid y Channel vienen en el OriginateResponse
+type CallOriginate struct {
+	Id               int               `json:"id"`
+	ActionId         string            `json:"action_id"`
+	Pbx              string            `json:"pbx"`
+	Agent            string            `json:"agent"`
+	Phone            string            `json:"phone"`
+	CallerId         string            `json:"caller_id"`
+	AccountCode      string            `json:"account_code"`
+	Variables        map[string]string `json:"variables,omitempty"`
+	Status           string            `json:"status"`
+	Reason           *string           `json:"reason"`
+	Uniqueid         *string           `json:"uniqueid"`
+	Channel          *string           `json:"channel"`
+	RequestedBy      string            `json:"requested_by"`
+	DatetimeRequest  string            `json:"datetime_request"`
+	DatetimeResponse *string           `json:"datetime_response"`
+}
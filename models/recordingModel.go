@@ -0,0 +1,21 @@
+package models
+
+type RecordingReq struct {
+	Id       int  `uri:"id" binding:"required,gte=1"`
+	Download bool `form:"download"`
+}
+
+type CallRecording struct {
+	IdCall   int    `json:"id_call"`
+	Uniqueid string `json:"uniqueid"`
+	File     string `json:"file"`
+}
+
+// RecordingAccess registro de cada acceso a una grabacion (auditoria)
+type RecordingAccess struct {
+	IdCall   int
+	Username string
+	ClientIp string
+	Range    string
+	Result   string // served | not_found | file_missing
+}
@@ -0,0 +1,58 @@
+package models
+
+type CallsReportReq struct {
+	DateFrom string `form:"date_from" binding:"required,datetime=2006-01-02"`
+	DateTo   string `form:"date_to" binding:"required,datetime=2006-01-02"`
+	Agent    string `form:"agent" binding:"omitempty,number"`
+	Outcome  string `form:"outcome" binding:"omitempty,oneof=answered busy no_answer rejected congestion unreachable abandoned cancelled failed system_closed unknown"`
+}
+
+type CallReport struct {
+	Id           int     `json:"id"`
+	Uniqueid     string  `json:"uniqueid"`
+	Pbx          *string `json:"pbx"`
+	Direction    string  `json:"direction"`
+	Phone        string  `json:"phone"`
+	Agent        *string `json:"agent"`
+	Status       *string `json:"status"`
+	CallDate     string  `json:"call_date"`
+	StartTime    *string `json:"start_time"`
+	EndTime      *string `json:"end_time"`
+	DurationWait *int    `json:"duration_wait"`
+	Duration     *int    `json:"duration"`
+	HoldCount    int     `json:"hold_count"`
+	HoldSeconds  int     `json:"hold_seconds"`
+	Transfers    int     `json:"transfers"`
+	ResolvedBy   *string `json:"resolved_by"`
+	Outcome      string  `json:"outcome"`
+	HangupBy     *string `json:"hangup_by"`
+	HangupCause  *int    `json:"hangup_cause"`
+	HangupTxt    *string `json:"hangup_cause_txt"`
+	Disposition  *string `json:"disposition"`
+	Subcode      *string `json:"disposition_subcode"`
+}
+
+// CallsOutcome cantidad de llamadas agrupadas por resultado
+type CallsOutcome struct {
+	Direction string `json:"direction"`
+	Outcome   string `json:"outcome"`
+	HangupBy  string `json:"hangup_by"`
+	Total     int    `json:"total"`
+	Seconds   int    `json:"seconds"` // suma de duration
+}
+
+type CallTransfersReq struct {
+	Uniqueid string `form:"uniqueid" binding:"required"`
+	Pbx      string `form:"pbx" binding:"max=40"` // central de la llamada, vacio la central por defecto
+}
+
+type CallTransfer struct {
+	Id            int     `json:"id"`
+	Type          string  `json:"type"` // blind | attended
+	FromAgent     string  `json:"from_agent"`
+	Target        string  `json:"target"`
+	TargetType    string  `json:"target_type"` // agent | queue | external
+	AnsweredBy    *string `json:"answered_by"`
+	TransferredAt string  `json:"transferred_at"`
+	AnsweredAt    *string `json:"answered_at"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// estado de la entrega de un webhook, se guarda en webhook_deliveries.status
+const (
+	WebhookPending   = "pending"   // esperando el primer intento
+	WebhookSending   = "sending"   // un worker la esta enviando
+	WebhookRetrying  = "retrying"  // fallo, se reintenta en next_attempt_at
+	WebhookDelivered = "delivered" // el receptor respondio 2xx
+	WebhookDead      = "dead"      // se agotaron los intentos, solo se reenvia manualmente
+)
+
+// Webhook suscripcion a los eventos del bus, se cargan desde el archivo .webhooks
+type Webhook struct {
+	Name       string   `json:"name"`
+	Url        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"` // llave del HMAC de la firma
+	Types      []string `json:"types"`            // tipos de evento, vacio todos
+	Extensions []string `json:"extensions"`       // extensiones de agente, vacio todas
+	Queues     []string `json:"queues"`           // colas, vacio todas
+	Disabled   bool     `json:"disabled"`
+}
+
+// Filter filtros del webhook con la misma logica de los eventos en vivo
+func (w Webhook) Filter() LiveFilter {
+	return LiveFilter{Extensions: w.Extensions, Queues: w.Queues, Types: w.Types}
+}
+
+type WebhookDeliveriesReq struct {
+	Webhook string `form:"webhook"`
+	Type    string `form:"type"`
+	Limit   int    `form:"limit" binding:"omitempty,gte=1,lte=1000"`
+}
+
+type WebhookDeliveryIdReq struct {
+	Id int `uri:"id" binding:"required,gte=1"`
+}
+
+// WebhookDelivery evento a entregar a un webhook, Payload es el cuerpo enviado en cada intento
+type WebhookDelivery struct {
+	Id              int                      `json:"id"`
+	Webhook         string                   `json:"webhook"`
+	Url             string                   `json:"url"`
+	EventType       string                   `json:"event_type"`
+	EventId         uint64                   `json:"event_id"`
+	Payload         string                   `json:"payload"`
+	Status          string                   `json:"status"`
+	Attempts        int                      `json:"attempts"`
+	LastStatusCode  *int                     `json:"last_status_code"`
+	LastError       *string                  `json:"last_error"`
+	DateCreated     string                   `json:"datetime_created"`
+	DateNextAttempt *string                  `json:"datetime_next_attempt"`
+	DateDelivered   *string                  `json:"datetime_delivered"`
+	AttemptList     []WebhookDeliveryAttempt `json:"attempt_list,omitempty"`
+}
+
+type WebhookDeliveryAttempt struct {
+	Attempt     int     `json:"attempt"`
+	StatusCode  *int    `json:"status_code"`
+	Error       *string `json:"error"`
+	DurationMs  int     `json:"duration_ms"`
+	DateAttempt string  `json:"datetime_attempt"`
+	Manual      bool    `json:"manual"` // reenvio desde el endpoint de administracion
+}
+
+// WebhookStats estado del despachador de webhooks
+type WebhookStats struct {
+	Webhooks     int       `json:"webhooks"`
+	Workers      int       `json:"workers"`
+	QueueDepth   int       `json:"queue_depth"`
+	Sent         int64     `json:"sent"`
+	Delivered    int64     `json:"delivered"`
+	Failed       int64     `json:"failed"`
+	Dead         int64     `json:"dead"`
+	Dropped      int64     `json:"dropped"` // eventos descartados por el bus con la cola llena
+	Lost         int64     `json:"lost"`    // entregas que no se pudieron guardar en mysql
+	RunningSince time.Time `json:"running_since"`
+}
@@ -35,10 +35,26 @@ func errorToJson(fieldError validator.FieldError, c *gin.Context) string {
 		return ginI18n.MustGetMessage(c, "veNotzero") + " " + fieldError.Param()
 	case "alfanumspa":
 		return ginI18n.MustGetMessage(c, "veAlphaNumSpa")
+	case "printascii":
+		return ginI18n.MustGetMessage(c, "vePrintAscii")
 	case "gte_number":
 		return ginI18n.MustGetMessage(c, "veGte") + " " + fieldError.Param()
 	case "lte_number":
 		return ginI18n.MustGetMessage(c, "veLte") + " " + fieldError.Param()
+	case "datetime":
+		return ginI18n.MustGetMessage(c, "veDatetime") + " " + fieldError.Param()
+	case "oneof":
+		return ginI18n.MustGetMessage(c, "veOneOf") + ": " + fieldError.Param()
+	case "required_with":
+		return ginI18n.MustGetMessage(c, "veRequiredWith") + " " + strings.ToLower(fieldError.Param())
+	case "required_without":
+		return ginI18n.MustGetMessage(c, "veRequiredWithout") + " " + strings.ToLower(fieldError.Param())
+	case "disposition":
+		return ginI18n.MustGetMessage(c, "veDisposition")
+	case "disposition_subcode":
+		return ginI18n.MustGetMessage(c, "veDispositionSubcode") + " " + fieldError.Param()
+	case "date_range":
+		return ginI18n.MustGetMessage(c, "veDateRange") + " " + fieldError.Param()
 	}
 	return fieldError.Error() // default error
 }
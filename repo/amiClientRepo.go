@@ -0,0 +1,289 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+const amiActionTimeout = 5 * time.Second // espera por defecto de la respuesta de una accion
+
+// cliente AMI compartido por central para enviar acciones. Una sola sesion con login
+// multiplexa las acciones concurrentes, la respuesta y la lista de eventos de cada
+// accion vuelven a quien la envio segun su ActionID. La sesion no recibe los eventos
+// de las llamadas (EventMask off), esos se leen en amiListenerRepo.go
+type amiClient struct {
+	pbx     models.Pbx
+	mu      sync.Mutex
+	client  *goami2.Client
+	dialing *amiDial // sesion abriendose, nil si no hay ninguna
+	pending map[string]*amiRequest
+	seq     uint64
+	status  models.AmiClientStatus
+}
+
+// amiDial conexion en curso, las acciones que llegan mientras tanto esperan su resultado
+type amiDial struct {
+	done chan struct{}
+	err  error
+}
+
+// amiResult respuesta de una accion y los eventos de su lista, ej: QueueMember de QueueStatus
+type amiResult struct {
+	Response *goami2.Message
+	Events   []*goami2.Message
+}
+
+type amiRequest struct {
+	result amiResult
+	done   chan error
+}
+
+var amiClientsMu sync.Mutex
+var amiClients = make(map[string]*amiClient)
+
+// amiAction envia la accion a la central por su cliente compartido y espera la
+// respuesta completa, si la central responde Error se retorna el resultado y el error
+func amiAction(pbx models.Pbx, action *goami2.Message, timeout time.Duration) (amiResult, error) {
+	return getAmiClient(pbx).send(action, timeout)
+}
+
+// AmiClientsStatus estado de los clientes de acciones en el orden de .pbx
+func AmiClientsStatus() []models.AmiClientStatus {
+	status := []models.AmiClientStatus{}
+	for _, pbx := range utils.PbxList() {
+		amiClientsMu.Lock()
+		c, ok := amiClients[pbx.Name]
+		amiClientsMu.Unlock()
+		if !ok {
+			continue
+		}
+		c.mu.Lock()
+		s := c.status
+		s.Pending = len(c.pending)
+		c.mu.Unlock()
+		status = append(status, s)
+	}
+	return status
+}
+
+func getAmiClient(pbx models.Pbx) *amiClient {
+	amiClientsMu.Lock()
+	defer amiClientsMu.Unlock()
+	c, ok := amiClients[pbx.Name]
+	if !ok {
+		c = &amiClient{pbx: pbx, pending: make(map[string]*amiRequest), status: models.AmiClientStatus{Pbx: pbx.Name, Server: pbx.Server}}
+		amiClients[pbx.Name] = c
+	}
+	return c
+}
+
+func (c *amiClient) send(action *goami2.Message, timeout time.Duration) (amiResult, error) {
+	name := action.Field("Action")
+
+	if err := c.session(); err != nil {
+		return amiResult{}, err
+	}
+
+	c.mu.Lock()
+	if c.client == nil {
+		c.mu.Unlock()
+		return amiResult{}, fmt.Errorf("ami session %s closed before sending %s", c.pbx.Name, name)
+	}
+	c.seq++
+	// el Originate asincrono trae su ActionID, asi se encuentra su OriginateResponse
+	actionID := action.ActionID()
+	if actionID == "" {
+		actionID = fmt.Sprintf("%s-%d-%d", strings.ToLower(name), time.Now().UnixNano(), c.seq)
+		action.SetField("ActionID", actionID)
+	}
+	req := &amiRequest{done: make(chan error, 1)}
+	c.pending[actionID] = req
+	c.status.Actions++
+	client := c.client
+	c.mu.Unlock()
+
+	if err := client.MustSend(action.Byte()); err != nil {
+		c.drop(client, err)
+		return amiResult{}, fmt.Errorf("failed to send %s to %s: %v", name, c.pbx.Name, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-req.done:
+		return req.result, err
+	case <-timer.C:
+		c.mu.Lock()
+		delete(c.pending, actionID)
+		c.status.Timeouts++
+		c.mu.Unlock()
+		return amiResult{}, fmt.Errorf("timeout waiting for %s on %s", name, c.pbx.Name)
+	}
+}
+
+// session abre la sesion si no hay una, solo una accion conecta y las demas esperan su
+// resultado sin tomar c.mu, asi el estado del cliente y las respuestas de la sesion
+// anterior no se bloquean mientras la central tarda en responder el login
+func (c *amiClient) session() error {
+	c.mu.Lock()
+	if c.client != nil {
+		c.mu.Unlock()
+		return nil
+	}
+	if dial := c.dialing; dial != nil {
+		c.mu.Unlock()
+		<-dial.done
+		return dial.err
+	}
+	dial := &amiDial{done: make(chan struct{})}
+	c.dialing = dial
+	c.mu.Unlock()
+
+	dial.err = c.connect()
+	c.mu.Lock()
+	c.dialing = nil
+	c.mu.Unlock()
+	close(dial.done)
+	return dial.err
+}
+
+// connect conecta y hace login sin c.mu, el lock solo se toma para instalar la sesion
+func (c *amiClient) connect() error {
+	client, err := utils.ConnectToAmi(c.pbx)
+	if err != nil {
+		c.mu.Lock()
+		c.setError(err)
+		c.mu.Unlock()
+		return err
+	}
+
+	// solo respuestas de acciones, los eventos de las listas llegan igual
+	events := goami2.NewAction("Events")
+	events.SetField("EventMask", "off")
+	if err := client.MustSend(events.Byte()); err != nil {
+		client.Close()
+		c.mu.Lock()
+		c.setError(err)
+		c.mu.Unlock()
+		return fmt.Errorf("error logging into ami %s", c.pbx.Name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.client = client
+	c.status.Connected = true
+	c.status.ConnectedSince = &now
+	c.status.Logins++
+	utils.Logline("ami action client connected", c.pbx.Name)
+
+	go c.read(client, client.AllMessages(), client.Err())
+	return nil
+}
+
+// read reparte los mensajes de la sesion a las acciones pendientes y mantiene viva la
+// conexion con Ping, termina cuando la sesion se cae
+func (c *amiClient) read(client *goami2.Client, msgs <-chan *goami2.Message, errs <-chan error) {
+	ping := time.NewTicker(amiPingInterval)
+	defer ping.Stop()
+	lastMsg := time.Now()
+
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				c.drop(client, fmt.Errorf("ami connection closed"))
+				return
+			}
+			if msg != nil {
+				lastMsg = time.Now()
+				c.route(msg)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				err = fmt.Errorf("ami connection closed")
+			}
+			c.drop(client, err)
+			return
+		case <-ping.C:
+			if time.Since(lastMsg) > amiIdleTimeout {
+				c.drop(client, fmt.Errorf("ami session idle for more than %s", amiIdleTimeout))
+				return
+			}
+			// la respuesta no tiene ActionID registrado y se descarta en route
+			client.Send(goami2.NewAction("Ping").Byte())
+		}
+	}
+}
+
+// route entrega el mensaje a la accion que lo pidio, una accion termina con su respuesta
+// o, si la respuesta anuncia una lista, con el evento que la completa
+func (c *amiClient) route(msg *goami2.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	actionID := msg.ActionID()
+	req, ok := c.pending[actionID]
+	if !ok {
+		return
+	}
+
+	if msg.IsResponse() {
+		req.result.Response = msg
+		switch {
+		case !msg.IsSuccess():
+			c.finish(actionID, req, fmt.Errorf("%s on %s: %s", msg.Field("Response"), c.pbx.Name, msg.Field("Message")))
+		case strings.EqualFold(msg.Field("EventList"), "start"), strings.Contains(strings.ToLower(msg.Field("Message")), "will follow"):
+			// siguen los eventos de la lista
+		default:
+			c.finish(actionID, req, nil)
+		}
+		return
+	}
+
+	if strings.EqualFold(msg.Field("EventList"), "Complete") || strings.HasSuffix(msg.Field("Event"), "Complete") {
+		c.finish(actionID, req, nil)
+		return
+	}
+	req.result.Events = append(req.result.Events, msg)
+}
+
+// finish se llama con c.mu tomado
+func (c *amiClient) finish(actionID string, req *amiRequest, err error) {
+	delete(c.pending, actionID)
+	req.done <- err
+}
+
+// drop cierra la sesion y falla las acciones pendientes, la siguiente accion reconecta
+func (c *amiClient) drop(client *goami2.Client, err error) {
+	c.mu.Lock()
+	if c.client != client {
+		c.mu.Unlock()
+		return
+	}
+	c.client = nil
+	c.status.Connected = false
+	c.status.ConnectedSince = nil
+	c.setError(err)
+	for actionID, req := range c.pending {
+		c.finish(actionID, req, fmt.Errorf("ami session %s closed: %v", c.pbx.Name, err))
+	}
+	c.mu.Unlock()
+
+	utils.Logline("ami action client disconnected", c.pbx.Name, err)
+	client.Close()
+}
+
+// setError se llama con c.mu tomado
+func (c *amiClient) setError(err error) {
+	now := time.Now()
+	c.status.LastError = err.Error()
+	c.status.LastErrorAt = &now
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+)
+
+// el login lento de la central no bloquea el cliente y solo una accion conecta
+func TestAmiClientDialsOutsideLock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			// nunca responde el login y luego corta
+			go func() {
+				time.Sleep(300 * time.Millisecond)
+				conn.Close()
+			}()
+		}
+	}()
+
+	pbx := models.Pbx{Name: "lenta", Server: ln.Addr().String(), User: "admin", Password: "secret"}
+	c := &amiClient{pbx: pbx, pending: make(map[string]*amiRequest), status: models.AmiClientStatus{Pbx: pbx.Name}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.send(goami2.NewAction("Ping"), time.Second)
+			errs <- err
+		}()
+	}
+
+	// mientras se espera el login el estado se puede leer
+	time.Sleep(100 * time.Millisecond)
+	locked := make(chan struct{})
+	go func() {
+		c.mu.Lock()
+		c.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("client lock is held while logging in")
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err == nil {
+			t.Error("send without a session should fail")
+		}
+	}
+	if n := accepted.Load(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+	if c.status.LastError == "" || c.dialing != nil {
+		t.Errorf("failed login should be recorded and the dial cleared: %+v", c.status)
+	}
+}
@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+const (
+	defaultAmiWorkers     = 8
+	defaultAmiWorkerQueue = 1000
+)
+
+// amiDispatcher reparte los eventos entre workers, todos los eventos de un mismo
+// Linkedid van al mismo worker por lo que se procesan en el orden en que llegaron
+// y las llamadas distintas se procesan en paralelo. La consulta de una transferencia
+// atendida conserva su Linkedid y su worker, el AttendedTransfer se encola en los workers
+// de la llamada original y de la consulta y se procesa cuando todos llegan a el, asi el
+// alias existe antes de los eventos siguientes de la consulta
+type amiDispatcher struct {
+	db      models.ConnMysql
+	queues  []chan amiDispatchItem
+	joinMu  sync.Mutex // los eventos encolados en varios workers entran en el mismo orden en todos
+	stats   amiDispatchCounters
+	started time.Time
+}
+
+type amiDispatchItem struct {
+	pbx        string
+	msg        *goami2.Message
+	receivedAt time.Time
+	join       *amiJoin // nil si el evento va a un solo worker
+}
+
+// amiJoin evento encolado en varios workers, el ultimo worker en llegar lo procesa y
+// los demas esperan a que termine antes de seguir con su cola
+type amiJoin struct {
+	pending atomic.Int32
+	done    chan struct{}
+}
+
+// arrive true si es el ultimo worker en llegar
+func (j *amiJoin) arrive() bool {
+	return j.pending.Add(-1) == 0
+}
+
+type amiDispatchCounters struct {
+	processed   atomic.Int64
+	dropped     atomic.Int64
+	panics      atomic.Int64
+	waitNanos   atomic.Int64 // tiempo total en cola
+	handleNanos atomic.Int64 // tiempo total en handleEvent
+	maxHandle   atomic.Int64
+}
+
+// dispatcher global, se crea una sola vez y sobrevive a las reconexiones AMI
+var dispatcher *amiDispatcher
+
+// newAmiDispatcher inicia los workers, AMI_WORKERS cantidad de workers y
+// AMI_WORKER_QUEUE eventos en cola por worker antes de descartar
+func newAmiDispatcher(db models.ConnMysql) *amiDispatcher {
+	workers := envInt("AMI_WORKERS", defaultAmiWorkers)
+	queueSize := envInt("AMI_WORKER_QUEUE", defaultAmiWorkerQueue)
+
+	d := &amiDispatcher{db: db, queues: make([]chan amiDispatchItem, workers), started: time.Now()}
+	for i := range d.queues {
+		d.queues[i] = make(chan amiDispatchItem, queueSize)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// envDuration variable en formato duracion de go, ej: 15m, 30s
+func envDuration(name string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// Dispatch encola el evento en el worker de su llamada, nunca bloquea la lectura AMI
+func (d *amiDispatcher) Dispatch(pbx string, msg *goami2.Message, receivedAt time.Time) {
+	if msg.Field("Event") == "AttendedTransfer" && d.dispatchJoined(pbx, msg, receivedAt) {
+		return
+	}
+	queue := d.queues[d.shard(dispatchKey(msg))]
+	select {
+	case queue <- amiDispatchItem{pbx: pbx, msg: msg, receivedAt: receivedAt}:
+	default:
+		if d.stats.dropped.Add(1)%100 == 1 {
+			utils.Logline("ami worker queue is full, dropping events", pbx, d.stats.dropped.Load(), msg)
+		}
+	}
+}
+
+// dispatchKey Linkedid de la llamada del evento, los eventos de transferencia no
+// traen Linkedid sino el de cada parte. El OriginateResponse de una click-to-call usa
+// como ActionID el ChannelId de la llamada, asi va al mismo worker que sus canales.
+// Se usa el Linkedid tal cual llega: el alias de una transferencia atendida se crea
+// despues en un worker, si se resolviera aqui los eventos de la consulta cambiarian de
+// worker a mitad de la llamada. El alias se resuelve en handleEvent y el AttendedTransfer
+// que lo crea tambien pasa por el worker de la consulta (ver dispatchJoined)
+func dispatchKey(msg *goami2.Message) string {
+	if msg.Field("Event") == "OriginateResponse" {
+		return msg.ActionID()
+	}
+	for _, field := range []string{"Linkedid", "TransfereeLinkedid", "OrigTransfererLinkedid", "TransfererLinkedid"} {
+		if linkedId := msg.Field(field); linkedId != "" {
+			return linkedId
+		}
+	}
+	return ""
+}
+
+// dispatchJoined encola el AttendedTransfer en los workers de todos sus Linkedid, false si
+// todos caen en el mismo worker y basta el camino normal
+func (d *amiDispatcher) dispatchJoined(pbx string, msg *goami2.Message, receivedAt time.Time) bool {
+	var shards []int
+	seen := make(map[int]bool)
+	for _, field := range []string{"TransfereeLinkedid", "OrigTransfererLinkedid", "SecondTransfererLinkedid", "TransferTargetLinkedid"} {
+		if linkedId := msg.Field(field); linkedId != "" && !seen[d.shard(linkedId)] {
+			seen[d.shard(linkedId)] = true
+			shards = append(shards, d.shard(linkedId))
+		}
+	}
+	if len(shards) < 2 {
+		return false
+	}
+
+	// con el lock dos transferencias no quedan cruzadas entre workers, si una cola esta
+	// llena se descarta como cualquier evento
+	d.joinMu.Lock()
+	defer d.joinMu.Unlock()
+	for _, shard := range shards {
+		if len(d.queues[shard]) == cap(d.queues[shard]) {
+			d.stats.dropped.Add(1)
+			utils.Logline("ami worker queue is full, dropping events", pbx, d.stats.dropped.Load(), msg)
+			return true
+		}
+	}
+	join := &amiJoin{done: make(chan struct{})}
+	join.pending.Store(int32(len(shards)))
+	for _, shard := range shards {
+		d.queues[shard] <- amiDispatchItem{pbx: pbx, msg: msg, receivedAt: receivedAt, join: join}
+	}
+	return true
+}
+
+func (d *amiDispatcher) shard(key string) int {
+	if key == "" {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+func (d *amiDispatcher) work(queue chan amiDispatchItem) {
+	for item := range queue {
+		if item.join != nil && !item.join.arrive() {
+			<-item.join.done
+			continue
+		}
+		start := time.Now()
+		d.handle(item.pbx, item.msg)
+		if item.join != nil {
+			close(item.join.done)
+		}
+		handle := time.Since(start).Nanoseconds()
+
+		d.stats.processed.Add(1)
+		d.stats.waitNanos.Add(start.Sub(item.receivedAt).Nanoseconds())
+		d.stats.handleNanos.Add(handle)
+		for {
+			max := d.stats.maxHandle.Load()
+			if handle <= max || d.stats.maxHandle.CompareAndSwap(max, handle) {
+				break
+			}
+		}
+	}
+}
+
+// handle un panic en un evento no debe detener el worker
+func (d *amiDispatcher) handle(pbx string, msg *goami2.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.stats.panics.Add(1)
+			utils.Logline("Recovered from panic <<ami_worker>>: %v", r, msg)
+		}
+	}()
+	handleEvent(d.db, pbx, msg)
+}
+
+// Stats metricas de los workers para el endpoint de estado
+func (d *amiDispatcher) Stats() models.AmiDispatchStats {
+	stats := models.AmiDispatchStats{
+		Workers:      len(d.queues),
+		Processed:    d.stats.processed.Load(),
+		Dropped:      d.stats.dropped.Load(),
+		Panics:       d.stats.panics.Load(),
+		MaxHandleMs:  float64(d.stats.maxHandle.Load()) / float64(time.Millisecond),
+		QueueDepth:   make([]int, len(d.queues)),
+		RunningSince: d.started,
+	}
+	for i, queue := range d.queues {
+		stats.QueueCapacity = cap(queue)
+		stats.QueueDepth[i] = len(queue)
+		stats.QueueTotal += len(queue)
+	}
+	if stats.Processed > 0 {
+		stats.AvgWaitMs = float64(d.stats.waitNanos.Load()) / float64(stats.Processed) / float64(time.Millisecond)
+		stats.AvgHandleMs = float64(d.stats.handleNanos.Load()) / float64(stats.Processed) / float64(time.Millisecond)
+	}
+	return stats
+}
@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+func TestDispatchKeyIgnoresAliases(t *testing.T) {
+	tracker := callTracker
+	callTracker = newCallRegistry()
+	t.Cleanup(func() { callTracker = tracker })
+
+	consult := goami2.NewMessage()
+	consult.AddField("Event", "Hangup")
+	consult.AddField("Linkedid", "200.1")
+
+	d := &amiDispatcher{queues: make([]chan amiDispatchItem, 8)}
+	before := d.shard(dispatchKey(consult))
+
+	// el alias lo crea un worker al procesar el AttendedTransfer
+	callTracker.Alias("central", "200.1", "100.1")
+	if key := dispatchKey(consult); key != "200.1" {
+		t.Errorf("dispatchKey = %s, want the raw Linkedid 200.1", key)
+	}
+	if after := d.shard(dispatchKey(consult)); after != before {
+		t.Errorf("consult leg moved from worker %d to %d", before, after)
+	}
+
+	transfer := goami2.NewMessage()
+	transfer.AddField("Event", "AttendedTransfer")
+	transfer.AddField("TransfereeLinkedid", "100.1")
+	transfer.AddField("SecondTransfererLinkedid", "200.1")
+	if key := dispatchKey(transfer); key != "100.1" {
+		t.Errorf("dispatchKey = %s, want 100.1", key)
+	}
+}
+
+// el AttendedTransfer pasa por los workers de la llamada y de la consulta, los eventos
+// siguientes de la consulta esperan a que se cree el alias
+func TestDispatchAttendedTransferJoinsWorkers(t *testing.T) {
+	db, _ := newFakeMysql(t, nil)
+	d := &amiDispatcher{db: db, queues: make([]chan amiDispatchItem, 8)}
+	for i := range d.queues {
+		d.queues[i] = make(chan amiDispatchItem, 10)
+	}
+
+	// Linkedid de la consulta en otro worker que la llamada original
+	original, consult := "100.1", ""
+	for i := 2; consult == ""; i++ {
+		if id := fmt.Sprintf("200.%d", i); d.shard(id) != d.shard(original) {
+			consult = id
+		}
+	}
+
+	transfer := goami2.NewMessage()
+	transfer.AddField("Event", "AttendedTransfer")
+	transfer.AddField("TransfereeLinkedid", original)
+	transfer.AddField("SecondTransfererLinkedid", consult)
+	hangup := goami2.NewMessage()
+	hangup.AddField("Event", "Hangup")
+	hangup.AddField("Linkedid", consult)
+
+	d.Dispatch("central", transfer, time.Now())
+	d.Dispatch("central", hangup, time.Now())
+
+	first := <-d.queues[d.shard(original)]
+	joined := <-d.queues[d.shard(consult)]
+	if first.join == nil || first.join != joined.join {
+		t.Fatal("transfer should be queued on both workers with the same join")
+	}
+	if next := <-d.queues[d.shard(consult)]; next.msg != hangup {
+		t.Fatal("consult hangup should wait behind the transfer")
+	}
+
+	// con los workers corriendo el evento se procesa una sola vez y ninguna cola se traba
+	d.queues[d.shard(original)] <- first
+	d.queues[d.shard(consult)] <- joined
+	for _, queue := range d.queues {
+		go d.work(queue)
+		t.Cleanup(func() { close(queue) })
+	}
+	deadline := time.Now().Add(time.Second)
+	for d.stats.processed.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("processed = %d, want 1", d.stats.processed.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
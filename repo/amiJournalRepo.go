@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+const (
+	amiJournalFile   = "logs/ami-events.log"
+	amiJournalBuffer = 4096 // eventos en cola antes de descartar, el listener no puede bloquearse
+)
+
+// journal de eventos AMI crudos, un objeto JSON por linea
+type amiJournal struct {
+	writer  *lumberjack.Logger
+	entries chan []byte
+	include map[string]bool // si no esta vacio solo se guardan estos eventos
+	exclude map[string]bool
+	dropped atomic.Int64
+}
+
+var journal *amiJournal
+
+// StartAmiJournal abre el journal de eventos, debe llamarse antes de iniciar el listener
+// AMI_JOURNAL_EVENTS lista de eventos a guardar separados por coma, vacio guarda todos
+// AMI_JOURNAL_EXCLUDE_EVENTS lista de eventos que nunca se guardan
+func StartAmiJournal() {
+	journal = &amiJournal{
+		writer: &lumberjack.Logger{
+			Filename:   amiJournalFile,
+			MaxSize:    100,  // megabytes
+			MaxBackups: 30,   // Keep logs for a month
+			Compress:   true, // disabled by default
+		},
+		entries: make(chan []byte, amiJournalBuffer),
+		include: eventSet(os.Getenv("AMI_JOURNAL_EVENTS")),
+		exclude: eventSet(os.Getenv("AMI_JOURNAL_EXCLUDE_EVENTS")),
+	}
+	go journal.run()
+}
+
+func eventSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, event := range strings.Split(list, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			set[strings.ToLower(event)] = true
+		}
+	}
+	return set
+}
+
+// escribe las entradas en orden y rota el archivo diariamente
+func (j *amiJournal) run() {
+	rotate := time.NewTicker(24 * time.Hour)
+	defer rotate.Stop()
+	for {
+		select {
+		case entry := <-j.entries:
+			if _, err := j.writer.Write(entry); err != nil {
+				utils.Logline("failed to write ami journal", err)
+			}
+		case <-rotate.C:
+			j.writer.Rotate()
+		}
+	}
+}
+
+// journalEvent encola el evento recibido de la central, nunca bloquea al listener
+func journalEvent(msg *goami2.Message, pbx models.Pbx, receivedAt time.Time) {
+	if journal == nil || !msg.IsEvent() {
+		return
+	}
+	event := strings.ToLower(msg.Field("Event"))
+	if journal.exclude[event] || (len(journal.include) > 0 && !journal.include[event]) {
+		return
+	}
+
+	entry, err := json.Marshal(struct {
+		ReceivedAt time.Time       `json:"received_at"`
+		Pbx        string          `json:"pbx"`
+		Server     string          `json:"server"`
+		Event      json.RawMessage `json:"event"`
+	}{receivedAt, pbx.Name, pbx.Server, json.RawMessage(msg.JSON())})
+	if err != nil {
+		return
+	}
+
+	select {
+	case journal.entries <- append(entry, '\n'):
+	default:
+		if journal.dropped.Add(1) == 1 {
+			utils.Logline("ami journal buffer is full, dropping events")
+		}
+	}
+}
+
+// AmiJournalDropped cantidad de eventos descartados por el journal
+func AmiJournalDropped() int64 {
+	if journal == nil {
+		return 0
+	}
+	return journal.dropped.Load()
+}
+
+// AmiJournalByLinkedid secuencia de eventos de una llamada en los ultimos dias, incluye
+// los eventos donde el Linkedid aparece en otro campo, ej: TransfereeLinkedid
+func AmiJournalByLinkedid(linkedId string, days int) ([]models.AmiJournalEntry, error) {
+	files, err := filepath.Glob(strings.TrimSuffix(amiJournalFile, ".log") + "*.log*")
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	needle := []byte(`"` + linkedId + `"`)
+	events := []models.AmiJournalEntry{}
+
+	for _, file := range files {
+		// un archivo rotado antes del rango no puede tener eventos del rango
+		info, err := os.Stat(file)
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+
+		found, err := scanJournalFile(file, linkedId, needle, since)
+		if err != nil {
+			utils.Logline("error reading ami journal", file, err)
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+
+	sort.SliceStable(events, func(i, k int) bool { return events[i].ReceivedAt.Before(events[k].ReceivedAt) })
+	return events, nil
+}
+
+func scanJournalFile(file string, linkedId string, needle []byte, since time.Time) ([]models.AmiJournalEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var events []models.AmiJournalEntry
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.Contains(line, needle) {
+			continue
+		}
+
+		var entry models.AmiJournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.ReceivedAt.Before(since) {
+			continue
+		}
+		for key, value := range entry.Event {
+			if strings.HasSuffix(key, "linkedid") && value == linkedId {
+				events = append(events, entry)
+				break
+			}
+		}
+	}
+
+	return events, scanner.Err()
+}
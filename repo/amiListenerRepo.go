@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+const (
+	amiBackoffMin    = 1 * time.Second  // primera espera despues de una sesion caida
+	amiBackoffMax    = 60 * time.Second // limite del backoff exponencial
+	amiStableSession = 2 * time.Minute  // una sesion que dura esto reinicia el backoff
+	amiPingInterval  = 30 * time.Second // keepalive enviado a asterisk
+	amiIdleTimeout   = 90 * time.Second // sin mensajes en este tiempo el socket esta muerto
+)
+
+// estado del listener de cada central, compartido con el endpoint de administracion
+var listenerMu sync.RWMutex
+var listenerStatus = make(map[string]*models.AmiListenerStatus)
+var listenerOrder []string
+
+// los workers son compartidos por todas las centrales
+var dispatcherOnce sync.Once
+
+// AmiListener mantiene abierta una unica sesion AMI con la central para leer eventos,
+// si la sesion se cae se reconecta con backoff exponencial y jitter. Nunca retorna,
+// debe ejecutarse en su propia goroutine, una por central. El tracking de llamadas
+// vive en memoria del paquete por lo que sobrevive a las reconexiones y reinicios de
+// asterisk. La lectura no espera a mysql, los eventos se procesan en los workers de
+// amiDispatchRepo.go
+func AmiListener(db models.ConnMysql, pbx models.Pbx) {
+	backoff := amiBackoffMin
+	listenerMu.Lock()
+	listenerStatus[pbx.Name] = &models.AmiListenerStatus{Pbx: pbx.Name, Server: pbx.Server}
+	listenerOrder = append(listenerOrder, pbx.Name)
+	dispatcherOnce.Do(func() { dispatcher = newAmiDispatcher(db) })
+	listenerMu.Unlock()
+
+	for {
+		startedAt := time.Now()
+		err := runAmiSession(db, pbx)
+		setListenerDisconnected(pbx.Name, err)
+		extensionStates.invalidate(pbx.Name)
+		queueMembers.invalidate(pbx.Name)
+
+		// si la sesion fue estable volvemos a empezar desde el backoff minimo
+		if time.Since(startedAt) >= amiStableSession {
+			backoff = amiBackoffMin
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		utils.Logline("ami listener disconnected, retrying in", pbx.Name, wait.String(), err)
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > amiBackoffMax {
+			backoff = amiBackoffMax
+		}
+	}
+}
+
+// GetAmiListenerStatus retorna una copia del estado actual de las sesiones y los workers
+func GetAmiListenerStatus() models.AmiServiceStatus {
+	listenerMu.RLock()
+	defer listenerMu.RUnlock()
+
+	status := models.AmiServiceStatus{Sessions: []models.AmiListenerStatus{}, Clients: AmiClientsStatus(), Subscribers: EventBusStats(), JournalDropped: AmiJournalDropped(),
+		Webhooks: WebhookStats()}
+	for _, name := range listenerOrder {
+		status.Sessions = append(status.Sessions, *listenerStatus[name])
+	}
+	if dispatcher != nil {
+		stats := dispatcher.Stats()
+		status.Dispatch = &stats
+	}
+	return status
+}
+
+// abre una sesion AMI (login incluido) y procesa eventos hasta que la conexion falle
+func runAmiSession(db models.ConnMysql, pbx models.Pbx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.Logline("Recovered from panic <<ami_listener>>: %v", r)
+			err = fmt.Errorf("panic on ami session: %v", r)
+		}
+	}()
+
+	clientAmi, err := utils.ConnectToAmi(pbx)
+	if err != nil {
+		return err
+	}
+	defer clientAmi.Close()
+
+	reconnect := setListenerConnected(pbx.Name)
+	utils.Logline("Starting AMI events service", pbx.Name)
+
+	// los eventos de estado ya llegan por esta sesion, la carga inicial va por el cliente de acciones
+	go seedExtensionStates(pbx)
+	go seedQueueMembers(pbx)
+
+	// reconstruir el tracking de las llamadas en curso antes de leer nuevos eventos, en una
+	// reconexion solo las que no estan en memoria
+	pending, err := recoverCalls(db, clientAmi, pbx, reconnect)
+	if err != nil {
+		utils.Logline("failed to recover calls in progress", pbx.Name, err)
+	}
+	for _, msg := range pending {
+		journalEvent(msg, pbx, time.Now())
+		dispatcher.Dispatch(pbx.Name, msg, time.Now())
+	}
+
+	ping := time.NewTicker(amiPingInterval)
+	defer ping.Stop()
+	lastMsg := time.Now()
+
+	for {
+		select {
+		case msg, ok := <-clientAmi.AllMessages():
+			if !ok {
+				return fmt.Errorf("ami connection closed")
+			}
+			if msg != nil {
+				lastMsg = time.Now()
+				setListenerLastEvent(pbx.Name, lastMsg)
+				journalEvent(msg, pbx, lastMsg)
+				dispatcher.Dispatch(pbx.Name, msg, lastMsg)
+			}
+		case err := <-clientAmi.Err():
+			// un paquete que goami2 no pudo parsear no invalida la sesion
+			if !amiConnectionLost(err) {
+				utils.Logline("failed to parse ami message", pbx.Name, err)
+				continue
+			}
+			utils.Logline("error on ami", pbx.Name, err)
+			return fmt.Errorf("ami session error: %v", err)
+		case <-ping.C:
+			if time.Since(lastMsg) > amiIdleTimeout {
+				return fmt.Errorf("ami session idle for more than %s", amiIdleTimeout)
+			}
+			if err := clientAmi.MustSend(goami2.NewAction("Ping").Byte()); err != nil {
+				return fmt.Errorf("ami ping failed: %v", err)
+			}
+		}
+	}
+}
+
+// amiConnectionLost indica si el error de goami2 es de la conexion (lectura fallida,
+// cierre o error de red), los de protocolo son paquetes que no se pudieron parsear
+func amiConnectionLost(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, goami2.ErrEOF) || errors.Is(err, goami2.ErrConn) || errors.As(err, &netErr)
+}
+
+// setListenerConnected marca la sesion como conectada, true si es una reconexion
+func setListenerConnected(name string) bool {
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	status := listenerStatus[name]
+	now := time.Now()
+	reconnect := status.SessionCount > 0
+	if reconnect {
+		status.ReconnectCount++
+	}
+	status.SessionCount++
+	status.Connected = true
+	status.ConnectedSince = &now
+	return reconnect
+}
+
+func setListenerDisconnected(name string, err error) {
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	status := listenerStatus[name]
+	now := time.Now()
+	status.Connected = false
+	status.ConnectedSince = nil
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = &now
+	}
+}
+
+func setListenerLastEvent(name string, t time.Time) {
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	listenerStatus[name].LastEventAt = &t
+}
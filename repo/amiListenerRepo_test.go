@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/staskobzar/goami2"
+)
+
+// solo los errores de conexion cierran la sesion, un paquete invalido se descarta
+func TestAmiConnectionLost(t *testing.T) {
+	_, parseErr := goami2.Parse("invalid packet\r\n\r\n")
+	if parseErr == nil {
+		t.Fatal("invalid packet should not parse")
+	}
+
+	cases := []struct {
+		err  error
+		lost bool
+	}{
+		{parseErr, false},
+		{goami2.ErrEOF, true},
+		{fmt.Errorf("%w: failed read: %s", goami2.ErrEOF, io.EOF), true},
+		{fmt.Errorf("%w: write", goami2.ErrConn), true},
+	}
+	for _, c := range cases {
+		if lost := amiConnectionLost(c.err); lost != c.lost {
+			t.Errorf("amiConnectionLost(%v) = %v, want %v", c.err, lost, c.lost)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+)
+
+// AmiReplayEvent evento grabado, la central y el momento en que se recibio. At es
+// cero si la grabacion no tiene marca de tiempo y Pbx vacio si no indica la central
+type AmiReplayEvent struct {
+	At  time.Time
+	Pbx string
+	Msg *goami2.Message
+}
+
+// ReadAmiEvents lee eventos grabados en cualquiera de estos formatos:
+// - JSON lines del journal (logs/ami-events.log) o un objeto por linea como Message.JSON()
+// - volcado del protocolo AMI, lineas "Campo: valor" y eventos separados por una linea vacia
+func ReadAmiEvents(r io.Reader) ([]AmiReplayEvent, error) {
+	reader := bufio.NewReader(r)
+	first, err := reader.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if first[0] == '{' {
+		return readAmiJsonLines(scanner)
+	}
+	return readAmiDump(scanner)
+}
+
+func readAmiJsonLines(scanner *bufio.Scanner) ([]AmiReplayEvent, error) {
+	var events []AmiReplayEvent
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		// las lineas del journal traen el evento dentro de "event"
+		var entry struct {
+			ReceivedAt time.Time       `json:"received_at"`
+			Pbx        string          `json:"pbx"`
+			Event      json.RawMessage `json:"event"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		raw := line
+		if len(entry.Event) > 0 {
+			raw = string(entry.Event)
+		}
+
+		msg, err := goami2.FromJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		at := entry.ReceivedAt
+		if at.IsZero() {
+			at = amiTimestamp(msg)
+		}
+		events = append(events, AmiReplayEvent{At: at, Pbx: entry.Pbx, Msg: msg})
+	}
+	return events, scanner.Err()
+}
+
+func readAmiDump(scanner *bufio.Scanner) ([]AmiReplayEvent, error) {
+	var events []AmiReplayEvent
+	msg := goami2.NewMessage()
+	flush := func() {
+		if msg.Len() > 0 {
+			events = append(events, AmiReplayEvent{At: amiTimestamp(msg), Msg: msg})
+		}
+		msg = goami2.NewMessage()
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			flush()
+			continue
+		}
+		// ignora el saludo "Asterisk Call Manager/x.y" y lineas sin campo
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.ContainsAny(name, " /") {
+			continue
+		}
+		msg.AddField(name, strings.TrimSpace(value))
+	}
+	flush()
+	return events, scanner.Err()
+}
+
+// amiTimestamp campo Timestamp que agrega asterisk con timestampevents=yes
+func amiTimestamp(msg *goami2.Message) time.Time {
+	ts, err := strconv.ParseFloat(msg.Field("Timestamp"), 64)
+	if err != nil || ts <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ts*float64(time.Second)))
+}
+
+// ReplayAmiEvents pasa los eventos por el mismo handleEvent del listener. speed 1
+// respeta los tiempos originales, 10 es diez veces mas rapido y 0 no espera. pbx es
+// la central de los eventos que no la indican
+func ReplayAmiEvents(db models.ConnMysql, events []AmiReplayEvent, speed float64, pbx string) models.AmiReplayReport {
+	report := models.AmiReplayReport{StartedAt: time.Now(), ByEvent: make(map[string]int)}
+
+	var prev time.Time
+	for _, event := range events {
+		if !event.Msg.IsEvent() {
+			report.Skipped++
+			continue
+		}
+		if speed > 0 && !prev.IsZero() && event.At.After(prev) {
+			time.Sleep(time.Duration(float64(event.At.Sub(prev)) / speed))
+		}
+		if !event.At.IsZero() {
+			prev = event.At
+		}
+
+		eventPbx := event.Pbx
+		if eventPbx == "" {
+			eventPbx = pbx
+		}
+		handleEvent(db, eventPbx, event.Msg)
+		report.Events++
+		report.ByEvent[event.Msg.Field("Event")]++
+	}
+
+	report.FinishedAt = time.Now()
+	report.Calls = callTracker.List()
+	return report
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"os"
+	"strings"
+	"testing"
+
+	"ired.com/callcenter/models"
+)
+
+// la saliente grabada en testdata pasa por handleEvent como en el listener
+func TestReplayOutboundCall(t *testing.T) {
+	if _, err := LoadCallRules("../callrules_example.json"); err != nil {
+		t.Fatal(err)
+	}
+	tracker := callTracker
+	callTracker = newCallRegistry()
+	t.Cleanup(func() {
+		callTracker = tracker
+		activeRules.Store(mustCompileDefaultRules())
+	})
+
+	file, err := os.Open("../testdata/ami/outbound_call.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, err := ReadAmiEvents(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(query, "FROM agent"):
+			return []string{"id"}, [][]driver.Value{{int64(7)}}
+		case strings.Contains(query, "SELECT id FROM calls"):
+			return []string{"id"}, [][]driver.Value{{"42"}}
+		}
+		return nil, nil
+	})
+
+	report := ReplayAmiEvents(db, events, 0, "default")
+	if report.Events != 7 || report.ByEvent["BridgeEnter"] != 2 {
+		t.Errorf("unexpected replay report: %+v", report)
+	}
+
+	call, ok := callTracker.Get("central", "1760781600.100")
+	if !ok {
+		t.Fatal("outbound call was not tracked")
+	}
+	if call.State != models.CallCompleted || call.AnsweredAt == nil || call.EndedAt == nil {
+		t.Errorf("call should be answered and completed: %+v", call)
+	}
+	if call.Pbx != "central" || call.Agent != "8001" || call.Direction != models.DirectionOutbound {
+		t.Errorf("unexpected call data: %+v", call)
+	}
+	if call.Hangup == nil || call.Hangup.By != models.HangupByAgent || call.Hangup.Cause != 16 {
+		t.Errorf("unexpected hangup: %+v", call.Hangup)
+	}
+	if outcome := callOutcome(call); outcome != models.OutcomeAnswered {
+		t.Errorf("outcome = %s, want %s", outcome, models.OutcomeAnswered)
+	}
+
+	// la llamada se inserta, se atiende y se cierra en calls
+	inserts := fake.Execs("INSERT INTO calls")
+	if len(inserts) != 1 || inserts[0].Args[1] != "8001" || inserts[0].Args[2] != "1760781600.100" || inserts[0].Args[4] != "04141234567" {
+		t.Errorf("unexpected calls insert: %+v", inserts)
+	}
+	if len(fake.Execs("UPDATE calls SET status = 'Active'")) != 1 {
+		t.Error("call should be marked as answered")
+	}
+	if len(fake.Execs("UPDATE calls SET status = 'Finalizada'")) != 1 {
+		t.Error("call should be closed as Finalizada")
+	}
+	if len(fake.Execs("DELETE cc FROM current_calls")) != 1 {
+		t.Error("call should be removed from current_calls")
+	}
+}
+
+// la entrante que sale de la cola por tiempo de espera termina al colgar el cliente
+func TestReplayInboundQueueTimeout(t *testing.T) {
+	if _, err := LoadCallRules("../callrules_example.json"); err != nil {
+		t.Fatal(err)
+	}
+	tracker := callTracker
+	callTracker = newCallRegistry()
+	t.Cleanup(func() {
+		callTracker = tracker
+		activeRules.Store(mustCompileDefaultRules())
+	})
+
+	file, err := os.Open("../testdata/ami/inbound_queue_timeout.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, err := ReadAmiEvents(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(query, "SELECT id FROM calls"):
+			return []string{"id"}, [][]driver.Value{{"42"}}
+		case strings.Contains(query, "SELECT id, phone FROM calls"):
+			return []string{"id", "phone"}, [][]driver.Value{{int64(42), "04141234567"}}
+		}
+		return nil, nil
+	})
+
+	ReplayAmiEvents(db, events, 0, "default")
+
+	call, ok := callTracker.Get("central", "1760785200.200")
+	if !ok {
+		t.Fatal("inbound call was not tracked")
+	}
+	if call.State != models.CallNoAnswer || call.EndedAt == nil || call.Outcome != models.OutcomeAbandoned {
+		t.Errorf("call should end unanswered: %+v", call)
+	}
+	if closed := fake.Execs("status = 'Sin respuesta'"); len(closed) != 1 {
+		t.Errorf("call should be closed in calls: %+v", closed)
+	}
+	if deleted := fake.Execs("DELETE cc FROM current_calls"); len(deleted) != 1 {
+		t.Errorf("current_call should be deleted: %+v", deleted)
+	}
+	if callbacks := fake.Execs("INSERT INTO call_callbacks"); len(callbacks) != 1 {
+		t.Errorf("unanswered call should get a callback: %+v", callbacks)
+	}
+}
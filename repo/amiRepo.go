@@ -1,55 +1,72 @@
 package repo
 
 import (
-	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
 	"ired.com/callcenter/utils"
 )
 
 func HangupCall(ext string) error {
-	// Connect to Asterisk AMI
-	clientAmi, err := utils.ConnectToAmi()
+	// la accion va a la central dueña de la extension
+	pbx, err := utils.PbxForExtension(ext)
 	if err != nil {
 		return err
 	}
-	defer clientAmi.Close()
 
 	// hangup-call
 	action := goami2.NewAction("Hangup")
-	action.SetField("Channel", fmt.Sprintf("/^SIP/%s-.*$/", ext)) // Replace with your extension number
-	actionID := fmt.Sprintf("hangupcall-%d", time.Now().Unix())
-	action.SetField("ActionID", actionID)
-
-	// Send the action
-	clientAmi.Send(action.Byte())
-
-	// create context
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	// Listen for responses
-	for {
-		select {
-		case msg := <-clientAmi.AllMessages():
-			if msg.Field("Event") == "Hangup" {
-				utils.Logline("extension colgada con exito", ext)
-				return nil
-			}
-
-			// Break the loop if no channel its found
-			if msg.Field("Message") == "No such channel" {
-				return fmt.Errorf("no existe un canal abierto en la extension %s", ext)
-			}
-
-		case <-ctx.Done():
-			return fmt.Errorf("timeout waiting for hangup call")
-
-		case err := <-clientAmi.Err():
-			utils.Logline("error on ami", err)
-			return fmt.Errorf("an error occurred executing ami command")
-		}
+	action.SetField("Channel", utils.ExtensionChannel(pbx, ext).Regex()) // todos los canales de la extension
+
+	res, err := amiAction(pbx, action, 2*time.Second)
+	if res.Response != nil && res.Response.Field("Message") == "No such channel" {
+		return fmt.Errorf("no existe un canal abierto en la extension %s", ext)
+	}
+	if err != nil {
+		utils.Logline("error on ami", err)
+		return fmt.Errorf("an error occurred executing ami command")
+	}
+
+	utils.Logline("extension colgada con exito", ext)
+	return nil
+}
+
+// queueOriginate Originate asincrono, asterisk solo confirma que encolo la accion y el
+// resultado llega despues en el evento OriginateResponse con el mismo ActionID
+func queueOriginate(pbx models.Pbx, originate models.Originate, actionId string) error {
+	action := originateAction(pbx, originate)
+	action.SetField("Async", "true")
+	action.SetField("ActionID", actionId)
+
+	_, err := amiAction(pbx, action, 5*time.Second)
+	return err
+}
+
+func originateAction(pbx models.Pbx, originate models.Originate) *goami2.Message {
+	action := goami2.NewAction("Originate")
+	action.SetField("Channel", utils.ExtensionChannel(pbx, originate.Agent).Dial())
+	action.SetField("Context", originate.Context)
+	action.SetField("Exten", originate.Phone)
+	action.SetField("Priority", "1")
+	action.SetField("CallerID", originate.CallerId)
+	action.SetField("Timeout", strconv.FormatInt(originate.RingTimeout.Milliseconds(), 10))
+	action.SetField("ChannelId", originate.ChannelId)
+	if originate.AccountCode != "" {
+		action.SetField("Account", originate.AccountCode)
+	}
+
+	// una cabecera Variable por cada variable, ordenadas por nombre
+	names := make([]string, 0, len(originate.Variables))
+	for name := range originate.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		action.AddField("Variable", name+"="+originate.Variables[name])
 	}
+	return action
 }
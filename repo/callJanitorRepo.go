@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// edad maxima por defecto de una llamada antes de revisarla contra asterisk
+const defaultCallMaxAge = 4 * time.Hour
+
+// ultimo reporte del janitor, se expone en el endpoint de administracion
+var janitorMu sync.RWMutex
+var lastJanitor *models.CallJanitorReport
+
+// GetCallJanitorReport retorna el resultado de la ultima limpieza de llamadas
+func GetCallJanitorReport() (models.CallJanitorReport, error) {
+	janitorMu.RLock()
+	defer janitorMu.RUnlock()
+	if lastJanitor == nil {
+		return models.CallJanitorReport{}, fmt.Errorf("call janitor has not run yet")
+	}
+	return *lastJanitor, nil
+}
+
+// callMaxAge CALL_MAX_AGE en formato duracion de go, ej: 4h, 90m
+func callMaxAge() time.Duration {
+	maxAge, err := time.ParseDuration(os.Getenv("CALL_MAX_AGE"))
+	if err != nil || maxAge <= 0 {
+		return defaultCallMaxAge
+	}
+	return maxAge
+}
+
+// CallJanitor busca llamadas que superan la edad maxima en memoria y en current_calls,
+// las verifica contra los canales vivos de asterisk y cierra las que ya no existen
+// con el estado 'Cerrada por sistema'. Cada cierre queda en call_janitor_log
+func CallJanitor(db models.ConnMysql) (models.CallJanitorReport, error) {
+	maxAge := callMaxAge()
+	report := models.CallJanitorReport{StartedAt: time.Now(), MaxAge: maxAge.String()}
+	since := report.StartedAt.Add(-maxAge)
+
+	// candidatas en memoria
+	suspects := make(map[callKey]*models.StaleCall)
+	for _, call := range callTracker.List() {
+		if !call.State.IsFinal() && call.StartedAt.Before(since) {
+			suspects[callKey{call.Pbx, call.Linkedid}] = &models.StaleCall{Uniqueid: call.Linkedid, Pbx: call.Pbx, Agent: call.Agent, State: call.State.String(),
+				StartedAt: call.StartedAt, Source: "memory"}
+		}
+	}
+
+	// candidatas en current_calls, solo las campañas de las reglas activas. Se usa el
+	// uniqueid de calls que es el Linkedid de la llamada, igual que en memoria
+	if campaignIds := activeRules.Load().campaignIds(); len(campaignIds) > 0 {
+		query := `SELECT c.uniqueid, c.pbx, cc.agentnum, cc.event, TIMESTAMPDIFF(SECOND, cc.fecha_inicio, NOW())
+			FROM current_calls AS cc
+			INNER JOIN calls AS c ON c.id = cc.id_call
+			WHERE cc.fecha_inicio < NOW() - INTERVAL ? SECOND AND c.id_campaign IN (?` + strings.Repeat(", ?", len(campaignIds)-1) + `)`
+		args := append([]any{int(maxAge.Seconds())}, campaignIds...)
+		rows, err := db.Conn.QueryContext(db.Ctx, query, args...)
+		if err != nil {
+			utils.Logline("error getting stale current_calls", err)
+			return report, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var call models.StaleCall
+			var age int
+			if err := rows.Scan(&call.Uniqueid, &call.Pbx, &call.Agent, &call.State, &age); err != nil {
+				utils.Logline("error scanning stale current_calls", err)
+				return report, err
+			}
+			call.StartedAt = report.StartedAt.Add(-time.Duration(age) * time.Second)
+			call.Uniqueid = callTracker.Resolve(call.Pbx, call.Uniqueid)
+			key := callKey{call.Pbx, call.Uniqueid}
+			if suspect, ok := suspects[key]; ok {
+				suspect.Source = "both"
+				continue
+			}
+			call.Source = "current_calls"
+			suspects[key] = &call
+		}
+		rows.Close()
+	}
+
+	report.Checked = len(suspects)
+	if len(suspects) == 0 {
+		return finishJanitorReport(report), nil
+	}
+
+	// verificar contra la central de cada llamada, una llamada larga pero viva no se toca
+	liveCalls := make(map[string]map[string]bool)
+	for _, call := range suspects {
+		if _, ok := liveCalls[call.Pbx]; ok {
+			continue
+		}
+		live, err := janitorLiveCalls(call.Pbx)
+		if err != nil {
+			utils.Logline("failed to get live channels for call janitor", call.Pbx, err)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", call.Pbx, err))
+		}
+		liveCalls[call.Pbx] = live
+	}
+
+	for key, call := range suspects {
+		// sin respuesta de la central no se puede confirmar que la llamada murio
+		live := liveCalls[call.Pbx]
+		if live == nil || live[call.Uniqueid] {
+			report.StillAlive++
+			continue
+		}
+
+		call.AgeSeconds = int(report.StartedAt.Sub(call.StartedAt).Seconds())
+		if err := closeStaleCall(db, *call); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s/%s: %v", key.pbx, key.linkedId, err))
+			continue
+		}
+		tracked, ok := callTracker.Get(key.pbx, key.linkedId)
+		if !ok {
+			tracked = models.TrackedCall{Linkedid: key.linkedId, Pbx: key.pbx, Agent: call.Agent}
+		}
+		tracked.Outcome = models.OutcomeSystemClosed
+		publishCallEnded(tracked)
+		callTracker.Remove(key.pbx, key.linkedId)
+		report.Closed = append(report.Closed, *call)
+	}
+
+	return finishJanitorReport(report), nil
+}
+
+// janitorLiveCalls Linkedid de los canales vivos en la central, los canales de la consulta
+// de una transferencia atendida mantienen su Linkedid y cuentan para la llamada original
+func janitorLiveCalls(name string) (map[string]bool, error) {
+	pbx, ok := utils.GetPbx(name)
+	if !ok {
+		pbx = utils.DefaultPbx()
+	}
+
+	res, err := amiAction(pbx, goami2.NewAction("CoreShowChannels"), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]bool)
+	for _, msg := range res.Events {
+		if msg.Field("Event") == "CoreShowChannel" {
+			live[msg.Field("Linkedid")] = true
+			live[callTracker.Resolve(name, msg.Field("Linkedid"))] = true
+		}
+	}
+	return live, nil
+}
+
+func finishJanitorReport(report models.CallJanitorReport) models.CallJanitorReport {
+	report.FinishedAt = time.Now()
+	if report.Checked > 0 {
+		utils.Logline(fmt.Sprintf("call janitor: %d checked, %d still alive, %d closed, %d errors",
+			report.Checked, report.StillAlive, len(report.Closed), len(report.Errors)), report)
+	}
+
+	janitorMu.Lock()
+	lastJanitor = &report
+	janitorMu.Unlock()
+	return report
+}
+
+// closeStaleCall cierra la llamada huerfana y registra la limpieza
+func closeStaleCall(db models.ConnMysql, call models.StaleCall) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	query := `UPDATE calls SET status = 'Cerrada por sistema', end_time = NOW(),
+		duration = TIMESTAMPDIFF(SECOND, COALESCE(start_time, fecha_llamada), NOW()), outcome = ?, hangup_by = ?
+		WHERE pbx = ? AND uniqueid = ? AND end_time IS NULL`
+	_, err := db.Conn.ExecContext(ctx, query, models.OutcomeSystemClosed, models.HangupBySystem, call.Pbx, call.Uniqueid)
+	if err != nil {
+		utils.Logline("Failed to close stale call", call, err)
+		return fmt.Errorf("failed to close stale call")
+	}
+
+	query = `DELETE cc FROM current_calls AS cc INNER JOIN calls AS c ON c.id = cc.id_call
+		WHERE c.pbx = ? AND c.uniqueid = ?`
+	_, err = db.Conn.ExecContext(ctx, query, call.Pbx, call.Uniqueid)
+	if err != nil {
+		utils.Logline("Failed to delete stale current_call", call, err)
+		return fmt.Errorf("failed to delete stale current_call")
+	}
+
+	query = `INSERT INTO call_janitor_log (pbx, uniqueid, source, agent, state, age_seconds, datetime_cleanup)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())`
+	_, err = db.Conn.ExecContext(ctx, query, call.Pbx, call.Uniqueid, call.Source, call.Agent, call.State, call.AgeSeconds)
+	if err != nil {
+		utils.Logline("Failed to insert call_janitor_log", call, err)
+	}
+
+	return nil
+}
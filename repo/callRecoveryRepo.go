@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// ultimo reporte de reconciliacion de cada central, se expone en el endpoint de administracion
+var recoveryMu sync.RWMutex
+var lastRecovery = make(map[string]models.CallRecoveryReport)
+
+// GetCallRecoveryReport retorna el ultimo reporte de reconciliacion de llamadas de cada central
+func GetCallRecoveryReport() ([]models.CallRecoveryReport, error) {
+	recoveryMu.RLock()
+	defer recoveryMu.RUnlock()
+	if len(lastRecovery) == 0 {
+		return nil, fmt.Errorf("call recovery has not run yet")
+	}
+	var reports []models.CallRecoveryReport
+	for _, pbx := range utils.PbxList() {
+		if report, ok := lastRecovery[pbx.Name]; ok {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// recoverCalls reconstruye el tracking en memoria a partir de current_calls y lo
+// cruza contra los canales vivos en asterisk (CoreShowChannels). Las filas cuyos
+// canales ya no existen se cierran, las demas se siguen rastreando. Retorna los
+// eventos que llegaron mientras se esperaba la lista de canales para que el
+// listener los procese en orden. Solo se revisan las llamadas de la central, las
+// llamadas sin central pertenecen a la central por defecto.
+// La reconstruccion completa solo ocurre al iniciar el proceso, en una reconexion las
+// llamadas que ya estan en memoria se mantienen y si su canal murio mientras la sesion
+// estaba caida se cierran con los datos de memoria
+func recoverCalls(db models.ConnMysql, clientAmi *goami2.Client, pbx models.Pbx, reconnect bool) ([]*goami2.Message, error) {
+	report := models.CallRecoveryReport{Pbx: pbx.Name, StartedAt: time.Now(), Reconnect: reconnect}
+
+	liveCalls, pending, err := getAmiLiveCalls(clientAmi)
+	if err != nil {
+		return pending, err
+	}
+	report.LiveChannels = len(liveCalls)
+	callTracker.SeedChannels(pbx.Name, liveCalls)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// solo las campañas de las reglas activas, current_calls tambien lo usa el marcador de issabel
+	campaignIds := activeRules.Load().campaignIds()
+	if len(campaignIds) == 0 {
+		return pending, nil
+	}
+	query := `SELECT cc.uniqueid, cc.agentnum, cc.event, LOWER(c.status), c.direction, cc.queue, cc.hold, c.hold_count, c.hold_seconds
+		FROM current_calls AS cc
+		INNER JOIN calls AS c ON c.id = cc.id_call
+		WHERE c.pbx = ? AND c.id_campaign IN (?` + strings.Repeat(", ?", len(campaignIds)-1) + `)`
+	args := append([]any{pbx.Name}, campaignIds...)
+	rows, err := db.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.Logline("error getting current_calls to recover", err)
+		return pending, err
+	}
+	defer rows.Close()
+
+	var calls []models.RecoveredCall
+	for rows.Next() {
+		var call models.RecoveredCall
+		if err := rows.Scan(&call.Uniqueid, &call.Agent, &call.Event, &call.Status, &call.Direction, &call.Queue, &call.Hold, &call.HoldCount, &call.HoldSeconds); err != nil {
+			utils.Logline("error scanning current_calls to recover", err)
+			return pending, err
+		}
+		calls = append(calls, call)
+	}
+	rows.Close()
+	report.RowsChecked = len(calls)
+
+	for _, call := range calls {
+		// el canal sigue vivo en asterisk, se retoma el tracking
+		if liveCalls[call.Uniqueid] > 0 {
+			tracked := models.TrackedCall{Linkedid: call.Uniqueid, Pbx: pbx.Name, Direction: call.Direction, Agent: call.Agent, Queue: call.Queue,
+				HoldCount: call.HoldCount, HoldSeconds: call.HoldSeconds, State: models.CallRinging}
+			if call.Event == "Link" {
+				tracked.State = models.CallActive
+			}
+			if call.Event == "Link" && call.Hold == "S" {
+				now := time.Now()
+				tracked.State = models.CallOnHold
+				tracked.HoldStarted = &now
+			}
+			if callTracker.Restore(tracked) {
+				report.Resumed = append(report.Resumed, call)
+			} else {
+				report.Kept = append(report.Kept, call)
+			}
+			continue
+		}
+
+		// el canal ya no existe, se cierra la llamada con la hora actual
+		if _, ok := callTracker.Get(pbx.Name, call.Uniqueid); ok {
+			from, to, err := callTracker.Fire(pbx.Name, call.Uniqueid, models.EventHangup)
+			if err == nil && to.IsFinal() && !from.IsFinal() {
+				if err := endCall(db, pbx.Name, nil, call.Uniqueid, to); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", call.Uniqueid, err))
+					continue
+				}
+				report.Closed = append(report.Closed, call)
+				continue
+			}
+		}
+		state := models.CallNoAnswer
+		if call.Status == "active" {
+			state = models.CallCompleted
+		}
+		if err := finishCall(db, pbx.Name, call.Uniqueid, state); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", call.Uniqueid, err))
+			continue
+		}
+		callTracker.Remove(pbx.Name, call.Uniqueid)
+		report.Closed = append(report.Closed, call)
+	}
+
+	report.FinishedAt = time.Now()
+	utils.Logline(fmt.Sprintf("calls recovery %s: %d live channels, %d current_calls checked, %d resumed, %d kept, %d closed, %d errors",
+		pbx.Name, report.LiveChannels, report.RowsChecked, len(report.Resumed), len(report.Kept), len(report.Closed), len(report.Errors)), report)
+
+	recoveryMu.Lock()
+	lastRecovery[pbx.Name] = report
+	recoveryMu.Unlock()
+
+	return pending, nil
+}
+
+// getAmiLiveCalls envia CoreShowChannels por la sesion del listener y retorna los
+// Linkedid con la cantidad de canales vivos, junto a los eventos ajenos recibidos mientras tanto
+func getAmiLiveCalls(clientAmi *goami2.Client) (map[string]int, []*goami2.Message, error) {
+	action := goami2.NewAction("CoreShowChannels")
+	actionID := fmt.Sprintf("coreshowchannels-%d", time.Now().UnixNano())
+	action.SetField("ActionID", actionID)
+
+	var pending []*goami2.Message
+	if err := clientAmi.MustSend(action.Byte()); err != nil {
+		return nil, pending, err
+	}
+
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	liveCalls := make(map[string]int)
+
+	// Listen for responses
+	for {
+		select {
+		case msg, ok := <-clientAmi.AllMessages():
+			if !ok {
+				return nil, pending, fmt.Errorf("ami connection closed")
+			}
+			if msg == nil {
+				continue
+			}
+			if msg.ActionID() != actionID {
+				pending = append(pending, msg)
+				continue
+			}
+
+			if msg.IsResponse() && !msg.IsSuccess() {
+				return nil, pending, fmt.Errorf("CoreShowChannels failed: %s", msg.Field("Message"))
+			}
+
+			if msg.Field("Event") == "CoreShowChannel" {
+				liveCalls[msg.Field("Linkedid")]++
+			}
+
+			// Break the loop if the response is "CoreShowChannelsComplete"
+			if msg.Field("Event") == "CoreShowChannelsComplete" {
+				return liveCalls, pending, nil
+			}
+
+		case <-ctx.Done():
+			return nil, pending, fmt.Errorf("timeout waiting for CoreShowChannels")
+
+		case err := <-clientAmi.Err():
+			return nil, pending, err
+		}
+	}
+}
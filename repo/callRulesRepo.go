@@ -0,0 +1,215 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// reglas por defecto, equivalen al comportamiento original del servicio
+var defaultCallRules = models.CallRules{
+	Outbound: models.OutboundRules{
+		Enabled:             true,
+		AgentPatterns:       []string{"^80.*"},
+		ExcludedContexts:    []string{"tc-maint"},
+		DialedNumberContext: "from-internal",
+		Queue:               "8000",
+		CampaignId:          1,
+	},
+	Inbound: models.InboundRules{
+		Enabled: true,
+		Queues:  []models.QueueRule{{Queue: "8000", CampaignId: 1}},
+	},
+}
+
+// callRules reglas ya validadas y con las regex compiladas
+type callRules struct {
+	cfg           models.CallRules
+	agentPatterns []*regexp.Regexp
+}
+
+var activeRules atomic.Pointer[callRules]
+
+func init() {
+	activeRules.Store(mustCompileDefaultRules())
+}
+
+// LoadCallRules lee, valida y activa las reglas del archivo indicado. Si el archivo
+// no existe se mantienen las reglas por defecto. Si es invalido retorna el error
+// de validacion y las reglas activas no cambian
+func LoadCallRules(path string) (models.CallRules, error) {
+	// open file
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		utils.Logline("call rules file not found, using default rules", path)
+		activeRules.Store(mustCompileDefaultRules())
+		return defaultCallRules, nil
+	}
+	if err != nil {
+		return models.CallRules{}, fmt.Errorf("failed to read call rules %s: %v", path, err)
+	}
+	defer file.Close()
+
+	// decode json data to struct
+	var cfg models.CallRules
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return models.CallRules{}, fmt.Errorf("invalid call rules %s: %v", path, err)
+	}
+
+	rules, err := compileCallRules(cfg)
+	if err != nil {
+		return models.CallRules{}, fmt.Errorf("invalid call rules %s: %v", path, err)
+	}
+
+	activeRules.Store(rules)
+	utils.Logline("call rules loaded", path, cfg)
+	return cfg, nil
+}
+
+// GetCallRules retorna las reglas activas
+func GetCallRules() models.CallRules {
+	return activeRules.Load().cfg
+}
+
+func mustCompileDefaultRules() *callRules {
+	rules, err := compileCallRules(defaultCallRules)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// compileCallRules valida las reglas y compila las expresiones regulares
+func compileCallRules(cfg models.CallRules) (*callRules, error) {
+	var errs []string
+	rules := &callRules{cfg: cfg}
+
+	if cfg.Outbound.Enabled {
+		if len(cfg.Outbound.AgentPatterns) == 0 {
+			errs = append(errs, "outbound.agent_patterns: at least one pattern is required")
+		}
+		for i, pattern := range cfg.Outbound.AgentPatterns {
+			if pattern == "" {
+				errs = append(errs, fmt.Sprintf("outbound.agent_patterns[%d]: empty pattern would match every channel", i))
+				continue
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("outbound.agent_patterns[%d]: %v", i, err))
+				continue
+			}
+			rules.agentPatterns = append(rules.agentPatterns, re)
+		}
+		if cfg.Outbound.Queue == "" {
+			errs = append(errs, "outbound.queue: required")
+		}
+		if cfg.Outbound.CampaignId <= 0 {
+			errs = append(errs, "outbound.campaign_id: must be greater than 0")
+		}
+		for _, context := range cfg.Outbound.Contexts {
+			if slices.Contains(cfg.Outbound.ExcludedContexts, context) {
+				errs = append(errs, fmt.Sprintf("outbound.contexts: %q is also excluded", context))
+			}
+		}
+	}
+
+	if cfg.Inbound.Enabled {
+		if len(cfg.Inbound.Queues) == 0 {
+			errs = append(errs, "inbound.queues: at least one queue is required")
+		}
+		seen := make(map[string]bool)
+		for i, queue := range cfg.Inbound.Queues {
+			if queue.Queue == "" {
+				errs = append(errs, fmt.Sprintf("inbound.queues[%d].queue: required", i))
+			}
+			if seen[queue.Queue] {
+				errs = append(errs, fmt.Sprintf("inbound.queues[%d].queue: %q is duplicated", i, queue.Queue))
+			}
+			seen[queue.Queue] = true
+			if queue.CampaignId <= 0 {
+				errs = append(errs, fmt.Sprintf("inbound.queues[%d].campaign_id: must be greater than 0", i))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return rules, nil
+}
+
+// isAgent indica si el numero corresponde a una extension de agente rastreada
+func (r *callRules) isAgent(callerIdNum string) bool {
+	if !r.cfg.Outbound.Enabled {
+		return false
+	}
+	for _, re := range r.agentPatterns {
+		if re.MatchString(callerIdNum) {
+			return true
+		}
+	}
+	return false
+}
+
+// tracksContext indica si se rastrean las salientes que inician en el contexto
+func (r *callRules) tracksContext(context string) bool {
+	return len(r.cfg.Outbound.Contexts) == 0 || slices.Contains(r.cfg.Outbound.Contexts, context)
+}
+
+// isExcludedContext indica si el contexto se ignora para cerrar llamadas
+func (r *callRules) isExcludedContext(context string) bool {
+	return slices.Contains(r.cfg.Outbound.ExcludedContexts, context)
+}
+
+// inboundQueue retorna la regla de la cola si sus llamadas se rastrean
+func (r *callRules) inboundQueue(queue string) (models.QueueRule, bool) {
+	if !r.cfg.Inbound.Enabled {
+		return models.QueueRule{}, false
+	}
+	for _, rule := range r.cfg.Inbound.Queues {
+		if rule.Queue == queue {
+			return rule, true
+		}
+	}
+	return models.QueueRule{}, false
+}
+
+// campaignIds todas las campañas usadas por las reglas, para filtrar calls
+func (r *callRules) campaignIds() []any {
+	var ids []any
+	add := func(id int) {
+		if !slices.Contains(ids, any(id)) {
+			ids = append(ids, id)
+		}
+	}
+	if r.cfg.Outbound.Enabled {
+		add(r.cfg.Outbound.CampaignId)
+	}
+	if r.cfg.Inbound.Enabled {
+		for _, queue := range r.cfg.Inbound.Queues {
+			add(queue.CampaignId)
+		}
+	}
+	return ids
+}
+
+// targetType clasifica el destino de una transferencia: queue, agent o external
+func (r *callRules) targetType(target string) string {
+	if _, ok := r.inboundQueue(target); ok {
+		return "queue"
+	}
+	if r.isAgent(target) {
+		return "agent"
+	}
+	return "external"
+}
@@ -0,0 +1,355 @@
+package repo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ired.com/callcenter/models"
+)
+
+// tiempo que se conserva una llamada terminada en memoria, asi los eventos tardios
+// del mismo Linkedid no vuelven a crear la llamada
+const finishedCallRetention = 10 * time.Minute
+
+// tabla de transiciones validas, cualquier otra combinacion es un error
+var callTransitions = map[models.CallState]map[models.CallEvent]models.CallState{
+	models.CallDialing: {
+		models.EventDial:         models.CallRinging,
+		models.EventRingNoAnswer: models.CallDialing,
+		models.EventAnswer:       models.CallActive,
+		models.EventBridgeEnter:  models.CallActive,
+		models.EventAbandon:      models.CallNoAnswer,
+		models.EventHangup:       models.CallNoAnswer,
+	},
+	// en las entrantes un RingNoAnswer devuelve la llamada a esperar en la cola
+	models.CallRinging: {
+		models.EventDial:         models.CallRinging,
+		models.EventRingNoAnswer: models.CallDialing,
+		models.EventAnswer:       models.CallActive,
+		models.EventBridgeEnter:  models.CallActive,
+		models.EventAbandon:      models.CallNoAnswer,
+		models.EventHangup:       models.CallNoAnswer,
+	},
+	models.CallActive: {
+		models.EventBridgeEnter: models.CallActive,
+		models.EventUnhold:      models.CallActive,
+		models.EventHold:        models.CallOnHold,
+		models.EventTransfer:    models.CallTransferred,
+		models.EventBridgeLeave: models.CallCompleted,
+		models.EventHangup:      models.CallCompleted,
+	},
+	models.CallOnHold: {
+		models.EventHold:        models.CallOnHold,
+		models.EventUnhold:      models.CallActive,
+		models.EventBridgeEnter: models.CallOnHold,
+		models.EventTransfer:    models.CallTransferred,
+		models.EventBridgeLeave: models.CallCompleted,
+		models.EventHangup:      models.CallCompleted,
+	},
+	// el agente original ya salio, la llamada espera que el destino (agente o cola) atienda
+	models.CallTransferred: {
+		models.EventBridgeEnter:  models.CallActive,
+		models.EventAnswer:       models.CallActive,
+		models.EventDial:         models.CallTransferred,
+		models.EventRingNoAnswer: models.CallTransferred,
+		models.EventBridgeLeave:  models.CallTransferred,
+		models.EventHangup:       models.CallTransferred,
+		models.EventTransfer:     models.CallTransferred,
+		models.EventAbandon:      models.CallNoAnswer,
+		models.EventChannelsGone: models.CallCompleted, // el destino nunca atendio y el cliente colgo
+	},
+	models.CallCompleted: {
+		models.EventBridgeLeave: models.CallCompleted,
+		models.EventHangup:      models.CallCompleted,
+	},
+	models.CallNoAnswer: {
+		models.EventHangup: models.CallNoAnswer,
+	},
+}
+
+// nextCallState retorna el estado destino de aplicar el evento, false si no es valido
+func nextCallState(from models.CallState, ev models.CallEvent) (models.CallState, bool) {
+	to, ok := callTransitions[from][ev]
+	return to, ok
+}
+
+// callKey una llamada se identifica por su central y su Linkedid, dos centrales pueden
+// generar el mismo uniqueid si comparten el systemname de asterisk.conf
+type callKey struct {
+	pbx      string
+	linkedId string
+}
+
+// callRegistry llamadas rastreadas indexadas por central y Linkedid, seguro para uso concurrente
+type callRegistry struct {
+	mu       sync.Mutex
+	calls    map[callKey]*models.TrackedCall
+	aliases  map[callKey]string // Linkedid secundario -> Linkedid de la llamada original, en la misma central
+	channels map[callKey]int    // canales vivos por Linkedid, rastreada o no
+}
+
+func newCallRegistry() *callRegistry {
+	return &callRegistry{calls: make(map[callKey]*models.TrackedCall), aliases: make(map[callKey]string), channels: make(map[callKey]int)}
+}
+
+// registro global de llamadas del listener
+var callTracker = newCallRegistry()
+
+// Start comienza a rastrear una llamada en Dialing, false si ya estaba rastreada
+func (r *callRegistry) Start(call models.TrackedCall) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked()
+
+	key := callKey{call.Pbx, call.Linkedid}
+	if _, ok := r.calls[key]; ok {
+		return false
+	}
+	now := time.Now()
+	call.State = models.CallDialing
+	call.StartedAt = now
+	call.UpdatedAt = now
+	r.calls[key] = &call
+	return true
+}
+
+// StartIfAgentIdle como Start pero solo si el agente no tiene otra llamada en curso en su
+// central, la
+// revision y el alta se hacen con el mismo lock asi dos Originate al mismo agente no
+// pueden pasar ambos
+func (r *callRegistry) StartIfAgentIdle(call models.TrackedCall) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked()
+
+	key := callKey{call.Pbx, call.Linkedid}
+	if _, ok := r.calls[key]; ok {
+		return false
+	}
+	for _, active := range r.calls {
+		if active.Pbx == call.Pbx && active.Agent == call.Agent && !active.State.IsFinal() {
+			return false
+		}
+	}
+	now := time.Now()
+	call.State = models.CallDialing
+	call.StartedAt = now
+	call.UpdatedAt = now
+	r.calls[key] = &call
+	return true
+}
+
+// Restore retoma el rastreo de una llamada en un estado conocido (reconciliacion), false
+// si ya estaba rastreada. En una reconexion el registro en memoria tiene mas datos que
+// current_calls (transferencias, esperas, alias) por lo que no se reemplaza
+func (r *callRegistry) Restore(call models.TrackedCall) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := callKey{call.Pbx, call.Linkedid}
+	if _, ok := r.calls[key]; ok {
+		return false
+	}
+	now := time.Now()
+	call.StartedAt = now
+	call.UpdatedAt = now
+	if call.State == models.CallActive {
+		call.AnsweredAt = &now
+	}
+	r.calls[key] = &call
+	return true
+}
+
+// Update modifica los datos de la llamada que no son parte del estado
+func (r *callRegistry) Update(pbx string, linkedId string, fn func(call *models.TrackedCall)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call, ok := r.calls[r.resolveLocked(pbx, linkedId)]
+	if !ok {
+		return false
+	}
+	fn(call)
+	call.UpdatedAt = time.Now()
+	return true
+}
+
+// Get retorna una copia de la llamada rastreada
+func (r *callRegistry) Get(pbx string, linkedId string) (models.TrackedCall, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call, ok := r.calls[r.resolveLocked(pbx, linkedId)]
+	if !ok {
+		return models.TrackedCall{}, false
+	}
+	return *call, true
+}
+
+// Fire aplica un evento a la llamada y retorna el estado previo y el nuevo, si la
+// transicion no es valida el estado no cambia y se retorna un error
+func (r *callRegistry) Fire(pbx string, linkedId string, ev models.CallEvent) (models.CallState, models.CallState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call, ok := r.calls[r.resolveLocked(pbx, linkedId)]
+	if !ok {
+		return 0, 0, fmt.Errorf("call %s/%s is not tracked", pbx, linkedId)
+	}
+
+	from := call.State
+	to, ok := nextCallState(from, ev)
+	if !ok {
+		return from, from, fmt.Errorf("invalid transition %s --%s--> ? for call %s/%s", from, ev, pbx, linkedId)
+	}
+
+	// sin canales vivos termina como completada solo si alguien la atendio
+	if ev == models.EventChannelsGone && call.AnsweredAt == nil {
+		to = models.CallNoAnswer
+	}
+
+	now := time.Now()
+	call.State = to
+	call.UpdatedAt = now
+	if to == models.CallActive && call.AnsweredAt == nil {
+		call.AnsweredAt = &now
+	}
+	if to.IsFinal() && !from.IsFinal() {
+		call.EndedAt = &now
+	}
+	if from.IsFinal() && !to.IsFinal() {
+		call.EndedAt = nil
+	}
+
+	// contador y duracion de las esperas, Hold y MusicOnHoldStart llegan juntos
+	// por lo que solo se cuenta el cambio de estado
+	if to == models.CallOnHold && from != models.CallOnHold {
+		call.HoldCount++
+		call.HoldStarted = &now
+	}
+	if from == models.CallOnHold && to != models.CallOnHold && call.HoldStarted != nil {
+		call.HoldSeconds += int(now.Sub(*call.HoldStarted).Seconds())
+		call.HoldStarted = nil
+	}
+	return from, to, nil
+}
+
+// Alias hace que los eventos de otro Linkedid se apliquen a la llamada original,
+// se usa en las transferencias atendidas donde el destino viene de la consulta
+func (r *callRegistry) Alias(pbx string, linkedId string, original string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if linkedId != original {
+		r.aliases[callKey{pbx, linkedId}] = original
+	}
+}
+
+// Resolve retorna el Linkedid de la llamada original si el recibido es un alias
+func (r *callRegistry) Resolve(pbx string, linkedId string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.resolveLocked(pbx, linkedId).linkedId
+}
+
+// resolveLocked Get, Update y Fire resuelven el alias con el lock tomado, asi un evento
+// de la consulta que llega justo cuando se crea el alias se aplica a la llamada original
+func (r *callRegistry) resolveLocked(pbx string, linkedId string) callKey {
+	key := callKey{pbx, linkedId}
+	if original, ok := r.aliases[key]; ok {
+		return callKey{pbx, original}
+	}
+	return key
+}
+
+// ChannelUp cuenta un canal nuevo (Newchannel) del Linkedid
+func (r *callRegistry) ChannelUp(pbx string, linkedId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[callKey{pbx, linkedId}]++
+}
+
+// ChannelDown descuenta un canal que colgo y retorna los canales que le quedan a la
+// llamada, sumando los de sus alias (la consulta de una transferencia atendida)
+func (r *callRegistry) ChannelDown(pbx string, linkedId string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := callKey{pbx, linkedId}
+	if r.channels[key] <= 1 {
+		delete(r.channels, key)
+	} else {
+		r.channels[key]--
+	}
+
+	original := r.resolveLocked(pbx, linkedId)
+	remaining := r.channels[original]
+	for alias, o := range r.aliases {
+		if alias.pbx == pbx && o == original.linkedId {
+			remaining += r.channels[alias]
+		}
+	}
+	return remaining
+}
+
+// SeedChannels reemplaza los canales vivos de la central con los de CoreShowChannels al
+// iniciar la sesion, asi no cuentan los eventos perdidos mientras la sesion estuvo caida
+func (r *callRegistry) SeedChannels(pbx string, channels map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.channels {
+		if key.pbx == pbx {
+			delete(r.channels, key)
+		}
+	}
+	for linkedId, count := range channels {
+		r.channels[callKey{pbx, linkedId}] = count
+	}
+}
+
+// Remove deja de rastrear la llamada
+func (r *callRegistry) Remove(pbx string, linkedId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.calls, callKey{pbx, linkedId})
+}
+
+// List retorna una copia de todas las llamadas rastreadas
+func (r *callRegistry) List() []models.TrackedCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]models.TrackedCall, 0, len(r.calls))
+	for _, call := range r.calls {
+		list = append(list, *call)
+	}
+	return list
+}
+
+// elimina las llamadas terminadas que superaron el tiempo de retencion
+func (r *callRegistry) pruneLocked() {
+	for key, call := range r.calls {
+		if call.EndedAt != nil && time.Since(*call.EndedAt) > finishedCallRetention {
+			delete(r.calls, key)
+		}
+	}
+	for alias, original := range r.aliases {
+		if _, ok := r.calls[callKey{alias.pbx, original}]; !ok {
+			delete(r.aliases, alias)
+		}
+	}
+}
+
+// ActiveByAgent retorna la llamada en curso de la extension del agente en la central, la
+// misma extension puede existir en otra central
+func (r *callRegistry) ActiveByAgent(pbx string, agent string) (models.TrackedCall, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, call := range r.calls {
+		if call.Pbx == pbx && call.Agent == agent && !call.State.IsFinal() {
+			return *call, true
+		}
+	}
+	return models.TrackedCall{}, false
+}
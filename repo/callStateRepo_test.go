@@ -0,0 +1,332 @@
+package repo
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ired.com/callcenter/models"
+)
+
+func TestNextCallState(t *testing.T) {
+	tests := []struct {
+		from models.CallState
+		ev   models.CallEvent
+		to   models.CallState
+		ok   bool
+	}{
+		{models.CallDialing, models.EventDial, models.CallRinging, true},
+		{models.CallDialing, models.EventBridgeEnter, models.CallActive, true},
+		{models.CallDialing, models.EventHangup, models.CallNoAnswer, true},
+		{models.CallRinging, models.EventRingNoAnswer, models.CallDialing, true},
+		{models.CallRinging, models.EventAnswer, models.CallActive, true},
+		{models.CallRinging, models.EventAbandon, models.CallNoAnswer, true},
+		{models.CallActive, models.EventHold, models.CallOnHold, true},
+		{models.CallActive, models.EventTransfer, models.CallTransferred, true},
+		{models.CallActive, models.EventBridgeLeave, models.CallCompleted, true},
+		{models.CallOnHold, models.EventUnhold, models.CallActive, true},
+		{models.CallOnHold, models.EventHangup, models.CallCompleted, true},
+		{models.CallTransferred, models.EventBridgeEnter, models.CallActive, true},
+		{models.CallTransferred, models.EventHangup, models.CallTransferred, true},
+		{models.CallTransferred, models.EventAbandon, models.CallNoAnswer, true},
+		{models.CallTransferred, models.EventChannelsGone, models.CallCompleted, true},
+		{models.CallCompleted, models.EventHangup, models.CallCompleted, true},
+		{models.CallNoAnswer, models.EventHangup, models.CallNoAnswer, true},
+		// invalidas
+		{models.CallDialing, models.EventHold, 0, false},
+		{models.CallActive, models.EventDial, 0, false},
+		{models.CallCompleted, models.EventBridgeEnter, 0, false},
+		{models.CallNoAnswer, models.EventAnswer, 0, false},
+		{models.CallActive, models.EventChannelsGone, 0, false},
+	}
+
+	for _, tt := range tests {
+		to, ok := nextCallState(tt.from, tt.ev)
+		if ok != tt.ok || (ok && to != tt.to) {
+			t.Errorf("%s --%s--> got (%s, %v), want (%s, %v)", tt.from, tt.ev, to, ok, tt.to, tt.ok)
+		}
+	}
+}
+
+func TestCallRegistryStart(t *testing.T) {
+	r := newCallRegistry()
+	call := models.TrackedCall{Pbx: "central", Linkedid: "100.1", Direction: models.DirectionOutbound, Agent: "8001"}
+
+	if !r.Start(call) {
+		t.Fatal("first Start should track the call")
+	}
+	if r.Start(call) {
+		t.Fatal("second Start of the same Linkedid should be rejected")
+	}
+	got, ok := r.Get("central", "100.1")
+	if !ok || got.State != models.CallDialing || got.StartedAt.IsZero() {
+		t.Fatalf("unexpected call after Start: %+v", got)
+	}
+}
+
+// de varios Originate simultaneos al mismo agente solo uno lo toma
+func TestCallRegistryStartIfAgentIdle(t *testing.T) {
+	r := newCallRegistry()
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call := models.TrackedCall{Pbx: "central", Linkedid: fmt.Sprintf("click-%d", i), Agent: "8001", Originated: true}
+			if r.StartIfAgentIdle(call) {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := started.Load(); n != 1 {
+		t.Fatalf("started = %d, want 1", n)
+	}
+
+	if !r.StartIfAgentIdle(models.TrackedCall{Pbx: "central", Linkedid: "click-20", Agent: "8002"}) {
+		t.Error("another agent should not be blocked")
+	}
+	if !r.StartIfAgentIdle(models.TrackedCall{Pbx: "sucursal", Linkedid: "click-30", Agent: "8001"}) {
+		t.Error("the same extension on another pbx should not be blocked")
+	}
+	for _, call := range r.List() {
+		if call.Agent == "8001" {
+			r.Update(call.Pbx, call.Linkedid, func(c *models.TrackedCall) { c.State = models.CallCompleted })
+		}
+	}
+	if !r.StartIfAgentIdle(models.TrackedCall{Pbx: "central", Linkedid: "click-21", Agent: "8001"}) {
+		t.Error("agent with only finished calls should be idle")
+	}
+}
+
+func TestCallRegistryFire(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []models.CallEvent
+		state   models.CallState
+		answer  bool
+		ended   bool
+		holds   int
+		invalid int
+	}{
+		{"answered and completed", []models.CallEvent{models.EventDial, models.EventBridgeEnter, models.EventBridgeLeave}, models.CallCompleted, true, true, 0, 0},
+		{"not answered", []models.CallEvent{models.EventDial, models.EventHangup}, models.CallNoAnswer, false, true, 0, 0},
+		{"hold counted once", []models.CallEvent{models.EventBridgeEnter, models.EventHold, models.EventHold, models.EventUnhold}, models.CallActive, true, false, 1, 0},
+		{"invalid keeps state", []models.CallEvent{models.EventDial, models.EventHold}, models.CallRinging, false, false, 0, 1},
+		{"transfer waits for target", []models.CallEvent{models.EventBridgeEnter, models.EventTransfer, models.EventHangup}, models.CallTransferred, true, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newCallRegistry()
+			r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1"})
+
+			invalid := 0
+			for _, ev := range tt.events {
+				if _, _, err := r.Fire("central", "100.1", ev); err != nil {
+					invalid++
+				}
+			}
+			call, _ := r.Get("central", "100.1")
+			if call.State != tt.state {
+				t.Errorf("state = %s, want %s", call.State, tt.state)
+			}
+			if (call.AnsweredAt != nil) != tt.answer {
+				t.Errorf("answered = %v, want %v", call.AnsweredAt != nil, tt.answer)
+			}
+			if (call.EndedAt != nil) != tt.ended {
+				t.Errorf("ended = %v, want %v", call.EndedAt != nil, tt.ended)
+			}
+			if call.HoldCount != tt.holds {
+				t.Errorf("holds = %d, want %d", call.HoldCount, tt.holds)
+			}
+			if invalid != tt.invalid {
+				t.Errorf("invalid transitions = %d, want %d", invalid, tt.invalid)
+			}
+		})
+	}
+
+	r := newCallRegistry()
+	if _, _, err := r.Fire("central", "missing", models.EventDial); err == nil {
+		t.Error("Fire on an untracked call should fail")
+	}
+}
+
+func TestCallRegistryAliasResolve(t *testing.T) {
+	r := newCallRegistry()
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1"})
+
+	r.Alias("central", "200.1", "100.1")
+	r.Alias("central", "100.1", "100.1") // un alias a si mismo se ignora
+
+	tests := map[string]string{"200.1": "100.1", "100.1": "100.1", "300.1": "300.1"}
+	for linkedId, want := range tests {
+		if got := r.Resolve("central", linkedId); got != want {
+			t.Errorf("Resolve(%s) = %s, want %s", linkedId, got, want)
+		}
+	}
+}
+
+func TestCallRegistryPrune(t *testing.T) {
+	r := newCallRegistry()
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "old"})
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "recent"})
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "open"})
+	r.Alias("central", "old-consult", "old")
+
+	expired := time.Now().Add(-finishedCallRetention - time.Minute)
+	recent := time.Now()
+	r.Update("central", "old", func(call *models.TrackedCall) { call.EndedAt = &expired })
+	r.Update("central", "recent", func(call *models.TrackedCall) { call.EndedAt = &recent })
+
+	// Start limpia las terminadas antes de agregar
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "new"})
+
+	if _, ok := r.Get("central", "old"); ok {
+		t.Error("expired call should be pruned")
+	}
+	if r.Resolve("central", "old-consult") != "old-consult" {
+		t.Error("alias of a pruned call should be removed")
+	}
+	for _, linkedId := range []string{"recent", "open", "new"} {
+		if _, ok := r.Get("central", linkedId); !ok {
+			t.Errorf("%s should still be tracked", linkedId)
+		}
+	}
+}
+
+func TestCallRegistryRestore(t *testing.T) {
+	r := newCallRegistry()
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1", Agent: "8001"})
+	r.Fire("central", "100.1", models.EventBridgeEnter)
+	r.Fire("central", "100.1", models.EventTransfer)
+
+	// una reconexion no debe pisar lo que ya esta en memoria
+	if r.Restore(models.TrackedCall{Pbx: "central", Linkedid: "100.1", Agent: "8001", State: models.CallActive}) {
+		t.Error("Restore should skip a call already tracked")
+	}
+	if call, _ := r.Get("central", "100.1"); call.State != models.CallTransferred {
+		t.Errorf("tracked call was replaced: %+v", call)
+	}
+
+	if !r.Restore(models.TrackedCall{Pbx: "central", Linkedid: "200.1", State: models.CallActive}) {
+		t.Error("Restore should track an unknown call")
+	}
+	if call, _ := r.Get("central", "200.1"); call.AnsweredAt == nil {
+		t.Error("restored active call should be answered")
+	}
+}
+
+func TestCallRegistryActiveByAgent(t *testing.T) {
+	r := newCallRegistry()
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1", Agent: "8001"})
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.2", Agent: "8002"})
+	r.Fire("central", "100.2", models.EventHangup)
+
+	if _, ok := r.ActiveByAgent("central", "8001"); !ok {
+		t.Error("8001 has a call in progress")
+	}
+	if _, ok := r.ActiveByAgent("sucursal", "8001"); ok {
+		t.Error("8001 of another pbx has no call")
+	}
+	if _, ok := r.ActiveByAgent("central", "8002"); ok {
+		t.Error("8002 call already ended")
+	}
+}
+
+func TestCallRegistryChannelsGone(t *testing.T) {
+	r := newCallRegistry()
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1"})
+	r.ChannelUp("central", "100.1") // cliente
+	r.ChannelUp("central", "100.1") // agente
+	r.ChannelUp("central", "200.1") // consulta de la transferencia atendida
+	r.Alias("central", "200.1", "100.1")
+	r.Fire("central", "100.1", models.EventBridgeEnter)
+	r.Fire("central", "100.1", models.EventTransfer)
+
+	// el agente que transfirio cuelga, siguen el cliente y el destino de la consulta
+	if remaining := r.ChannelDown("central", "100.1"); remaining != 2 {
+		t.Fatalf("remaining = %d, want 2", remaining)
+	}
+	if remaining := r.ChannelDown("central", "200.1"); remaining != 1 {
+		t.Fatalf("remaining = %d, want 1", remaining)
+	}
+	if remaining := r.ChannelDown("central", "100.1"); remaining != 0 {
+		t.Fatalf("remaining = %d, want 0", remaining)
+	}
+	// un Hangup de mas no deja el contador negativo
+	if remaining := r.ChannelDown("central", "100.1"); remaining != 0 {
+		t.Fatalf("remaining = %d, want 0", remaining)
+	}
+
+	if _, to, err := r.Fire("central", "100.1", models.EventChannelsGone); err != nil || to != models.CallCompleted {
+		t.Errorf("answered transferred call: got (%s, %v), want Completed", to, err)
+	}
+	if call, _ := r.Get("central", "100.1"); call.EndedAt == nil {
+		t.Error("call without channels should be ended")
+	}
+
+	// restaurada sin datos de atencion, termina como no atendida
+	r.Restore(models.TrackedCall{Pbx: "central", Linkedid: "300.1", State: models.CallTransferred})
+	if _, to, err := r.Fire("central", "300.1", models.EventChannelsGone); err != nil || to != models.CallNoAnswer {
+		t.Errorf("unanswered transferred call: got (%s, %v), want NoAnswer", to, err)
+	}
+}
+
+func TestCallRegistrySeedChannels(t *testing.T) {
+	r := newCallRegistry()
+	r.ChannelUp("central", "100.1")
+	r.SeedChannels("central", map[string]int{"100.1": 3})
+
+	if remaining := r.ChannelDown("central", "100.1"); remaining != 2 {
+		t.Errorf("remaining = %d, want 2", remaining)
+	}
+}
+
+func TestCallRegistryFireResolvesAlias(t *testing.T) {
+	r := newCallRegistry()
+	r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1"})
+	r.Alias("central", "200.1", "100.1")
+
+	if _, to, err := r.Fire("central", "200.1", models.EventBridgeEnter); err != nil || to != models.CallActive {
+		t.Fatalf("event of the consult leg: got (%s, %v), want Active", to, err)
+	}
+	if call, ok := r.Get("central", "200.1"); !ok || call.Linkedid != "100.1" || call.State != models.CallActive {
+		t.Errorf("consult leg should resolve to the original call: %+v", call)
+	}
+}
+
+func TestCallRegistryKeyedByPbx(t *testing.T) {
+	r := newCallRegistry()
+	if !r.Start(models.TrackedCall{Pbx: "central", Linkedid: "100.1", Agent: "8001"}) {
+		t.Fatal("call of central should be tracked")
+	}
+	if !r.Start(models.TrackedCall{Pbx: "sucursal", Linkedid: "100.1", Agent: "9001"}) {
+		t.Fatal("same Linkedid on another pbx is another call")
+	}
+
+	r.Fire("central", "100.1", models.EventBridgeEnter)
+	if call, _ := r.Get("sucursal", "100.1"); call.State != models.CallDialing || call.Agent != "9001" {
+		t.Errorf("event of central changed the call of sucursal: %+v", call)
+	}
+
+	r.Alias("central", "200.1", "100.1")
+	if got := r.Resolve("sucursal", "200.1"); got != "200.1" {
+		t.Errorf("alias of central resolved on sucursal to %s", got)
+	}
+
+	// la reconexion de una central reemplaza solo sus canales
+	r.ChannelUp("central", "300.1")
+	r.ChannelUp("sucursal", "300.1")
+	r.ChannelUp("sucursal", "300.1")
+	r.SeedChannels("central", map[string]int{"400.1": 1})
+	if remaining := r.ChannelDown("central", "300.1"); remaining != 0 {
+		t.Errorf("central remaining = %d, want 0", remaining)
+	}
+	if remaining := r.ChannelDown("sucursal", "300.1"); remaining != 1 {
+		t.Errorf("sucursal remaining = %d, want 1", remaining)
+	}
+}
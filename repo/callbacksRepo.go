@@ -0,0 +1,487 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// valores por defecto del job de rellamadas (task service_callbacks)
+const (
+	defaultCallbackMaxAttempts = 3
+	defaultCallbackRetryAfter  = 15 * time.Minute
+	defaultCallbackRingTimeout = 30 * time.Second
+	defaultCallbackContext     = "from-internal"
+	callbackBatch              = 50 // rellamadas revisadas por ejecucion
+)
+
+var ErrCallbackNotFound = errors.New("callback not found")
+var ErrCallbackNotAvailable = errors.New("callback is not available")
+
+const callbackColumns = `id, phone, id_call, uniqueid, queue, pbx, abandon_count, status, claimed_by, attempts, result, notes, closed_by,
+	datetime_first_abandon, datetime_last_abandon, datetime_claimed, datetime_last_attempt, datetime_closed`
+
+// registerCallback agrega la llamada entrante no atendida a la lista de rellamadas, si el
+// telefono ya tiene una rellamada abierta solo se suma el abandono
+func registerCallback(db models.ConnMysql, call models.TrackedCall) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var callId int
+	var phone string
+	err := db.Conn.QueryRowContext(ctx, `SELECT id, phone FROM calls WHERE pbx = ? AND uniqueid = ?`, call.Pbx, call.Linkedid).Scan(&callId, &phone)
+	if err != nil {
+		utils.Logline("Failed to get abandoned call for callback", call.Linkedid, err)
+		return fmt.Errorf("failed to get abandoned call")
+	}
+	if !callbackPhone(phone) {
+		return nil
+	}
+
+	// open_phone es unico mientras la rellamada no se cierra (migrations/014), dos abandonos
+	// simultaneos del mismo telefono terminan en la misma rellamada
+	query := `INSERT INTO call_callbacks (phone, id_call, uniqueid, queue, pbx, datetime_first_abandon, datetime_last_abandon)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE abandon_count = abandon_count + 1, id_call = VALUES(id_call), uniqueid = VALUES(uniqueid),
+			datetime_last_abandon = NOW()`
+	res, err := db.Conn.ExecContext(ctx, query, phone, callId, call.Linkedid, call.Queue, call.Pbx)
+	if err != nil {
+		utils.Logline("Failed to insert callback", call.Linkedid, err)
+		return fmt.Errorf("failed to insert callback")
+	}
+	// 1 fila afectada es una rellamada nueva, 2 es un abandono mas de la abierta
+	if rows, _ := res.RowsAffected(); rows == 1 {
+		utils.Logline("callback registered", phone, call.Linkedid)
+	}
+	return nil
+}
+
+// closeCallbacksAnswered el cliente volvio a llamar y fue atendido, sus rellamadas
+// pendientes ya no hacen falta
+func closeCallbacksAnswered(db models.ConnMysql, pbx string, uniqueIdDb string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `UPDATE call_callbacks AS cb INNER JOIN calls AS c ON c.phone = cb.phone
+		SET cb.status = 'closed', cb.result = 'called_back', cb.closed_by = 'system', cb.datetime_closed = NOW()
+		WHERE c.pbx = ? AND c.uniqueid = ? AND cb.status = 'pending'`
+	_, err := db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to close answered callbacks", uniqueIdDb, err)
+		return fmt.Errorf("failed to close answered callbacks")
+	}
+	return nil
+}
+
+// callbackPhone descarta los numeros privados o vacios
+func callbackPhone(phone string) bool {
+	switch strings.ToLower(phone) {
+	case "", "anonymous", "unknown", "restricted", "<unknown>":
+		return false
+	}
+	return true
+}
+
+// Callbacks lista de rellamadas, por defecto las abiertas de la mas vieja a la mas nueva
+func Callbacks(db models.ConnMysql, filter models.CallbacksReq) ([]models.Callback, error) {
+	query := `SELECT ` + callbackColumns + ` FROM call_callbacks WHERE 1 = 1`
+	var args []any
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	} else {
+		query += ` AND status <> 'closed'`
+	}
+	if filter.Queue != "" {
+		query += ` AND queue = ?`
+		args = append(args, filter.Queue)
+	}
+	if filter.Phone != "" {
+		query += ` AND phone = ?`
+		args = append(args, filter.Phone)
+	}
+	query += ` ORDER BY datetime_first_abandon ASC LIMIT 500`
+
+	rows, err := db.Conn.QueryContext(db.Ctx, query, args...)
+	if err != nil {
+		utils.Logline("error getting callbacks", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	callbacks := []models.Callback{}
+	for rows.Next() {
+		callback, err := scanCallback(rows)
+		if err != nil {
+			utils.Logline("error scanning callbacks", err)
+			return nil, err
+		}
+		callbacks = append(callbacks, callback)
+	}
+	return callbacks, rows.Err()
+}
+
+// GetCallback rellamada con sus intentos
+func GetCallback(db models.ConnMysql, id int) (models.Callback, error) {
+	row := db.Conn.QueryRowContext(db.Ctx, `SELECT `+callbackColumns+` FROM call_callbacks WHERE id = ?`, id)
+	callback, err := scanCallback(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return callback, ErrCallbackNotFound
+	}
+	if err != nil {
+		utils.Logline("error getting callback", id, err)
+		return callback, err
+	}
+
+	query := `SELECT id, id_callback, id_call, agent, uniqueid, result, reason, datetime_attempt, datetime_result
+		FROM call_callback_attempts WHERE id_callback = ? ORDER BY id ASC`
+	rows, err := db.Conn.QueryContext(db.Ctx, query, id)
+	if err != nil {
+		utils.Logline("error getting callback attempts", id, err)
+		return callback, err
+	}
+	defer rows.Close()
+
+	callback.AttemptList = []models.CallbackAttempt{}
+	for rows.Next() {
+		var attempt models.CallbackAttempt
+		if err := rows.Scan(&attempt.Id, &attempt.IdCallback, &attempt.IdCall, &attempt.Agent, &attempt.Uniqueid, &attempt.Result,
+			&attempt.Reason, &attempt.DatetimeAttempt, &attempt.DatetimeResult); err != nil {
+			utils.Logline("error scanning callback attempts", id, err)
+			return callback, err
+		}
+		callback.AttemptList = append(callback.AttemptList, attempt)
+	}
+	return callback, rows.Err()
+}
+
+func scanCallback(row interface{ Scan(...any) error }) (models.Callback, error) {
+	var cb models.Callback
+	err := row.Scan(&cb.Id, &cb.Phone, &cb.IdCall, &cb.Uniqueid, &cb.Queue, &cb.Pbx, &cb.AbandonCount, &cb.Status, &cb.ClaimedBy, &cb.Attempts,
+		&cb.Result, &cb.Notes, &cb.ClosedBy, &cb.DatetimeFirstAbandon, &cb.DatetimeLastAbandon, &cb.DatetimeClaimed, &cb.DatetimeLastAttempt, &cb.DatetimeClosed)
+	return cb, err
+}
+
+// ClaimCallback el agente toma la rellamada, solo si esta pendiente o ya era suya
+func ClaimCallback(db models.ConnMysql, id int, agent string) (models.Callback, error) {
+	query := `UPDATE call_callbacks SET status = 'claimed', claimed_by = ?, datetime_claimed = COALESCE(datetime_claimed, NOW())
+		WHERE id = ? AND (status = 'pending' OR (status = 'claimed' AND claimed_by = ?))`
+	res, err := db.Conn.ExecContext(db.Ctx, query, agent, id, agent)
+	if err != nil {
+		utils.Logline("error claiming callback", id, err)
+		return models.Callback{}, err
+	}
+	return changedCallback(db, id, res)
+}
+
+// CloseCallback cierra la rellamada con su resultado, no se puede cerrar mientras el job la origina
+func CloseCallback(db models.ConnMysql, id int, closeReq models.CallbackCloseReq, closedBy string) (models.Callback, error) {
+	query := `UPDATE call_callbacks SET status = 'closed', result = ?, notes = NULLIF(?, ''), closed_by = ?, datetime_closed = NOW()
+		WHERE id = ? AND status IN ('pending', 'claimed')`
+	res, err := db.Conn.ExecContext(db.Ctx, query, closeReq.Result, closeReq.Notes, closedBy, id)
+	if err != nil {
+		utils.Logline("error closing callback", id, err)
+		return models.Callback{}, err
+	}
+	return changedCallback(db, id, res)
+}
+
+// changedCallback retorna la rellamada actualizada o por que no se pudo cambiar
+func changedCallback(db models.ConnMysql, id int, res sql.Result) (models.Callback, error) {
+	rows, _ := res.RowsAffected()
+	callback, err := GetCallback(db, id)
+	if err != nil {
+		return callback, err
+	}
+	if rows == 0 {
+		return callback, ErrCallbackNotAvailable
+	}
+	return callback, nil
+}
+
+// CallbackDialer origina las rellamadas pendientes hacia los agentes libres de la cola
+// de cada una: miembros sin pausa, sin llamada y con la extension libre. Cada intento
+// queda en call_callback_attempts y se origina como la click-to-call, rastreado y en
+// calls. El resultado llega en el OriginateResponse: si el agente contesta la rellamada
+// pasa a ser suya y la cierra desde la API, si no vuelve a pendiente hasta agotar
+// CALLBACK_MAX_ATTEMPTS
+func CallbackDialer(db models.ConnMysql) (models.CallbackDialerReport, error) {
+	report := models.CallbackDialerReport{StartedAt: time.Now(), Attempts: []models.CallbackAttempt{}}
+	maxAttempts := envInt("CALLBACK_MAX_ATTEMPTS", defaultCallbackMaxAttempts)
+	retryAfter := envDuration("CALLBACK_RETRY_AFTER", defaultCallbackRetryAfter)
+	ringTimeout := envDuration("CALLBACK_RING_TIMEOUT", defaultCallbackRingTimeout)
+
+	// intentos que quedaron en curso si el servicio se reinicio mientras originaba
+	query := `UPDATE call_callbacks SET status = 'pending', claimed_by = NULL
+		WHERE status = 'calling' AND datetime_last_attempt < NOW() - INTERVAL ? SECOND`
+	if _, err := db.Conn.ExecContext(db.Ctx, query, int((ringTimeout + time.Minute).Seconds())); err != nil {
+		utils.Logline("error releasing stuck callbacks", err)
+	}
+
+	query = `SELECT ` + callbackColumns + ` FROM call_callbacks
+		WHERE status = 'pending' AND attempts < ? AND (datetime_last_attempt IS NULL OR datetime_last_attempt < NOW() - INTERVAL ? SECOND)
+		ORDER BY datetime_first_abandon ASC LIMIT ?`
+	rows, err := db.Conn.QueryContext(db.Ctx, query, maxAttempts, int(retryAfter.Seconds()), callbackBatch)
+	if err != nil {
+		utils.Logline("error getting due callbacks", err)
+		return report, err
+	}
+	defer rows.Close()
+
+	var due []models.Callback
+	for rows.Next() {
+		callback, err := scanCallback(rows)
+		if err != nil {
+			utils.Logline("error scanning due callbacks", err)
+			return report, err
+		}
+		due = append(due, callback)
+	}
+	rows.Close()
+	report.Due = len(due)
+
+	// agentes libres por central y cola, un agente recibe una sola rellamada por ejecucion
+	free := make(map[string][]string)
+	busy := make(map[string]bool)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, callback := range due {
+		key := callback.Pbx + "/" + callback.Queue
+		if _, ok := free[key]; !ok {
+			free[key] = callbackFreeAgents(callback.Pbx, callback.Queue)
+			report.FreeAgents += len(free[key])
+		}
+
+		var agent string
+		for len(free[key]) > 0 && agent == "" {
+			candidate := free[key][0]
+			free[key] = free[key][1:]
+			if !busy[callback.Pbx+"/"+candidate] {
+				agent = candidate
+			}
+		}
+		if agent == "" {
+			continue
+		}
+		busy[callback.Pbx+"/"+agent] = true
+
+		attempt, err := startCallbackAttempt(db, callback, agent)
+		if err != nil {
+			if !errors.Is(err, ErrCallbackNotAvailable) {
+				report.Errors = append(report.Errors, fmt.Sprintf("%d: %v", callback.Id, err))
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(callback models.Callback, attempt models.CallbackAttempt) {
+			defer wg.Done()
+			attempt = dialCallback(db, callback, attempt, maxAttempts, ringTimeout)
+			mu.Lock()
+			report.Attempts = append(report.Attempts, attempt)
+			mu.Unlock()
+		}(callback, attempt)
+	}
+	wg.Wait()
+
+	report.FinishedAt = time.Now()
+	if report.Due > 0 {
+		utils.Logline(fmt.Sprintf("callbacks: %d due, %d free agents, %d attempts, %d errors",
+			report.Due, report.FreeAgents, len(report.Attempts), len(report.Errors)), report)
+	}
+	return report, nil
+}
+
+// callbackFreeAgents miembros de la cola que pueden recibir una rellamada
+func callbackFreeAgents(pbxName string, queue string) []string {
+	pbx, ok := utils.GetPbx(pbxName)
+	if !ok {
+		pbx = utils.DefaultPbx()
+	}
+
+	queues, err := getAmiQueueStatus(pbx, queue)
+	if err != nil {
+		utils.Logline("error getting queue members for callbacks", pbxName, queue, err)
+		return nil
+	}
+
+	var agents []string
+	for _, stats := range queues {
+		for _, member := range stats.Members {
+			if member.Paused || member.InCall || member.Status != "NotInUse" {
+				continue
+			}
+			if _, ok := callTracker.ActiveByAgent(pbx.Name, member.Extension); ok {
+				continue
+			}
+			agents = append(agents, member.Extension)
+		}
+	}
+	return agents
+}
+
+// startCallbackAttempt marca la rellamada como en curso y registra el intento
+func startCallbackAttempt(db models.ConnMysql, callback models.Callback, agent string) (models.CallbackAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	query := `UPDATE call_callbacks SET status = 'calling', claimed_by = ?, attempts = attempts + 1, datetime_last_attempt = NOW()
+		WHERE id = ? AND status = 'pending'`
+	res, err := db.Conn.ExecContext(ctx, query, agent, callback.Id)
+	if err != nil {
+		utils.Logline("Failed to start callback attempt", callback.Id, err)
+		return models.CallbackAttempt{}, fmt.Errorf("failed to start callback attempt")
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return models.CallbackAttempt{}, ErrCallbackNotAvailable
+	}
+
+	attempt := models.CallbackAttempt{IdCallback: callback.Id, IdCall: callback.IdCall, Agent: agent, Result: "calling"}
+	query = `INSERT INTO call_callback_attempts (id_callback, id_call, agent, uniqueid, datetime_attempt) VALUES (?, ?, ?, '', NOW())`
+	res, err = db.Conn.ExecContext(ctx, query, callback.Id, callback.IdCall, agent)
+	if err != nil {
+		utils.Logline("Failed to insert callback attempt", callback.Id, err)
+		db.Conn.ExecContext(ctx, `UPDATE call_callbacks SET status = 'pending', claimed_by = NULL WHERE id = ?`, callback.Id)
+		return attempt, fmt.Errorf("failed to insert callback attempt")
+	}
+	id, _ := res.LastInsertId()
+	attempt.Id = int(id)
+
+	// el ChannelId es el uniqueid del canal del agente, asi el intento se encuentra en el journal AMI
+	attempt.Uniqueid = fmt.Sprintf("callback-%d-%d", callback.Id, attempt.Id)
+	db.Conn.ExecContext(ctx, `UPDATE call_callback_attempts SET uniqueid = ? WHERE id = ?`, attempt.Uniqueid, attempt.Id)
+	return attempt, nil
+}
+
+// dialCallback origina la rellamada por el mismo camino que la click-to-call, el ActionID
+// es el uniqueid del intento. Si no se pudo encolar el intento termina fallido, si no
+// sigue en curso hasta su OriginateResponse (ver finishCallbackOriginate)
+func dialCallback(db models.ConnMysql, callback models.Callback, attempt models.CallbackAttempt, maxAttempts int, ringTimeout time.Duration) models.CallbackAttempt {
+	pbx, ok := utils.GetPbx(callback.Pbx)
+	if !ok {
+		pbx = utils.DefaultPbx()
+	}
+	context := os.Getenv("CALLBACK_CONTEXT")
+	if context == "" {
+		context = defaultCallbackContext
+	}
+	callerId := fmt.Sprintf(`"Callback %s" <%s>`, callback.Phone, callback.Phone)
+
+	// mismas validaciones del numero que la click-to-call, pudo pasar a dnc o a la lista
+	// negra despues del abandono y entonces la rellamada ya no se hace
+	err := checkDialable(db, callback.Phone)
+	if errors.Is(err, ErrPhoneNotAllowed) || errors.Is(err, ErrPhoneDnc) || errors.Is(err, ErrPhoneBlacklisted) {
+		closeCallbackNotDialable(db, callback, attempt, err)
+		attempt.Result = "failed"
+		reason := err.Error()
+		attempt.Reason = &reason
+		return attempt
+	}
+	if err == nil {
+		err = ErrOriginateAgent
+		if agentId := getAgentId(db, attempt.Agent); agentId != 0 {
+			originate := models.CallOriginate{ActionId: attempt.Uniqueid, Pbx: pbx.Name, Agent: attempt.Agent, Phone: callback.Phone, CallerId: callerId,
+				Status: models.OriginateQueued, RequestedBy: "service_callbacks"}
+			err = startOriginate(db, pbx, &originate, agentId, models.Originate{
+				Agent:       attempt.Agent,
+				Phone:       callback.Phone,
+				Context:     context,
+				CallerId:    callerId,
+				RingTimeout: ringTimeout,
+			})
+		}
+	}
+	if err == nil {
+		return attempt
+	}
+
+	attempt.Result = "failed"
+	reason := err.Error()
+	attempt.Reason = &reason
+	finishCallbackAttempt(db, callback, attempt, maxAttempts)
+	return attempt
+}
+
+// closeCallbackNotDialable cierra la rellamada cuyo numero ya no se puede marcar
+func closeCallbackNotDialable(db models.ConnMysql, callback models.Callback, attempt models.CallbackAttempt, reason error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	query := `UPDATE call_callback_attempts SET result = 'failed', reason = ?, datetime_result = NOW() WHERE id = ?`
+	if _, err := db.Conn.ExecContext(ctx, query, reason.Error(), attempt.Id); err != nil {
+		utils.Logline("Failed to update callback attempt", attempt, err)
+	}
+	query = `UPDATE call_callbacks SET status = 'closed', claimed_by = NULL, result = 'not_dialable', closed_by = 'system', datetime_closed = NOW()
+		WHERE id = ?`
+	if _, err := db.Conn.ExecContext(ctx, query, callback.Id); err != nil {
+		utils.Logline("Failed to close not dialable callback", callback.Id, err)
+	}
+	utils.Logline("callback closed, phone is not dialable", callback.Id, callback.Phone, reason)
+}
+
+// finishCallbackOriginate guarda el resultado del OriginateResponse en el intento de
+// rellamada, el Originate contestado es el agente que contesto
+func finishCallbackOriginate(db models.ConnMysql, actionId string, answered bool, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var callback models.Callback
+	attempt := models.CallbackAttempt{Uniqueid: actionId, Result: "failed"}
+	query := `SELECT id, id_callback, id_call, agent FROM call_callback_attempts WHERE uniqueid = ? AND result = 'calling'`
+	err := db.Conn.QueryRowContext(ctx, query, actionId).Scan(&attempt.Id, &attempt.IdCallback, &attempt.IdCall, &attempt.Agent)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.Logline("Failed to get callback attempt", actionId, err)
+		}
+		return
+	}
+	callback.Id = attempt.IdCallback
+
+	if answered {
+		attempt.Result = "answered"
+	} else {
+		attempt.Reason = &reason
+	}
+	finishCallbackAttempt(db, callback, attempt, envInt("CALLBACK_MAX_ATTEMPTS", defaultCallbackMaxAttempts))
+}
+
+// finishCallbackAttempt el agente que contesto se queda con la rellamada, si fallo vuelve
+// a pendiente o se cierra al agotar los intentos
+func finishCallbackAttempt(db models.ConnMysql, callback models.Callback, attempt models.CallbackAttempt, maxAttempts int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	query := `UPDATE call_callback_attempts SET result = ?, reason = ?, datetime_result = NOW() WHERE id = ?`
+	_, err := db.Conn.ExecContext(ctx, query, attempt.Result, attempt.Reason, attempt.Id)
+	if err != nil {
+		utils.Logline("Failed to update callback attempt", attempt, err)
+	}
+
+	if attempt.Result == "answered" {
+		query = `UPDATE call_callbacks SET status = 'claimed', datetime_claimed = NOW() WHERE id = ?`
+		_, err = db.Conn.ExecContext(ctx, query, callback.Id)
+	} else {
+		query = `UPDATE call_callbacks SET
+			status = IF(attempts >= ?, 'closed', 'pending'),
+			claimed_by = NULL,
+			result = IF(attempts >= ?, 'max_attempts', result),
+			closed_by = IF(attempts >= ?, 'system', closed_by),
+			datetime_closed = IF(attempts >= ?, NOW(), datetime_closed)
+			WHERE id = ?`
+		_, err = db.Conn.ExecContext(ctx, query, maxAttempts, maxAttempts, maxAttempts, maxAttempts, callback.Id)
+	}
+	if err != nil {
+		utils.Logline("Failed to update callback after attempt", callback.Id, err)
+		return fmt.Errorf("failed to update callback after attempt")
+	}
+	return nil
+}
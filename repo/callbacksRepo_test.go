@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+)
+
+// el abandono se registra con un solo upsert sobre la rellamada abierta del telefono
+func TestRegisterCallbackUpsert(t *testing.T) {
+	db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "FROM calls") {
+			return []string{"id", "phone"}, [][]driver.Value{{int64(42), "5551234"}}
+		}
+		return nil, nil
+	})
+
+	call := models.TrackedCall{Linkedid: "1700000000.1", Pbx: "central", Queue: "8000"}
+	if err := registerCallback(db, call); err != nil {
+		t.Fatal(err)
+	}
+	execs := fake.Execs("call_callbacks")
+	if len(execs) != 1 || !strings.Contains(execs[0].Query, "ON DUPLICATE KEY UPDATE") {
+		t.Fatalf("want a single upsert, got %+v", execs)
+	}
+	if !strings.Contains(execs[0].Query, "id_call = VALUES(id_call)") {
+		t.Error("the open callback should point to the last abandoned call")
+	}
+}
+
+// el OriginateResponse de una rellamada cierra su intento aunque la llamada no este rastreada
+func TestOriginateResponseFinishesCallbackAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		result   string
+		callback string
+	}{
+		{"answered", "Success", "answered", "status = 'claimed'"},
+		{"no answer", "Failure", "failed", "IF(attempts >= ?, 'closed', 'pending')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+				if strings.Contains(query, "FROM call_callback_attempts") {
+					return []string{"id", "id_callback", "id_call", "agent"}, [][]driver.Value{{int64(9), int64(3), int64(42), "8001"}}
+				}
+				return nil, nil
+			})
+
+			msg := goami2.NewMessage()
+			msg.AddField("Event", "OriginateResponse")
+			msg.AddField("ActionID", "callback-3-9")
+			msg.AddField("Response", tt.response)
+			msg.AddField("Reason", "3")
+			handleOriginateResponse(db, "central", msg)
+
+			attempts := fake.Execs("UPDATE call_callback_attempts")
+			if len(attempts) != 1 || attempts[0].Args[0] != tt.result {
+				t.Fatalf("attempt update = %+v, want result %s", attempts, tt.result)
+			}
+			if callbacks := fake.Execs(tt.callback); len(callbacks) != 1 {
+				t.Errorf("callback update %q not found", tt.callback)
+			}
+		})
+	}
+}
+
+// una rellamada cuyo numero paso a dnc o no cumple el patron se cierra sin originar
+func TestDialCallbackNotDialable(t *testing.T) {
+	tests := []struct {
+		name  string
+		phone string
+		dnc   bool
+	}{
+		{"dnc", "04141234567", true},
+		{"international", "0058414123456", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+				if strings.Contains(query, "dnc = 1") {
+					return []string{"dnc"}, [][]driver.Value{{tt.dnc}}
+				}
+				if strings.Contains(query, "call_blacklist") {
+					return []string{"blacklisted"}, [][]driver.Value{{false}}
+				}
+				return nil, nil
+			})
+
+			callback := models.Callback{Id: 3, Phone: tt.phone, Pbx: "central"}
+			attempt := models.CallbackAttempt{Id: 9, IdCallback: 3, Agent: "8001", Uniqueid: "callback-3-9", Result: "calling"}
+			attempt = dialCallback(db, callback, attempt, 3, time.Second)
+
+			if attempt.Result != "failed" {
+				t.Errorf("result = %s, want failed", attempt.Result)
+			}
+			if originates := fake.Execs("INSERT INTO call_originates"); len(originates) != 0 {
+				t.Errorf("not dialable callback was originated: %+v", originates)
+			}
+			if closed := fake.Execs("result = 'not_dialable'"); len(closed) != 1 {
+				t.Errorf("callback should be closed as not dialable")
+			}
+		})
+	}
+}
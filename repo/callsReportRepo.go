@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// CallsReport historico de llamadas rastreadas por el listener en un rango de fechas
+func CallsReport(db models.ConnMysql, req models.CallsReportReq) ([]models.CallReport, error) {
+	query := `SELECT c.id, c.uniqueid, c.pbx, c.direction, c.phone, a.number, c.status, c.fecha_llamada, c.start_time, c.end_time,
+			c.duration_wait, c.duration, c.hold_count, c.hold_seconds,
+			(SELECT COUNT(*) FROM call_transfers AS t WHERE t.id_call = c.id), COALESCE(c.resolved_by, a.number),
+			COALESCE(c.outcome, 'unknown'), c.hangup_by, c.hangup_cause, c.hangup_cause_txt, d.code, d.subcode
+		FROM calls AS c
+		LEFT JOIN agent AS a ON a.id = c.id_agent
+		LEFT JOIN call_dispositions AS d ON d.id_call = c.id
+		WHERE c.fecha_llamada >= ? AND c.fecha_llamada < DATE_ADD(?, INTERVAL 1 DAY) AND (? = '' OR a.number = ?)
+			AND (? = '' OR COALESCE(c.outcome, 'unknown') = ?)
+		ORDER BY c.fecha_llamada ASC
+		LIMIT 10000`
+
+	rows, err := db.Conn.QueryContext(db.Ctx, query, req.DateFrom, req.DateTo, req.Agent, req.Agent, req.Outcome, req.Outcome)
+	if err != nil {
+		utils.Logline("error getting calls report", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	calls := []models.CallReport{}
+	for rows.Next() {
+		var call models.CallReport
+		err := rows.Scan(&call.Id, &call.Uniqueid, &call.Pbx, &call.Direction, &call.Phone, &call.Agent, &call.Status, &call.CallDate,
+			&call.StartTime, &call.EndTime, &call.DurationWait, &call.Duration, &call.HoldCount, &call.HoldSeconds,
+			&call.Transfers, &call.ResolvedBy, &call.Outcome, &call.HangupBy, &call.HangupCause, &call.HangupTxt,
+			&call.Disposition, &call.Subcode)
+		if err != nil {
+			utils.Logline("error scanning calls report", err)
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+
+	return calls, rows.Err()
+}
+
+// CallsOutcome cantidad de llamadas por direccion, resultado y parte que colgo en un rango de fechas
+func CallsOutcome(db models.ConnMysql, req models.CallsReportReq) ([]models.CallsOutcome, error) {
+	query := `SELECT c.direction, COALESCE(c.outcome, 'unknown') AS outcome, COALESCE(c.hangup_by, '') AS hangup_by,
+			COUNT(*), COALESCE(SUM(c.duration), 0)
+		FROM calls AS c
+		LEFT JOIN agent AS a ON a.id = c.id_agent
+		WHERE c.fecha_llamada >= ? AND c.fecha_llamada < DATE_ADD(?, INTERVAL 1 DAY) AND (? = '' OR a.number = ?)
+			AND (? = '' OR COALESCE(c.outcome, 'unknown') = ?)
+		GROUP BY c.direction, outcome, hangup_by
+		ORDER BY c.direction, outcome, hangup_by`
+
+	rows, err := db.Conn.QueryContext(db.Ctx, query, req.DateFrom, req.DateTo, req.Agent, req.Agent, req.Outcome, req.Outcome)
+	if err != nil {
+		utils.Logline("error getting calls outcome", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	outcomes := []models.CallsOutcome{}
+	for rows.Next() {
+		var outcome models.CallsOutcome
+		if err := rows.Scan(&outcome.Direction, &outcome.Outcome, &outcome.HangupBy, &outcome.Total, &outcome.Seconds); err != nil {
+			utils.Logline("error scanning calls outcome", err)
+			return nil, err
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	return outcomes, rows.Err()
+}
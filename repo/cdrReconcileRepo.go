@@ -0,0 +1,417 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// valores por defecto de la conciliacion contra el CDR (task service_cdr_reconcile)
+const (
+	defaultCdrTable             = "asteriskcdrdb.cdr"
+	defaultCdrReconcileWindow   = 2 * time.Hour
+	defaultCdrReconcileDelay    = 10 * time.Minute // el CDR de una llamada se escribe al colgar cada tramo
+	defaultCdrDurationTolerance = 5                // segundos
+	cdrLookupBatch              = 500
+)
+
+var cdrTableRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+(\.[a-zA-Z0-9_]+)?$`)
+
+// ultimo reporte de la conciliacion, se expone en el endpoint de administracion
+var cdrReconcileMu sync.RWMutex
+var lastCdrReconcile *models.CdrReconcileReport
+
+// GetCdrReconcileReport retorna el resultado de la ultima conciliacion contra el CDR
+func GetCdrReconcileReport() (models.CdrReconcileReport, error) {
+	cdrReconcileMu.RLock()
+	defer cdrReconcileMu.RUnlock()
+	if lastCdrReconcile == nil {
+		return models.CdrReconcileReport{}, fmt.Errorf("cdr reconciliation has not run yet")
+	}
+	return *lastCdrReconcile, nil
+}
+
+// cdrTable CDR_TABLE, base.tabla del CDR de asterisk
+func cdrTable() (string, error) {
+	table := os.Getenv("CDR_TABLE")
+	if table == "" {
+		return defaultCdrTable, nil
+	}
+	if !cdrTableRegex.MatchString(table) {
+		return "", fmt.Errorf("invalid CDR_TABLE %q", table)
+	}
+	return table, nil
+}
+
+// cdrPbx CDR_PBX, central duena del CDR, por defecto la primera central
+func cdrPbx() string {
+	if pbx := os.Getenv("CDR_PBX"); pbx != "" {
+		return pbx
+	}
+	return utils.DefaultPbx().Name
+}
+
+// calls columnas de calls usadas para comparar contra el CDR
+type cdrCallsRow struct {
+	id       int
+	uniqueid string
+	pbx      string
+	status   string
+	outcome  string
+	duration int
+	open     bool
+}
+
+// CdrReconcile compara las llamadas del CDR de asterisk en la ventana indicada contra calls.
+// Las llamadas abiertas que el CDR ya termino, el estado final y la duracion se corrigen,
+// las que faltan en calls solo se registran. Con DryRun no se modifica calls. Cada ejecucion
+// queda en call_cdr_reconcile_runs y sus diferencias en call_cdr_reconcile_issues
+func CdrReconcile(db models.ConnMysql, cdr models.ConnMysql, req models.CdrReconcileReq) (models.CdrReconcileReport, error) {
+	report := models.CdrReconcileReport{StartedAt: time.Now(), DryRun: req.DryRun, Issues: []models.CdrIssue{}}
+
+	table, err := cdrTable()
+	if err != nil {
+		return finishCdrReconcile(db, report), err
+	}
+
+	// ventana por defecto relativa a la hora de la base de datos
+	report.WindowFrom, report.WindowTo = req.DateFrom, req.DateTo
+	if report.WindowFrom == "" {
+		delay := envDuration("CDR_RECONCILE_DELAY", defaultCdrReconcileDelay)
+		window := envDuration("CDR_RECONCILE_WINDOW", defaultCdrReconcileWindow)
+		query := `SELECT DATE_FORMAT(NOW() - INTERVAL ? SECOND, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(NOW() - INTERVAL ? SECOND, '%Y-%m-%d %H:%i:%s')`
+		err := cdr.Conn.QueryRowContext(cdr.Ctx, query, int((delay+window).Seconds()), int(delay.Seconds())).Scan(&report.WindowFrom, &report.WindowTo)
+		if err != nil {
+			utils.Logline("error getting cdr reconcile window", err)
+			return finishCdrReconcile(db, report), err
+		}
+	}
+
+	cdrCalls, err := readCdrCalls(cdr, table, report.WindowFrom, report.WindowTo)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return finishCdrReconcile(db, report), err
+	}
+	report.CdrCalls = len(cdrCalls)
+
+	calls, err := cdrLookupCalls(db, cdrCalls)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return finishCdrReconcile(db, report), err
+	}
+
+	rules := activeRules.Load()
+	tolerance := envInt("CDR_DURATION_TOLERANCE", defaultCdrDurationTolerance)
+	for _, cdrCall := range cdrCalls {
+		row, ok := calls[cdrCall.Linkedid]
+		for _, uniqueId := range cdrCall.Uniqueids {
+			if ok {
+				break
+			}
+			row, ok = calls[uniqueId]
+		}
+
+		if !ok {
+			if !cdrTracked(rules, cdrCall) {
+				report.Untracked++
+				continue
+			}
+			report.Missing++
+			report.Issues = append(report.Issues, models.CdrIssue{Uniqueid: cdrCall.Linkedid, Issue: models.CdrMissingCall,
+				CdrValue: fmt.Sprintf("%s -> %s %s %s", cdrCall.Src, cdrCall.Dst, cdrCall.StartedAt.Format(time.DateTime), cdrCall.Disposition),
+				Action:   models.CdrFlagged})
+			continue
+		}
+		report.Matched++
+
+		// la sigue el listener, si de verdad quedo huerfana la cierra el janitor
+		if tracked, live := callTracker.Get(row.pbx, row.uniqueid); live && !tracked.State.IsFinal() {
+			continue
+		}
+
+		status, outcome, duration := cdrExpected(rules, cdrCall)
+		switch {
+		case row.open:
+			issue := models.CdrIssue{Uniqueid: row.uniqueid, Issue: models.CdrOpenCall, CallsValue: row.status,
+				CdrValue: fmt.Sprintf("%s %s", outcome, cdrCall.EndedAt.Format(time.DateTime))}
+			issue = cdrFix(db, &report, issue, func(ctx context.Context) error {
+				return cdrCloseCall(db, ctx, row, cdrCall, status, outcome, duration)
+			})
+			report.Issues = append(report.Issues, issue)
+			if issue.Action != models.CdrFlagged {
+				report.OpenFixed++
+			}
+		case row.status == "Cerrada por sistema" || cdrAnswered(row) != cdrCall.Answered:
+			issue := models.CdrIssue{Uniqueid: row.uniqueid, Issue: models.CdrWrongStatus, CallsValue: fmt.Sprintf("%s/%s", row.status, row.outcome),
+				CdrValue: fmt.Sprintf("%s/%s", status, outcome)}
+			issue = cdrFix(db, &report, issue, func(ctx context.Context) error {
+				query := `UPDATE calls SET status = ?, outcome = ?, duration = ? WHERE id = ?`
+				_, err := db.Conn.ExecContext(ctx, query, status, outcome, duration, row.id)
+				return err
+			})
+			report.Issues = append(report.Issues, issue)
+			if issue.Action != models.CdrFlagged {
+				report.StatusFixed++
+			}
+		case cdrCall.Answered && abs(row.duration-duration) > tolerance:
+			issue := models.CdrIssue{Uniqueid: row.uniqueid, Issue: models.CdrWrongDuration, CallsValue: fmt.Sprint(row.duration),
+				CdrValue: fmt.Sprint(duration)}
+			issue = cdrFix(db, &report, issue, func(ctx context.Context) error {
+				_, err := db.Conn.ExecContext(ctx, `UPDATE calls SET duration = ? WHERE id = ?`, duration, row.id)
+				return err
+			})
+			report.Issues = append(report.Issues, issue)
+			if issue.Action != models.CdrFlagged {
+				report.DurationFixed++
+			}
+		}
+	}
+
+	return finishCdrReconcile(db, report), nil
+}
+
+// readCdrCalls registros del CDR que iniciaron en la ventana agrupados por linkedid,
+// sin linkedid (CDR de asterisk viejos) cada uniqueid es una llamada
+func readCdrCalls(cdr models.ConnMysql, table string, from string, to string) ([]models.CdrCall, error) {
+	query := `SELECT COALESCE(NULLIF(linkedid, ''), uniqueid), uniqueid, src, dst, dcontext, dstchannel, lastapp, disposition,
+			UNIX_TIMESTAMP(calldate), duration, billsec
+		FROM ` + table + `
+		WHERE calldate >= ? AND calldate < ?
+		ORDER BY calldate ASC, uniqueid ASC`
+	rows, err := cdr.Conn.QueryContext(cdr.Ctx, query, from, to)
+	if err != nil {
+		utils.Logline("error getting cdr", err)
+		return nil, fmt.Errorf("failed to read cdr: %v", err)
+	}
+	defer rows.Close()
+
+	var order []string
+	byLinkedid := make(map[string]*models.CdrCall)
+	answeredEnd := make(map[string]time.Time) // final del ultimo tramo contestado
+	for rows.Next() {
+		var linkedId, uniqueId, src, dst, dcontext, dstChannel, lastApp, disposition string
+		var calldate int64
+		var duration, billsec int
+		err := rows.Scan(&linkedId, &uniqueId, &src, &dst, &dcontext, &dstChannel, &lastApp, &disposition, &calldate, &duration, &billsec)
+		if err != nil {
+			utils.Logline("error scanning cdr", err)
+			return nil, fmt.Errorf("failed to read cdr: %v", err)
+		}
+
+		call, ok := byLinkedid[linkedId]
+		if !ok {
+			call = &models.CdrCall{Linkedid: linkedId, Src: src, Dst: dst, Context: dcontext, StartedAt: time.Unix(calldate, 0), Disposition: disposition}
+			byLinkedid[linkedId] = call
+			order = append(order, linkedId)
+		}
+		if !slices.Contains(call.Uniqueids, uniqueId) {
+			call.Uniqueids = append(call.Uniqueids, uniqueId)
+		}
+		if lastApp == "Queue" && call.Queue == "" {
+			call.Queue = dst
+		}
+
+		endedAt := time.Unix(calldate+int64(duration), 0)
+		if endedAt.After(call.EndedAt) {
+			call.EndedAt = endedAt
+		}
+
+		// tramo contestado por un agente o por el destino marcado, la cola contesta al cliente sin dstchannel
+		if disposition == "ANSWERED" && dstChannel != "" && billsec > 0 {
+			answeredAt := time.Unix(calldate+int64(duration-billsec), 0)
+			if !call.Answered || answeredAt.Before(call.AnsweredAt) {
+				call.AnsweredAt = answeredAt
+			}
+			if endedAt.After(answeredEnd[linkedId]) {
+				answeredEnd[linkedId] = endedAt
+			}
+			call.Answered = true
+			call.Disposition = disposition
+			call.Talk = int(answeredEnd[linkedId].Sub(call.AnsweredAt).Seconds())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cdr: %v", err)
+	}
+
+	calls := make([]models.CdrCall, 0, len(order))
+	for _, linkedId := range order {
+		calls = append(calls, *byLinkedid[linkedId])
+	}
+	return calls, nil
+}
+
+// cdrLookupCalls filas de calls de las llamadas del CDR por linkedid o por el uniqueid de sus tramos
+func cdrLookupCalls(db models.ConnMysql, cdrCalls []models.CdrCall) (map[string]cdrCallsRow, error) {
+	var keys []any
+	for _, call := range cdrCalls {
+		keys = append(keys, call.Linkedid)
+		for _, uniqueId := range call.Uniqueids {
+			if uniqueId != call.Linkedid {
+				keys = append(keys, uniqueId)
+			}
+		}
+	}
+
+	// el uniqueid solo es unico dentro de la central del CDR
+	pbx := cdrPbx()
+	calls := make(map[string]cdrCallsRow)
+	for start := 0; start < len(keys); start += cdrLookupBatch {
+		batch := keys[start:min(start+cdrLookupBatch, len(keys))]
+		query := `SELECT id, uniqueid, COALESCE(status, ''), COALESCE(outcome, ''), COALESCE(duration, 0), end_time IS NULL
+			FROM calls WHERE pbx = ? AND uniqueid IN (?` + strings.Repeat(", ?", len(batch)-1) + `)`
+		args := append([]any{pbx}, batch...)
+		rows, err := db.Conn.QueryContext(db.Ctx, query, args...)
+		if err != nil {
+			utils.Logline("error getting calls to reconcile", err)
+			return nil, fmt.Errorf("failed to read calls: %v", err)
+		}
+
+		for rows.Next() {
+			var row cdrCallsRow
+			if err := rows.Scan(&row.id, &row.uniqueid, &row.status, &row.outcome, &row.duration, &row.open); err != nil {
+				rows.Close()
+				utils.Logline("error scanning calls to reconcile", err)
+				return nil, fmt.Errorf("failed to read calls: %v", err)
+			}
+			row.pbx = pbx
+			calls[row.uniqueid] = row
+		}
+		rows.Close()
+	}
+	return calls, nil
+}
+
+// cdrTracked indica si la llamada del CDR debio quedar en calls segun las reglas de rastreo
+func cdrTracked(rules *callRules, call models.CdrCall) bool {
+	if call.Queue != "" {
+		_, ok := rules.inboundQueue(call.Queue)
+		return ok
+	}
+	return rules.isAgent(call.Src) && rules.tracksContext(call.Context) && !rules.isExcludedContext(call.Context)
+}
+
+// cdrAnswered indica si calls tiene la llamada como atendida
+func cdrAnswered(row cdrCallsRow) bool {
+	if row.outcome != "" {
+		return row.outcome == models.OutcomeAnswered
+	}
+	return row.status == "Finalizada"
+}
+
+// cdrExpected estado, resultado y duracion de calls segun el CDR, igual que finishCall la
+// atendida dura lo hablado y la no atendida lo que estuvo repicando
+func cdrExpected(rules *callRules, call models.CdrCall) (string, string, int) {
+	if call.Answered {
+		return "Finalizada", models.OutcomeAnswered, call.Talk
+	}
+
+	outcome := models.OutcomeNoAnswer
+	switch {
+	case call.Queue != "":
+		outcome = models.OutcomeAbandoned
+	case call.Disposition == "BUSY":
+		outcome = models.OutcomeBusy
+	case call.Disposition == "CONGESTION":
+		outcome = models.OutcomeCongestion
+	case call.Disposition == "FAILED":
+		outcome = models.OutcomeFailed
+	}
+	return "Sin respuesta", outcome, int(call.EndedAt.Sub(call.StartedAt).Seconds())
+}
+
+// cdrCloseCall cierra la llamada que quedo abierta con los datos del CDR
+func cdrCloseCall(db models.ConnMysql, ctx context.Context, row cdrCallsRow, call models.CdrCall, status string, outcome string, duration int) error {
+	query := `UPDATE calls SET status = ?, outcome = ?, duration = ?, end_time = FROM_UNIXTIME(?),
+			start_time = IF(? AND start_time IS NULL, FROM_UNIXTIME(?), start_time)
+		WHERE id = ? AND end_time IS NULL`
+	_, err := db.Conn.ExecContext(ctx, query, status, outcome, duration, call.EndedAt.Unix(), call.Answered, call.AnsweredAt.Unix(), row.id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Conn.ExecContext(ctx, `DELETE FROM current_calls WHERE id_call = ?`, row.id); err != nil {
+		return err
+	}
+	callTracker.Remove(row.pbx, row.uniqueid)
+	return nil
+}
+
+// cdrFix aplica la correccion, en dry run solo se marca. Si falla el problema queda registrado
+func cdrFix(db models.ConnMysql, report *models.CdrReconcileReport, issue models.CdrIssue, fix func(ctx context.Context) error) models.CdrIssue {
+	if report.DryRun {
+		issue.Action = models.CdrDryRun
+		return issue
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := fix(ctx); err != nil {
+		utils.Logline("Failed to fix call from cdr", issue, err)
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", issue.Uniqueid, err))
+		issue.Action = models.CdrFlagged
+		return issue
+	}
+	issue.Action = models.CdrFixed
+	return issue
+}
+
+// finishCdrReconcile guarda el resumen de la ejecucion y sus diferencias
+func finishCdrReconcile(db models.ConnMysql, report models.CdrReconcileReport) models.CdrReconcileReport {
+	report.FinishedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO call_cdr_reconcile_runs (datetime_start, datetime_end, dry_run, window_from, window_to, cdr_calls, matched,
+			untracked, missing, open_fixed, status_fixed, duration_fixed, errors)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`
+	res, err := db.Conn.ExecContext(ctx, query, report.StartedAt.Format(time.DateTime), report.FinishedAt.Format(time.DateTime), report.DryRun,
+		report.WindowFrom, report.WindowTo, report.CdrCalls, report.Matched, report.Untracked, report.Missing, report.OpenFixed,
+		report.StatusFixed, report.DurationFixed, truncate(strings.Join(report.Errors, "; "), 1000))
+	if err != nil {
+		utils.Logline("Failed to insert call_cdr_reconcile_runs", err)
+	} else {
+		report.Id, _ = res.LastInsertId()
+		query = `INSERT INTO call_cdr_reconcile_issues (id_run, uniqueid, issue, calls_value, cdr_value, action) VALUES (?, ?, ?, ?, ?, ?)`
+		for _, issue := range report.Issues {
+			_, err := db.Conn.ExecContext(ctx, query, report.Id, issue.Uniqueid, issue.Issue, truncate(issue.CallsValue, 255),
+				truncate(issue.CdrValue, 255), issue.Action)
+			if err != nil {
+				utils.Logline("Failed to insert call_cdr_reconcile_issues", issue, err)
+				break
+			}
+		}
+	}
+
+	utils.Logline(fmt.Sprintf("cdr reconcile %s - %s: %d cdr calls, %d matched, %d missing, %d open fixed, %d status fixed, %d duration fixed, dry run %v",
+		report.WindowFrom, report.WindowTo, report.CdrCalls, report.Matched, report.Missing, report.OpenFixed, report.StatusFixed,
+		report.DurationFixed, report.DryRun))
+
+	cdrReconcileMu.Lock()
+	lastCdrReconcile = &report
+	cdrReconcileMu.Unlock()
+	return report
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func truncate(text string, size int) string {
+	if len(text) > size {
+		return text[:size]
+	}
+	return text
+}
@@ -2,7 +2,6 @@ package repo
 
 import (
 	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/staskobzar/goami2"
@@ -11,91 +10,170 @@ import (
 	"ired.com/callcenter/utils"
 )
 
-// Global variables
-var trackList = make(map[string]bool)  // list of all calls
-var activeList = make(map[string]bool) // list of active calls (atendidas)
-
-func AmiEvents(db models.ConnMysql, caller string) error {
-	// Connect to Asterisk AMI
-	clientAmi, err := utils.ConnectToAmi()
-	if err != nil {
-		return err
-	}
-	defer clientAmi.Close()
-
-	utils.Logline("Starting AMI events service")
-	for {
-		select {
-		case msg := <-clientAmi.AllMessages():
-			if msg != nil {
-				handleEvent(db, msg)
-			}
-		case err := <-clientAmi.Err():
-			utils.Logline("error on ami", err)
-			return fmt.Errorf("an error occurred executing ami command")
-		}
-	}
-}
-
 // funcion principal para lectura de eventos
-// newChannel llamadaNueva solo las que inician con la extension 80*
-// Hangup Colgar llamada de cualquiera de las dos partes
-// BridgeEnter evento cuando atienden llamada
+// las salientes se rastrean por canales y bridges, las entrantes por eventos de la
+// cola (ver queueCallsRepo.go)
+// newChannel llamadaNueva solo las que inician en extensiones de agentes (reglas en .callrules)
+// DialBegin la contraparte esta repicando
+// Hangup Colgar llamada, solo la cierra el agente que la atiende en ese momento, el
+// primer Hangup de cualquier canal indica la causa y quien colgo (ver hangupCallsRepo.go).
+// Una transferida cuyo destino nunca atendio se cierra al colgar su ultimo canal y una
+// entrante que salio de la cola sin ser atendida al colgar el cliente
+// BridgeEnter evento cuando atienden llamada o entra el destino de una transferencia
 // BridgeLeave evento cuando la llamada termina
-func handleEvent(db models.ConnMysql, msg *goami2.Message) {
+// Hold/Unhold y MusicOnHoldStart/Stop esperas de la llamada (ver holdCallsRepo.go)
+// BlindTransfer/AttendedTransfer patas de transferencia (ver transferCallsRepo.go)
+// VarSet/MixMonitorStart archivo de grabacion de la llamada (ver recordingsRepo.go)
+// ExtensionStatus/DeviceStateChange cache de estados de extensiones (ver extensionStateRepo.go)
+// OriginateResponse resultado de las llamadas click-to-call (ver originateRepo.go)
+// QueueMemberPause solo se publica en el bus (ver eventBusRepo.go)
+// QueueMemberAdded/QueueMemberRemoved cache de miembros de cola (ver queueMembersRepo.go)
+// el estado de cada llamada vive en callTracker, ver callStateRepo.go
+func handleEvent(db models.ConnMysql, pbx string, msg *goami2.Message) {
 	uniqueId := msg.Field("Uniqueid")
-	linkedId := msg.Field("Linkedid")
+	ownChannel := uniqueId == msg.Field("Linkedid") // canal que origino el Linkedid
+	linkedId := callTracker.Resolve(pbx, msg.Field("Linkedid"))
 	context := msg.Field("Context")
+	rules := activeRules.Load()
+
+	if !msg.IsEvent() {
+		return
+	}
 
-	if msg.IsEvent() {
-		switch msg.Field("Event") {
-		case "Newchannel":
-			if trackList[linkedId] || uniqueId != linkedId {
+	switch msg.Field("Event") {
+	case "Newchannel":
+		callTracker.ChannelUp(pbx, msg.Field("Linkedid"))
+		if !ownChannel || !rules.tracksContext(context) {
+			return
+		}
+		if rules.isAgent(msg.Field("CallerIDNum")) {
+			call := models.TrackedCall{Linkedid: linkedId, Pbx: pbx, Direction: models.DirectionOutbound, Agent: msg.Field("CallerIDNum")}
+			if !callTracker.Start(call) {
 				return
 			}
-			if match, _ := regexp.MatchString("^80.*", msg.Field("CallerIDNum")); match {
-				utils.Logline("new event [newchannel] ", msg)
-				trackList[linkedId] = true
-				insertCall(db, msg)
+			utils.Logline("new event [newchannel] ", msg)
+			if err := insertCall(db, msg, rules.cfg.Outbound, pbx); err != nil {
+				callTracker.Remove(pbx, linkedId)
+				return
 			}
-		case "Hangup":
-			if context != "tc-maint" && trackList[linkedId] && !activeList[linkedId] {
-				if match, _ := regexp.MatchString("^80.*", msg.Field("CallerIDNum")); match {
-					utils.Logline("new event [hangup] ", msg)
-					if err := endCall(db, msg); err != nil {
-						delete(trackList, linkedId)
-					}
+			publishCall(models.BusCallStarted, pbx, linkedId)
+		}
+	case "DialBegin":
+		if isOutboundCall(pbx, linkedId) && ownChannel {
+			fireCallEvent(pbx, linkedId, models.EventDial, msg)
+		}
+	case "Hangup":
+		remaining := callTracker.ChannelDown(pbx, msg.Field("Linkedid"))
+		call, ok := callTracker.Get(pbx, linkedId)
+		if rules.isExcludedContext(context) || !ok {
+			return
+		}
+		recordHangup(db, pbx, msg, linkedId, ownChannel, rules)
+		if !tracksChannels(call) {
+			// la entrante que salio de la cola sin ser atendida (tiempo de espera, tecla de
+			// salida) no tiene QueueCallerAbandon, termina cuando cuelga el cliente
+			if ownChannel && call.Direction == models.DirectionInbound && call.AnsweredAt == nil && !call.State.IsFinal() {
+				from, to, ok := fireCallEvent(pbx, linkedId, models.EventAbandon, msg)
+				if ok && to.IsFinal() && !from.IsFinal() {
+					utils.Logline("new event [hangup] inbound call left the queue unanswered ", msg)
+					callTracker.Update(pbx, linkedId, func(call *models.TrackedCall) { call.Outcome = models.OutcomeAbandoned })
+					endCall(db, pbx, msg, linkedId, to)
 				}
 			}
-		case "BridgeEnter":
-			if trackList[linkedId] && uniqueId != linkedId {
-				utils.Logline("new event [bridgeenter] ", msg)
-				if err := bridgeEnterCall(db, msg); err != nil {
-					activeList[linkedId] = true
-				}
+			return
+		}
+		// despues de una transferencia el agente original cuelga y la llamada sigue, en las
+		// originadas el canal del agente lleva el caller id del cliente
+		agentLeg := rules.isAgent(msg.Field("CallerIDNum")) && msg.Field("CallerIDNum") == call.Agent
+		if agentLeg || (call.Originated && ownChannel && call.Transfers == 0) {
+			from, to, ok := fireCallEvent(pbx, linkedId, models.EventHangup, msg)
+			if ok && to.IsFinal() && !from.IsFinal() {
+				utils.Logline("new event [hangup] ", msg)
+				endCall(db, pbx, msg, linkedId, to)
 			}
-		case "BridgeLeave":
-			if activeList[linkedId] && uniqueId != linkedId {
-				utils.Logline("new event [bridgeleave] ", msg)
-				if err := endCall(db, msg); err != nil {
-					delete(activeList, linkedId)
-					delete(trackList, linkedId)
-				}
+		}
+		// transferida a un destino que nunca atendio, termina al colgar su ultimo canal
+		if call, _ := callTracker.Get(pbx, linkedId); remaining == 0 && call.State == models.CallTransferred {
+			from, to, ok := fireCallEvent(pbx, linkedId, models.EventChannelsGone, msg)
+			if ok && to.IsFinal() && !from.IsFinal() {
+				utils.Logline("new event [hangup] last channel of transferred call ", msg)
+				endCall(db, pbx, msg, linkedId, to)
 			}
 		}
+	case "BridgeEnter":
+		call, ok := callTracker.Get(pbx, linkedId)
+		if !ok || !tracksChannels(call) || ownChannel {
+			return
+		}
+		from, to, ok := fireCallEvent(pbx, linkedId, models.EventBridgeEnter, msg)
+		if ok && to == models.CallActive && from != models.CallActive {
+			utils.Logline("new event [bridgeenter] ", msg)
+			bridgeEnterCall(db, pbx, msg, linkedId, from)
+		}
+	case "BridgeLeave":
+		call, ok := callTracker.Get(pbx, linkedId)
+		if !ok || !tracksChannels(call) || ownChannel {
+			return
+		}
+		from, to, ok := fireCallEvent(pbx, linkedId, models.EventBridgeLeave, msg)
+		if ok && to.IsFinal() && !from.IsFinal() {
+			utils.Logline("new event [bridgeleave] ", msg)
+			endCall(db, pbx, msg, linkedId, to)
+		}
+	case "Hold", "MusicOnHoldStart":
+		handleHoldEvent(db, pbx, msg, linkedId, models.EventHold)
+	case "Unhold", "MusicOnHoldStop":
+		handleHoldEvent(db, pbx, msg, linkedId, models.EventUnhold)
+	case "VarSet":
+		if msg.Field("Variable") == "MIXMONITOR_FILENAME" {
+			handleRecordingEvent(db, pbx, msg, linkedId, msg.Field("Value"))
+		}
+	case "MixMonitorStart":
+		if filename, ok := msg.Var("MIXMONITOR_FILENAME"); ok {
+			handleRecordingEvent(db, pbx, msg, linkedId, filename)
+		}
+	case "ExtensionStatus", "DeviceStateChange":
+		handleExtensionEvent(pbx, msg)
+	case "QueueMemberPause", "QueueMemberPaused":
+		publishQueueMemberPause(pbx, msg)
+	case "QueueMemberAdded", "QueueMemberRemoved":
+		handleQueueMemberEvent(pbx, msg)
+	case "BlindTransfer", "AttendedTransfer":
+		handleTransferEvent(db, pbx, msg, rules)
+	case "OriginateResponse":
+		handleOriginateResponse(db, pbx, msg)
+	case "QueueCallerJoin", "AgentCalled", "AgentRingNoAnswer", "AgentConnect", "AgentComplete", "QueueCallerAbandon":
+		handleQueueEvent(db, pbx, msg, linkedId, rules)
 	}
 }
 
-func insertCall(db models.ConnMysql, msg *goami2.Message) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+// tracksChannels indica si el ciclo de la llamada se sigue por canales y bridges,
+// las salientes siempre y las entrantes solo cuando fueron transferidas fuera de la cola
+func tracksChannels(call models.TrackedCall) bool {
+	return call.Direction == models.DirectionOutbound || call.Transfers > 0
+}
+
+// isOutboundCall indica si el Linkedid es una llamada saliente rastreada
+func isOutboundCall(pbx string, linkedId string) bool {
+	call, ok := callTracker.Get(pbx, linkedId)
+	return ok && call.Direction == models.DirectionOutbound
+}
+
+// fireCallEvent aplica la transicion en el registro y deja en el log las invalidas
+func fireCallEvent(pbx string, linkedId string, ev models.CallEvent, msg *goami2.Message) (models.CallState, models.CallState, bool) {
+	from, to, err := callTracker.Fire(pbx, linkedId, ev)
+	if err != nil {
+		utils.Logline("invalid call transition", err, msg)
+		return from, to, false
+	}
+	return from, to, true
+}
 
-	uniqueIdDb := msg.Field("Linkedid")
+func insertCall(db models.ConnMysql, msg *goami2.Message, rule models.OutboundRules, pbx string) error {
 	callerIdNum := msg.Field("CallerIDNum")
-	channel := msg.Field("Channel")
 
 	channelClient := "-"
-	if msg.Field("Context") == "from-internal" {
+	if msg.Field("Context") == rule.DialedNumberContext {
 		channelClient = msg.Field("Exten")
 	}
 
@@ -105,126 +183,123 @@ func insertCall(db models.ConnMysql, msg *goami2.Message) error {
 		return fmt.Errorf("failed to insert call")
 	}
 
-	query := `INSERT INTO calls (id_campaign, phone, status, uniqueid, fecha_llamada, retries, id_agent, datetime_entry_queue, duration_wait, dnc, datetime_originate, trunk, scheduled)
-		VALUES (1, ?, 'Ringing', ?, NOW(), 0, ?, NOW(), 0, 0, NOW(), ?, 0)`
-	_, err := db.Conn.QueryContext(ctx, query, callerIdNum, uniqueIdDb, agentId, channelClient)
+	return insertOutboundCall(db, msg.Field("Linkedid"), agentId, callerIdNum, msg.Field("Channel"), channelClient, rule, pbx)
+}
+
+// insertOutboundCall registra la saliente en calls y current_calls, phone es la extension
+// del agente y trunk el numero marcado
+func insertOutboundCall(db models.ConnMysql, uniqueIdDb string, agentId int, agent string, channel string, channelClient string, rule models.OutboundRules, pbx string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO calls (id_campaign, phone, status, uniqueid, fecha_llamada, retries, id_agent, datetime_entry_queue, duration_wait, dnc, datetime_originate, trunk, scheduled, direction, pbx)
+		VALUES (?, ?, 'Ringing', ?, NOW(), 0, ?, NOW(), 0, 0, NOW(), ?, 0, 'outbound', ?)`
+	_, err := db.Conn.ExecContext(ctx, query, rule.CampaignId, agent, uniqueIdDb, agentId, channelClient, pbx)
 	if err != nil {
-		utils.Logline("Failed to insert call: ", msg, err)
+		utils.Logline("Failed to insert call: ", uniqueIdDb, err)
 		return fmt.Errorf("failed to insert call")
 	}
 
 	var callId string
-	err = db.Conn.QueryRowContext(ctx, `SELECT id FROM calls WHERE uniqueid=?`, uniqueIdDb).Scan(&callId)
+	err = db.Conn.QueryRowContext(ctx, `SELECT id FROM calls WHERE pbx = ? AND uniqueid = ?`, pbx, uniqueIdDb).Scan(&callId)
 	if err != nil {
-		utils.Logline("Failed to insert call: ", msg, err)
+		utils.Logline("Failed to insert call: ", uniqueIdDb, err)
 		return fmt.Errorf("failed to insert call")
 	}
 
 	query = `INSERT INTO current_calls (id_call, fecha_inicio, uniqueid, queue, agentnum, event, Channel, ChannelClient, hold)
-		VALUES (?, NOW(), ?, '8000', ?, 'Dialing', ?, ?, 'N')`
-	_, err = db.Conn.QueryContext(ctx, query, callId, uniqueIdDb, callerIdNum, channel, channelClient)
+		VALUES (?, NOW(), ?, ?, ?, 'Dialing', ?, ?, 'N')`
+	_, err = db.Conn.ExecContext(ctx, query, callId, uniqueIdDb, rule.Queue, agent, channel, channelClient)
 	if err != nil {
-		utils.Logline("Failed to insert current_call: ", msg, err)
+		utils.Logline("Failed to insert current_call: ", uniqueIdDb, err)
 		return fmt.Errorf("failed to insert current_call")
 	}
 
 	return nil
 }
 
-func bridgeEnterCall(db models.ConnMysql, msg *goami2.Message) error {
+// bridgeEnterCall marca la llamada como atendida, si venia de una transferencia
+// el canal que entra al bridge es el destino y pasa a ser el agente de la llamada
+func bridgeEnterCall(db models.ConnMysql, pbx string, msg *goami2.Message, uniqueIdDb string, from models.CallState) error {
+	if from == models.CallTransferred {
+		return transferLegAnswered(db, pbx, msg, uniqueIdDb, msg.Field("CallerIDNum"))
+	}
+	publishCall(models.BusCallAnswered, pbx, uniqueIdDb)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	uniqueIdDb := msg.Field("Linkedid")
-
-	var err error
-	var callId, phone, callStatus string
-	err = db.Conn.QueryRowContext(ctx, "SELECT id, phone, LOWER(status) FROM calls WHERE uniqueid=?", uniqueIdDb).Scan(&callId, &phone, &callStatus)
+	query := `UPDATE calls SET status = 'Active', start_time = NOW(), duration_wait = TIMESTAMPDIFF(SECOND, fecha_llamada, NOW()) WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
 	if err != nil {
-		utils.Logline("Failed to read status of call in db: ", msg, err)
-		return fmt.Errorf("failed to read status of call in db")
+		utils.Logline("Failed to start call", msg, err)
+		return fmt.Errorf("failed to start call")
 	}
 
-	var query string
-	// el primer IF sirve para evaluar cuando la llamada fue finalizada por que la transfirieron
-	if callStatus == "finalizada" || callStatus == "sin respuesta" {
-		query = `UPDATE calls SET status='Active', transfer=? WHERE uniqueid = ?`
-		_, err = db.Conn.QueryContext(ctx, query, msg.Field("CallerIDNum"), uniqueIdDb)
-		if err != nil {
-			utils.Logline("failed to update previous uniqueid call on db", msg, err)
-			return fmt.Errorf("failed to update previous uniqueid call on db")
-		}
+	query = `UPDATE current_calls AS cc INNER JOIN calls AS c ON c.id = cc.id_call SET cc.event = 'Link' WHERE c.pbx = ? AND c.uniqueid = ?`
+	_, err = db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to start current_call", msg, err)
+		return fmt.Errorf("failed to start current_call")
+	}
 
-		channel := msg.Field("Channel")
-		channelClient := msg.Field("ConnectedLineNum")
+	return nil
+}
 
-		query = `INSERT INTO current_calls (id_call, fecha_inicio, uniqueid, queue, agentnum, event, Channel, ChannelClient, hold)
-			VALUES (?, NOW(), ?, '8000', ?, 'Dialing', ?, ?, 'N')`
-		_, err = db.Conn.QueryContext(ctx, query, callId, uniqueIdDb, phone, channel, channelClient)
-		if err != nil {
-			utils.Logline("Failed to insert current_call: ", msg, err)
-			return fmt.Errorf("failed to insert current_call")
+func endCall(db models.ConnMysql, pbx string, msg *goami2.Message, uniqueIdDb string, state models.CallState) error {
+	// la llamada pudo terminar estando en espera, se guardan los acumulados finales
+	if call, ok := callTracker.Get(pbx, uniqueIdDb); ok {
+		if call.HoldCount > 0 {
+			holdCall(db, call)
 		}
+		outcomeCall(db, call)
+		publishCallEnded(call)
 
-		trackList[msg.Field("Linkedid")] = true
-		activeList[msg.Field("Linkedid")] = true
-	} else {
-		query := `UPDATE calls SET status = 'Active', start_time = NOW(), duration_wait = TIMESTAMPDIFF(SECOND, fecha_llamada, NOW()) WHERE uniqueid = ?`
-		_, err = db.Conn.QueryContext(ctx, query, uniqueIdDb)
-		if err != nil {
-			utils.Logline("Failed to start call", msg, err)
-			return fmt.Errorf("failed to start call")
-		}
-
-		query = `UPDATE current_calls SET event = 'Link' WHERE uniqueid = ?`
-		_, err = db.Conn.QueryContext(ctx, query, uniqueIdDb)
-		if err != nil {
-			utils.Logline("Failed to start current_call", msg, err)
-			return fmt.Errorf("failed to start current_call")
+		// entrante que nadie atendio, queda en la lista de rellamadas
+		if call.Direction == models.DirectionInbound && state == models.CallNoAnswer {
+			registerCallback(db, call)
 		}
 	}
 
+	if err := finishCall(db, pbx, uniqueIdDb, state); err != nil {
+		utils.Logline("Failed to end call", msg, err)
+		return err
+	}
 	return nil
 }
 
-func endCall(db models.ConnMysql, msg *goami2.Message) error {
+// finishCall cierra la llamada en calls segun su estado final y la elimina de current_calls
+func finishCall(db models.ConnMysql, pbx string, uniqueIdDb string, state models.CallState) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
 	var query string
 	var err error
-	uniqueIdDb := msg.Field("Linkedid")
-
-	var callStatus string
-	err = db.Conn.QueryRowContext(ctx, "SELECT LOWER(status) FROM calls WHERE uniqueid=?", uniqueIdDb).Scan(&callStatus)
-	if err != nil {
-		utils.Logline("Failed to read status of call in db: ", msg, err)
-		return fmt.Errorf("failed to read status of call in db")
-	}
 
 	// si la llamada fue colgada y ya habia sido atendida se categoriza como finalizada
 	// si se cuelga cuando solo estaba ringing entonces no se optuvo respuesta de la contraparte
-	if callStatus == "active" {
-		query := `UPDATE calls SET status = 'Finalizada', end_time = NOW(), duration = TIMESTAMPDIFF(SECOND, start_time, NOW()) WHERE uniqueid = ? AND (end_time IS NULL OR transfer<>'') `
-		_, err = db.Conn.QueryContext(ctx, query, uniqueIdDb)
+	switch state {
+	case models.CallCompleted:
+		query = `UPDATE calls SET status = 'Finalizada', end_time = NOW(), duration = TIMESTAMPDIFF(SECOND, start_time, NOW()) WHERE pbx = ? AND uniqueid = ? AND (end_time IS NULL OR transfer<>'') `
+		_, err = db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
 		if err != nil {
-			utils.Logline("Failed to update call status", msg, err)
+			utils.Logline("Failed to update call status", uniqueIdDb, err)
 			return fmt.Errorf("failed to update call status")
 		}
-	} else if callStatus == "ringing" {
+	case models.CallNoAnswer:
 		query = `UPDATE calls SET status = 'Sin respuesta', end_time = NOW(), duration_wait = TIMESTAMPDIFF(SECOND, fecha_llamada, NOW()), duration = TIMESTAMPDIFF(SECOND, fecha_llamada, NOW()) 
-			WHERE uniqueid = ? AND (end_time IS NULL OR transfer<>'')`
-		_, err = db.Conn.QueryContext(ctx, query, uniqueIdDb)
+			WHERE pbx = ? AND uniqueid = ? AND (end_time IS NULL OR transfer<>'')`
+		_, err = db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
 		if err != nil {
-			utils.Logline("Failed to end call", msg, err)
+			utils.Logline("Failed to end call", uniqueIdDb, err)
 			return fmt.Errorf("failed to end call")
 		}
 	}
 
-	query = `DELETE FROM current_calls WHERE uniqueid = ?`
-	_, err = db.Conn.QueryContext(ctx, query, uniqueIdDb)
+	query = `DELETE cc FROM current_calls AS cc INNER JOIN calls AS c ON c.id = cc.id_call WHERE c.pbx = ? AND c.uniqueid = ?`
+	_, err = db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
 	if err != nil {
-		utils.Logline("Failed to end current_calls", msg, err)
+		utils.Logline("Failed to end current_calls", uniqueIdDb, err)
 		return fmt.Errorf("failed to end current_calls")
 	}
 
@@ -0,0 +1,271 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-playground/validator/v10"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+var ErrCallNotFound = errors.New("call not found")
+
+// codigos y subcodigos en minusculas, se guardan en call_dispositions
+var dispositionCodeRegex = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// dispositionCatalog catalogo validado e indexado por codigo
+type dispositionCatalog struct {
+	cfg   models.Dispositions
+	codes map[string]models.DispositionCode
+}
+
+var activeDispositions atomic.Pointer[dispositionCatalog]
+
+func init() {
+	activeDispositions.Store(&dispositionCatalog{cfg: models.Dispositions{Codes: []models.DispositionCode{}}, codes: map[string]models.DispositionCode{}})
+}
+
+// LoadDispositions lee, valida y activa el catalogo del archivo indicado. Si el archivo
+// no existe el catalogo queda vacio. Si es invalido retorna el error de validacion y el
+// catalogo activo no cambia
+func LoadDispositions(path string) (models.Dispositions, error) {
+	// open file
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		utils.Logline("dispositions file not found, no disposition codes available", path)
+		return activeDispositions.Load().cfg, nil
+	}
+	if err != nil {
+		return models.Dispositions{}, fmt.Errorf("failed to read dispositions %s: %v", path, err)
+	}
+	defer file.Close()
+
+	// decode json data to struct
+	var cfg models.Dispositions
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return models.Dispositions{}, fmt.Errorf("invalid dispositions %s: %v", path, err)
+	}
+
+	catalog, err := compileDispositions(cfg)
+	if err != nil {
+		return models.Dispositions{}, fmt.Errorf("invalid dispositions %s: %v", path, err)
+	}
+
+	activeDispositions.Store(catalog)
+	utils.Logline("dispositions loaded", path, len(cfg.Codes))
+	return cfg, nil
+}
+
+// GetDispositions retorna el catalogo activo, incluye los codigos deshabilitados
+func GetDispositions() models.Dispositions {
+	return activeDispositions.Load().cfg
+}
+
+// compileDispositions valida que los codigos sean unicos y tengan etiqueta
+func compileDispositions(cfg models.Dispositions) (*dispositionCatalog, error) {
+	var errs []string
+	catalog := &dispositionCatalog{cfg: cfg, codes: make(map[string]models.DispositionCode)}
+	if catalog.cfg.Codes == nil {
+		catalog.cfg.Codes = []models.DispositionCode{}
+	}
+
+	for i, code := range cfg.Codes {
+		if !dispositionCodeRegex.MatchString(code.Code) {
+			errs = append(errs, fmt.Sprintf("codes[%d].code: %q must be lowercase letters, numbers or _ up to 32 chars", i, code.Code))
+		}
+		if _, ok := catalog.codes[code.Code]; ok {
+			errs = append(errs, fmt.Sprintf("codes[%d].code: %q is duplicated", i, code.Code))
+		}
+		if strings.TrimSpace(code.Label) == "" {
+			errs = append(errs, fmt.Sprintf("codes[%d].label: required", i))
+		}
+		seen := make(map[string]bool)
+		for j, subcode := range code.Subcodes {
+			if !dispositionCodeRegex.MatchString(subcode.Code) {
+				errs = append(errs, fmt.Sprintf("codes[%d].subcodes[%d].code: %q must be lowercase letters, numbers or _ up to 32 chars", i, j, subcode.Code))
+			}
+			if seen[subcode.Code] {
+				errs = append(errs, fmt.Sprintf("codes[%d].subcodes[%d].code: %q is duplicated", i, j, subcode.Code))
+			}
+			seen[subcode.Code] = true
+			if strings.TrimSpace(subcode.Label) == "" {
+				errs = append(errs, fmt.Sprintf("codes[%d].subcodes[%d].label: required", i, j))
+			}
+		}
+		catalog.codes[code.Code] = code
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return catalog, nil
+}
+
+// labels etiquetas del codigo y subcodigo, si ya no existen en el catalogo se usa el codigo
+func (c *dispositionCatalog) labels(code string, subcode string) (string, string) {
+	disposition, ok := c.codes[code]
+	if !ok {
+		return code, subcode
+	}
+	for _, sub := range disposition.Subcodes {
+		if sub.Code == subcode {
+			return disposition.Label, sub.Label
+		}
+	}
+	return disposition.Label, subcode
+}
+
+// ValidateDispositionsReport el rango del reporte no puede terminar antes de empezar, las
+// fechas YYYY-MM-DD se comparan como texto
+func ValidateDispositionsReport(sl validator.StructLevel) {
+	req := sl.Current().Interface().(models.DispositionsReportReq)
+	if req.DateFrom != "" && req.DateTo < req.DateFrom {
+		sl.ReportError(req.DateTo, "date_to", "DateTo", "date_range", "date_from")
+	}
+}
+
+// ValidateDisposition validacion de models.DispositionReq contra el catalogo activo, los
+// errores se reportan por campo y se traducen con models.ParseError
+func ValidateDisposition(sl validator.StructLevel) {
+	req := sl.Current().Interface().(models.DispositionReq)
+	if req.Code == "" {
+		return
+	}
+
+	disposition, ok := activeDispositions.Load().codes[req.Code]
+	if !ok || disposition.Disabled {
+		sl.ReportError(req.Code, "code", "Code", "disposition", "")
+		return
+	}
+
+	if disposition.NoteRequired && strings.TrimSpace(req.Notes) == "" {
+		sl.ReportError(req.Notes, "notes", "Notes", "required", "")
+	}
+
+	if len(disposition.Subcodes) == 0 {
+		if req.Subcode != "" {
+			sl.ReportError(req.Subcode, "subcode", "Subcode", "disposition_subcode", req.Code)
+		}
+		return
+	}
+	if req.Subcode == "" {
+		sl.ReportError(req.Subcode, "subcode", "Subcode", "required", "")
+		return
+	}
+	for _, subcode := range disposition.Subcodes {
+		if subcode.Code == req.Subcode && !subcode.Disabled {
+			return
+		}
+	}
+	sl.ReportError(req.Subcode, "subcode", "Subcode", "disposition_subcode", req.Code)
+}
+
+// SaveDisposition guarda la tipificacion de la llamada, si ya estaba tipificada se reemplaza.
+// Sin uniqueid se usa la ultima llamada del dia atendida por la extension
+func SaveDisposition(db models.ConnMysql, req models.DispositionReq, createdBy string) (models.CallDisposition, error) {
+	var callId int
+	var uniqueId, agent string
+
+	var row *sql.Row
+	if req.Uniqueid != "" {
+		// el uniqueid puede repetirse entre centrales
+		pbx := req.Pbx
+		if pbx == "" {
+			owner, err := utils.PbxForExtension(req.Extension)
+			if err != nil {
+				owner = utils.DefaultPbx()
+			}
+			pbx = owner.Name
+		}
+		query := `SELECT c.id, c.uniqueid, COALESCE(a.number, '')
+			FROM calls AS c
+			LEFT JOIN agent AS a ON a.id = c.id_agent
+			WHERE c.pbx = ? AND c.uniqueid = ?`
+		row = db.Conn.QueryRowContext(db.Ctx, query, pbx, req.Uniqueid)
+	} else {
+		query := `SELECT c.id, c.uniqueid, a.number
+			FROM calls AS c
+			INNER JOIN agent AS a ON a.id = c.id_agent
+			WHERE a.number = ? AND c.fecha_llamada >= NOW() - INTERVAL 1 DAY
+			ORDER BY c.fecha_llamada DESC, c.id DESC
+			LIMIT 1`
+		row = db.Conn.QueryRowContext(db.Ctx, query, req.Extension)
+	}
+	if err := row.Scan(&callId, &uniqueId, &agent); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.CallDisposition{}, ErrCallNotFound
+		}
+		utils.Logline("error getting call to disposition", req, err)
+		return models.CallDisposition{}, err
+	}
+
+	// quien envia la tipificacion, la llamada pudo ser atendida por otro agente antes de transferirse
+	if req.Extension != "" {
+		agent = req.Extension
+	}
+
+	query := `INSERT INTO call_dispositions (id_call, uniqueid, agent, code, subcode, notes, created_by, datetime_disposition)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, NOW())
+		ON DUPLICATE KEY UPDATE agent = VALUES(agent), code = VALUES(code), subcode = VALUES(subcode), notes = VALUES(notes),
+			created_by = VALUES(created_by), datetime_update = NOW()`
+	_, err := db.Conn.ExecContext(db.Ctx, query, callId, uniqueId, agent, req.Code, req.Subcode, strings.TrimSpace(req.Notes), createdBy)
+	if err != nil {
+		utils.Logline("Failed to save call disposition", req, err)
+		return models.CallDisposition{}, fmt.Errorf("failed to save call disposition")
+	}
+
+	var disposition models.CallDisposition
+	query = `SELECT id, id_call, uniqueid, agent, code, subcode, notes, created_by, datetime_disposition, datetime_update
+		FROM call_dispositions WHERE id_call = ?`
+	err = db.Conn.QueryRowContext(db.Ctx, query, callId).Scan(&disposition.Id, &disposition.IdCall, &disposition.Uniqueid, &disposition.Agent,
+		&disposition.Code, &disposition.Subcode, &disposition.Notes, &disposition.CreatedBy, &disposition.DateCreated, &disposition.DateModified)
+	if err != nil {
+		utils.Logline("error getting call disposition", callId, err)
+		return models.CallDisposition{}, err
+	}
+
+	utils.Logline("call disposition saved", disposition)
+	return disposition, nil
+}
+
+// DispositionsReport llamadas tipificadas por dia, agente, codigo y subcodigo en un rango de fechas
+func DispositionsReport(db models.ConnMysql, req models.DispositionsReportReq) ([]models.DispositionsReport, error) {
+	query := `SELECT DATE_FORMAT(c.fecha_llamada, '%Y-%m-%d') AS day, d.agent, d.code, COALESCE(d.subcode, '') AS subcode,
+			COUNT(*), COALESCE(SUM(c.duration), 0)
+		FROM call_dispositions AS d
+		INNER JOIN calls AS c ON c.id = d.id_call
+		WHERE c.fecha_llamada >= ? AND c.fecha_llamada < DATE_ADD(?, INTERVAL 1 DAY) AND (? = '' OR d.agent = ?)
+			AND (? = '' OR d.code = ?)
+		GROUP BY day, d.agent, d.code, subcode
+		ORDER BY day, d.agent, d.code, subcode`
+
+	rows, err := db.Conn.QueryContext(db.Ctx, query, req.DateFrom, req.DateTo, req.Agent, req.Agent, req.Code, req.Code)
+	if err != nil {
+		utils.Logline("error getting dispositions report", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	catalog := activeDispositions.Load()
+	report := []models.DispositionsReport{}
+	for rows.Next() {
+		var line models.DispositionsReport
+		if err := rows.Scan(&line.Day, &line.Agent, &line.Code, &line.Subcode, &line.Total, &line.Seconds); err != nil {
+			utils.Logline("error scanning dispositions report", err)
+			return nil, err
+		}
+		line.Label, line.SubcodeLabel = catalog.labels(line.Code, line.Subcode)
+		report = append(report, line)
+	}
+
+	return report, rows.Err()
+}
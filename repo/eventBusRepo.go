@@ -0,0 +1,247 @@
+package repo
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// bus interno de eventos de llamadas y agentes. Los handlers de eventos AMI publican
+// eventos ya interpretados y cada subsistema se suscribe con su propia cola, publicar
+// nunca bloquea a los workers: si la cola del suscriptor esta llena se aplica su politica.
+// Los ultimos eventos se guardan en memoria para que un cliente que reconecta retome
+// desde el ultimo id recibido (ver EventsSince)
+type eventBus struct {
+	mu      sync.RWMutex
+	subs    map[uint64]*BusSubscription
+	seq     atomic.Uint64 // id de los eventos
+	subSeq  atomic.Uint64
+	history []models.BusEvent
+	next    int  // posicion del historial donde se escribe el siguiente evento
+	sized   bool // el historial se dimensiona con el primer evento, ya con el .env cargado
+}
+
+var bus = &eventBus{subs: make(map[uint64]*BusSubscription)}
+
+// BusSubscription suscripcion al bus, los eventos se leen de Events hasta que el canal
+// se cierre por Close o por la politica BusDisconnect
+type BusSubscription struct {
+	id        uint64
+	name      string
+	types     []string
+	policy    models.BusPolicy
+	since     time.Time
+	mu        sync.Mutex
+	ch        chan models.BusEvent
+	closed    bool
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// SubscribeEvents crea una suscripcion con una cola de buffer eventos, sin types recibe todos
+func SubscribeEvents(name string, buffer int, policy models.BusPolicy, types ...string) *BusSubscription {
+	if buffer <= 0 {
+		buffer = 100
+	}
+	if policy == "" {
+		policy = models.BusDropNewest
+	}
+	sub := &BusSubscription{id: bus.subSeq.Add(1), name: name, types: types, policy: policy, since: time.Now(), ch: make(chan models.BusEvent, buffer)}
+
+	bus.mu.Lock()
+	bus.subs[sub.id] = sub
+	bus.mu.Unlock()
+	utils.Logline("event bus subscriber added", name, policy, types)
+	return sub
+}
+
+// Events canal de eventos de la suscripcion
+func (s *BusSubscription) Events() <-chan models.BusEvent {
+	return s.ch
+}
+
+// Close termina la suscripcion y cierra su canal
+func (s *BusSubscription) Close() {
+	bus.mu.Lock()
+	delete(bus.subs, s.id)
+	bus.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+func (s *BusSubscription) wants(eventType string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver entrega sin bloquear, false si la suscripcion se cerro por lenta
+func (s *BusSubscription) deliver(ev models.BusEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	select {
+	case s.ch <- ev:
+		s.delivered.Add(1)
+		return true
+	default:
+	}
+
+	s.dropped.Add(1)
+	switch s.policy {
+	case models.BusDropOldest:
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- ev:
+			s.delivered.Add(1)
+		default:
+		}
+	case models.BusDisconnect:
+		utils.Logline("event bus subscriber too slow, disconnected", s.name)
+		s.closed = true
+		close(s.ch)
+		return false
+	}
+	return true
+}
+
+// publishEvent guarda el evento en el historial y lo entrega a los suscriptores interesados
+func publishEvent(ev models.BusEvent) {
+	ev.At = time.Now()
+
+	// el id se asigna con el lock tomado para que el historial quede ordenado
+	bus.mu.Lock()
+	ev.Id = bus.seq.Add(1)
+	if !bus.sized {
+		bus.history = make([]models.BusEvent, 0, envInt("EVENTS_HISTORY", 1000))
+		bus.sized = true
+	}
+	if len(bus.history) < cap(bus.history) {
+		bus.history = append(bus.history, ev)
+	} else if cap(bus.history) > 0 {
+		bus.history[bus.next] = ev
+		bus.next = (bus.next + 1) % cap(bus.history)
+	}
+
+	var slow []uint64
+	for id, sub := range bus.subs {
+		if sub.wants(ev.Type) && !sub.deliver(ev) {
+			slow = append(slow, id)
+		}
+	}
+	for _, id := range slow {
+		delete(bus.subs, id)
+	}
+	bus.mu.Unlock()
+}
+
+// EventsSince eventos del historial posteriores al id, en orden. Si el id es mayor al
+// ultimo evento (el servicio se reinicio) se retorna todo el historial
+func EventsSince(lastId uint64) []models.BusEvent {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	if lastId > bus.seq.Load() {
+		lastId = 0
+	}
+	events := []models.BusEvent{}
+	for i := range bus.history {
+		ev := bus.history[(bus.next+i)%len(bus.history)]
+		if ev.Id > lastId {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// publishCall publica el evento con una copia del estado actual de la llamada
+func publishCall(eventType string, pbx string, linkedId string) {
+	call, ok := callTracker.Get(pbx, linkedId)
+	if !ok {
+		return
+	}
+	publishEvent(models.BusEvent{Type: eventType, Pbx: call.Pbx, Call: &call})
+}
+
+// publishCallEnded publica el fin de la llamada con su resultado normalizado
+func publishCallEnded(call models.TrackedCall) {
+	call.Outcome = callOutcome(call)
+	publishEvent(models.BusEvent{Type: models.BusCallEnded, Pbx: call.Pbx, Call: &call})
+}
+
+// publishExtensionState evento ExtensionStatus de la central
+func publishExtensionState(pbx string, msg *goami2.Message) {
+	publishEvent(models.BusEvent{Type: models.BusExtensionState, Pbx: pbx, Extension: &models.ExtensionState{
+		Exten:      msg.Field("Exten"),
+		Status:     translateStatusExtension(msg.Field("Status")),
+		StatusText: msg.Field("StatusText"),
+		Hint:       msg.Field("Hint"),
+	}})
+}
+
+// publishQueueMemberPause eventos QueueMemberPause (asterisk 13+) y QueueMemberPaused
+func publishQueueMemberPause(pbx string, msg *goami2.Message) {
+	iface := msg.Field("Interface")
+	if iface == "" {
+		iface = msg.Field("Location")
+	}
+	reason := msg.Field("PausedReason")
+	if reason == "" {
+		reason = msg.Field("Reason")
+	}
+	publishEvent(models.BusEvent{Type: models.BusQueueMemberPaused, Pbx: pbx, Member: &models.QueueMemberPause{
+		Queue:     msg.Field("Queue"),
+		Exten:     utils.ParseChannel(iface).Exten,
+		Interface: iface,
+		Paused:    msg.Field("Paused") == "1",
+		Reason:    reason,
+	}})
+}
+
+// EventBusStats estado de los suscriptores del bus
+func EventBusStats() []models.BusSubscriberStats {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	stats := []models.BusSubscriberStats{}
+	for _, sub := range bus.subs {
+		types := sub.types
+		if types == nil {
+			types = []string{}
+		}
+		stats = append(stats, models.BusSubscriberStats{
+			Name:      sub.name,
+			Policy:    sub.policy,
+			Types:     types,
+			Buffer:    cap(sub.ch),
+			Depth:     len(sub.ch),
+			Delivered: sub.delivered.Load(),
+			Dropped:   sub.dropped.Load(),
+			Since:     sub.since,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Since.Before(stats[j].Since) })
+	return stats
+}
@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// cache del estado de las extensiones de cada central, se mantiene con los eventos
+// ExtensionStatus y DeviceStateChange del listener y se carga con ExtensionStateList
+// cada vez que la sesion conecta. Mientras la sesion esta caida o la carga no termino
+// la cache de la central esta fria y las consultas van directo a la central
+type extensionStateCache struct {
+	mu       sync.RWMutex
+	states   map[string]map[string]*models.ExtensionState // pbx -> exten -> estado
+	seededAt map[string]time.Time                         // pbx con la cache cargada
+}
+
+var extensionStates = &extensionStateCache{states: make(map[string]map[string]*models.ExtensionState), seededAt: make(map[string]time.Time)}
+
+// handleExtensionEvent actualiza la cache con ExtensionStatus o DeviceStateChange
+func handleExtensionEvent(pbx string, msg *goami2.Message) {
+	switch msg.Field("Event") {
+	case "ExtensionStatus":
+		extensionStates.setStatus(pbx, msg, time.Now())
+		publishExtensionState(pbx, msg)
+	case "DeviceStateChange":
+		// solo dispositivos de extensiones, ej: PJSIP/8001, no Queue:8000_avail
+		device := msg.Field("Device")
+		if !strings.Contains(device, "/") {
+			return
+		}
+		extensionStates.setDevice(pbx, utils.ParseChannel(device).Exten, msg.Field("State"))
+	}
+}
+
+// seedExtensionStates carga la cache de la central con ExtensionStateList, los eventos
+// recibidos mientras tanto son mas nuevos y no se pisan
+func seedExtensionStates(pbx models.Pbx) {
+	startedAt := time.Now()
+	res, err := amiAction(pbx, goami2.NewAction("ExtensionStateList"), 10*time.Second)
+	if err != nil {
+		utils.Logline("failed to load extension states", pbx.Name, err)
+		return
+	}
+
+	for _, msg := range res.Events {
+		if msg.Field("Event") == "ExtensionStatus" {
+			extensionStates.setStatus(pbx.Name, msg, startedAt)
+		}
+	}
+
+	extensionStates.mu.Lock()
+	extensionStates.seededAt[pbx.Name] = time.Now()
+	extensionStates.mu.Unlock()
+	utils.Logline("extension states loaded", pbx.Name, len(res.Events))
+}
+
+// invalidate enfria la cache de la central, se llama cuando la sesion se cae
+func (c *extensionStateCache) invalidate(pbx string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seededAt, pbx)
+}
+
+// setStatus guarda el estado si es mas nuevo que el de la cache
+func (c *extensionStateCache) setStatus(pbx string, msg *goami2.Message, at time.Time) {
+	exten := msg.Field("Exten")
+	if exten == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// -2 la extension fue eliminada, -1 no existe el hint
+	if status := msg.Field("Status"); status == "-2" || status == "-1" {
+		delete(c.states[pbx], exten)
+		return
+	}
+
+	state := c.entry(pbx, exten)
+	if state.UpdatedAt != nil && state.UpdatedAt.After(at) {
+		return
+	}
+	state.Status = translateStatusExtension(msg.Field("Status"))
+	state.StatusText = msg.Field("StatusText")
+	state.Hint = msg.Field("Hint")
+	state.UpdatedAt = &at
+}
+
+func (c *extensionStateCache) setDevice(pbx string, exten string, deviceState string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry(pbx, exten).DeviceState = deviceState
+}
+
+// entry se llama con c.mu tomado
+func (c *extensionStateCache) entry(pbx string, exten string) *models.ExtensionState {
+	if c.states[pbx] == nil {
+		c.states[pbx] = make(map[string]*models.ExtensionState)
+	}
+	state, ok := c.states[pbx][exten]
+	if !ok {
+		state = &models.ExtensionState{Exten: exten}
+		c.states[pbx][exten] = state
+	}
+	return state
+}
+
+// get retorna el estado si la cache de la central esta caliente y conoce la extension
+func (c *extensionStateCache) get(pbx string, exten string) (models.ExtensionState, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.seededAt[pbx]; !ok {
+		return models.ExtensionState{}, false
+	}
+	state, ok := c.states[pbx][exten]
+	if !ok || state.UpdatedAt == nil {
+		return models.ExtensionState{}, false
+	}
+	return *state, true
+}
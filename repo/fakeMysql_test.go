@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"ired.com/callcenter/models"
+)
+
+// fakeMysql driver database/sql en memoria para probar los repos sin mysql, guarda
+// cada sentencia ejecutada y responde las consultas con la funcion rows
+type fakeMysql struct {
+	mu    sync.Mutex
+	execs []fakeQuery
+	rows  func(query string, args []any) ([]string, [][]driver.Value)
+	err   func(query string) error // error para simular fallas de mysql, nil para ninguna
+}
+
+type fakeQuery struct {
+	Query string
+	Args  []any
+}
+
+var fakeMysqlSeq atomic.Int64
+
+// newFakeMysql registra un driver nuevo por prueba y retorna la conexion lista para los repos
+func newFakeMysql(t *testing.T, rows func(query string, args []any) ([]string, [][]driver.Value)) (models.ConnMysql, *fakeMysql) {
+	t.Helper()
+	fake := &fakeMysql{rows: rows}
+	name := fmt.Sprintf("fakemysql-%d", fakeMysqlSeq.Add(1))
+	sql.Register(name, fakeDriver{fake})
+
+	conn, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return models.ConnMysql{Conn: conn, Ctx: context.Background()}, fake
+}
+
+// Execs sentencias ejecutadas que contienen el texto
+func (f *fakeMysql) Execs(contains string) []fakeQuery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []fakeQuery
+	for _, q := range f.execs {
+		if strings.Contains(q.Query, contains) {
+			found = append(found, q)
+		}
+	}
+	return found
+}
+
+func (f *fakeMysql) fail(query string) error {
+	if f.err == nil {
+		return nil
+	}
+	return f.err(query)
+}
+
+type fakeDriver struct{ fake *fakeMysql }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d.fake}, nil }
+
+type fakeConn struct{ fake *fakeMysql }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.fake.fail(query); err != nil {
+		return nil, err
+	}
+	c.fake.mu.Lock()
+	c.fake.execs = append(c.fake.execs, fakeQuery{Query: query, Args: values(args)})
+	id := int64(len(c.fake.execs))
+	c.fake.mu.Unlock()
+	return fakeResult{id}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.fake.fail(query); err != nil {
+		return nil, err
+	}
+	var columns []string
+	var data [][]driver.Value
+	if c.fake.rows != nil {
+		columns, data = c.fake.rows(query, values(args))
+	}
+	return &fakeRows{columns: columns, data: data}, nil
+}
+
+func values(args []driver.NamedValue) []any {
+	list := make([]any, len(args))
+	for i, arg := range args {
+		list[i] = arg.Value
+	}
+	return list
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeResult struct{ id int64 }
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	columns []string
+	data    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.columns) == 0 {
+		return []string{"value"}
+	}
+	return r.columns
+}
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.next])
+	r.next++
+	return nil
+}
@@ -1,9 +1,7 @@
 package repo
 
 import (
-	"context"
-	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/staskobzar/goami2"
@@ -11,11 +9,10 @@ import (
 	"ired.com/callcenter/utils"
 )
 
+// ExtensionStatus estado de las extensiones de los agentes, cada extension se consulta
+// en su central y los miembros de cola se unen de todas las centrales (ver queueMembersRepo.go)
 func ExtensionStatus(db models.ConnMysql) ([]models.ExtensionStatus, error) {
-	queueMembers, err := getAmiQueueStatus()
-	if err != nil {
-		utils.Logline("error getting queue status", err)
-	}
+	queues := queueMembership()
 
 	var extensions []models.ExtensionStatus
 
@@ -34,135 +31,178 @@ func ExtensionStatus(db models.ConnMysql) ([]models.ExtensionStatus, error) {
 			return nil, err
 		}
 
-		if status, err = getAmiExtStatus(extension); err != nil {
+		// estado desde la cache de eventos, si esta fria se consulta a la central
+		source := "cache"
+		pbx, err := utils.PbxForExtension(extension)
+		state, ok := extensionStates.get(pbx.Name, extension)
+		if err == nil && !ok {
+			source = "live"
+			status, err = getAmiExtStatus(pbx, extension)
+		}
+		if err != nil {
 			utils.Logline("error getting status of extension via ami", extension, err)
-			status = "-"
+			status, source = "-", ""
+		}
+		if ok {
+			status = state.Status
 		}
 
-		onQueue := checkExtenOnQueue(extension, queueMembers)
+		onQueue := checkExtenOnQueue(pbx.Name, extension, queues)
 
-		extensions = append(extensions, models.ExtensionStatus{Extension: extension, Status: status, OnQueue: onQueue})
+		extenStatus := models.ExtensionStatus{Extension: extension, Pbx: pbx.Name, Status: status, StatusSource: source, OnQueue: onQueue}
+		if ok {
+			extenStatus.DeviceState = state.DeviceState
+			extenStatus.StatusUpdatedAt = state.UpdatedAt
+			extenStatus.StatusAgeSeconds = int(time.Since(*state.UpdatedAt).Seconds())
+		}
+
+		// esperas de la llamada en curso segun el listener de eventos
+		if call, ok := callTracker.ActiveByAgent(pbx.Name, extension); ok {
+			extenStatus.OnHold = call.State == models.CallOnHold
+			extenStatus.HoldCount = call.HoldCount
+			extenStatus.HoldSeconds = call.TotalHoldSeconds()
+		}
+
+		extensions = append(extensions, extenStatus)
 	}
 	rowsMysql.Close()
 
 	return extensions, nil
 }
 
-func getAmiExtStatus(exten string) (string, error) {
-	// Connect to Asterisk AMI
-	clientAmi, err := utils.ConnectToAmi()
+func getAmiExtStatus(pbx models.Pbx, exten string) (string, error) {
+	// Retrieve extension status
+	action := goami2.NewAction("ExtensionState")
+	action.SetField("Exten", exten)
+
+	res, err := amiAction(pbx, action, 1*time.Second)
 	if err != nil {
 		return "", err
 	}
-	defer clientAmi.Close()
+	return translateStatusExtension(res.Response.Field("Status")), nil
+}
 
-	// Retrieve extension status
-	action := goami2.NewAction("ExtensionState")
-	action.SetField("Exten", exten) // Replace with your extension number
-	actionID := fmt.Sprintf("extstatus-%d", time.Now().Unix())
-	action.SetField("ActionID", actionID)
-
-	// Send the action
-	clientAmi.Send(action.Byte())
-
-	responseChan := make(chan string)
-	errorChan := make(chan error)
-
-	// create context
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				errorChan <- fmt.Errorf("timeout waiting for response")
-				return
-			case msg := <-clientAmi.AllMessages():
-				if msg.ActionID() == actionID {
-					status := msg.Field("Status")
-					if status != "" {
-						responseChan <- status
-						return
-					}
-				}
-			case err := <-clientAmi.Err():
-				errorChan <- fmt.Errorf("ami error: %v", err)
-				return
-			}
+// QueueStats estado en tiempo real de las colas de todas las centrales, una central
+// que no responde se omite
+func QueueStats(queue string) ([]models.QueueStats, error) {
+	stats := []models.QueueStats{}
+	var lastErr error
+	for _, pbx := range utils.PbxList() {
+		queues, err := getAmiQueueStatus(pbx, queue)
+		if err != nil {
+			utils.Logline("error getting queue status", pbx.Name, err)
+			lastErr = err
+			continue
 		}
-	}()
-
-	// Wait for response or timeout
-	select {
-	case status := <-responseChan:
-		return translateStatusExtension(status), nil
-	case err := <-errorChan:
-		return "", err
-	case <-ctx.Done():
-		return "", fmt.Errorf("timeout waiting for extension status")
+		stats = append(stats, queues...)
 	}
+	if len(stats) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return stats, nil
 }
 
-func getAmiQueueStatus() ([]models.QueueMember, error) {
-	// Connect to Asterisk AMI
-	clientAmi, err := utils.ConnectToAmi()
+// getAmiQueueStatus envia QueueStatus y arma cada cola con sus parametros, miembros y
+// clientes en espera, queue vacio retorna todas las colas
+func getAmiQueueStatus(pbx models.Pbx, queue string) ([]models.QueueStats, error) {
+	action := goami2.NewAction("QueueStatus")
+	if queue != "" {
+		action.SetField("Queue", queue)
+	}
+
+	res, err := amiAction(pbx, action, 2*time.Second)
 	if err != nil {
 		return nil, err
 	}
-	defer clientAmi.Close()
 
-	// Retrieve extension status
-	action := goami2.NewAction("QueueStatus")
-	action.SetField("Qeueue", "8000") // Replace with your extension number
-	actionID := fmt.Sprintf("queuestatus-%d", time.Now().Unix())
-	action.SetField("ActionID", actionID)
-
-	// Send the action
-	clientAmi.Send(action.Byte())
-
-	// create context
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-
-	// var queueMembers []models.QueueMember
-	var queueMembers []models.QueueMember
-
-	// Listen for responses
-	for {
-		select {
-		case msg := <-clientAmi.AllMessages():
-			if msg.Field("Event") == "QueueMember" {
-				queueName := msg.Field("queue")
-				exten := strings.ReplaceAll(msg.Field("name"), "SIP/", "")
-				status := msg.Field("status")
-				queueMembers = append(queueMembers, models.QueueMember{QueueName: queueName, Extension: exten, Status: status})
-			}
+	var queues []models.QueueStats
+	byName := make(map[string]int)
+	queueOf := func(name string) *models.QueueStats {
+		i, ok := byName[name]
+		if !ok {
+			i = len(queues)
+			byName[name] = i
+			queues = append(queues, models.QueueStats{Queue: name, Pbx: pbx.Name, Members: []models.QueueMemberStats{}, Callers: []models.QueueCaller{}})
+		}
+		return &queues[i]
+	}
 
-			// Break the loop if the response is "QueueStatusComplete"
-			if msg.Field("Event") == "QueueStatusComplete" {
-				fmt.Println("QueueStatus completed.")
-				return queueMembers, nil
+	for _, msg := range res.Events {
+		switch msg.Field("Event") {
+		case "QueueParams":
+			q := queueOf(msg.Field("Queue"))
+			q.Strategy = msg.Field("Strategy")
+			q.CallsWaiting = fieldInt(msg, "Calls")
+			q.Completed = fieldInt(msg, "Completed")
+			q.Abandoned = fieldInt(msg, "Abandoned")
+			q.ServiceLevel = fieldInt(msg, "ServiceLevel")
+			q.ServiceLevelPerf, _ = strconv.ParseFloat(msg.Field("ServicelevelPerf"), 64)
+			q.HoldTimeAvg = fieldInt(msg, "Holdtime")
+			q.TalkTimeAvg = fieldInt(msg, "TalkTime")
+		case "QueueMember":
+			q := queueOf(msg.Field("Queue"))
+			iface := queueMemberInterface(msg)
+			member := models.QueueMemberStats{
+				Extension:    utils.ParseChannel(iface).Exten,
+				Name:         msg.Field("Name"),
+				Interface:    iface,
+				Membership:   msg.Field("Membership"),
+				Penalty:      fieldInt(msg, "Penalty"),
+				Status:       translateStatusMember(msg.Field("Status")),
+				InCall:       msg.Field("InCall") == "1",
+				Paused:       msg.Field("Paused") == "1",
+				PausedReason: msg.Field("PausedReason"),
+				CallsTaken:   fieldInt(msg, "CallsTaken"),
 			}
-
-		case <-ctx.Done():
-			return nil, fmt.Errorf("timeout waiting for extension status")
-
-		case err := <-clientAmi.Err():
-			return nil, err
+			if lastCall := fieldInt(msg, "LastCall"); lastCall > 0 {
+				t := time.Unix(int64(lastCall), 0)
+				member.LastCall = &t
+			}
+			q.Members = append(q.Members, member)
+		case "QueueEntry":
+			q := queueOf(msg.Field("Queue"))
+			caller := models.QueueCaller{
+				Position:     fieldInt(msg, "Position"),
+				Uniqueid:     msg.Field("Uniqueid"),
+				Channel:      msg.Field("Channel"),
+				CallerIDNum:  msg.Field("CallerIDNum"),
+				CallerIDName: msg.Field("CallerIDName"),
+				WaitSeconds:  fieldInt(msg, "Wait"),
+			}
+			if caller.WaitSeconds > q.LongestWaitSeconds {
+				q.LongestWaitSeconds = caller.WaitSeconds
+			}
+			q.Callers = append(q.Callers, caller)
 		}
 	}
+	return queues, nil
 }
 
-func checkExtenOnQueue(exten string, queue []models.QueueMember) bool {
-	for _, member := range queue {
-		if exten == member.Extension {
-			return true
+// checkExtenOnQueue indica si la extension es miembro de alguna de las colas entrantes
+// rastreadas en .callrules
+func checkExtenOnQueue(pbx string, exten string, queues []models.QueueStats) bool {
+	rules := activeRules.Load()
+	for _, queue := range queues {
+		if queue.Pbx != pbx {
+			continue
+		}
+		if _, ok := rules.inboundQueue(queue.Queue); !ok {
+			continue
+		}
+		for _, member := range queue.Members {
+			if exten == member.Extension {
+				return true
+			}
 		}
 	}
 	return false
 }
 
+func fieldInt(msg *goami2.Message, key string) int {
+	value, _ := strconv.Atoi(msg.Field(key))
+	return value
+}
+
 // translateStatusExtension converts numeric status to human-readable format
 func translateStatusExtension(status string) string {
 	switch status {
@@ -176,8 +216,36 @@ func translateStatusExtension(status string) string {
 		return "Unavailable"
 	case "8":
 		return "Ringing"
+	case "9":
+		return "InUse&Ringing"
 	case "16":
 		return "OnHold"
+	case "17":
+		return "InUse&OnHold"
+	default:
+		return "Unknown"
+	}
+}
+
+// translateStatusMember estado del dispositivo de un miembro de cola (QueueMember Status)
+func translateStatusMember(status string) string {
+	switch status {
+	case "1":
+		return "NotInUse"
+	case "2":
+		return "InUse"
+	case "3":
+		return "Busy"
+	case "4":
+		return "Invalid"
+	case "5":
+		return "Unavailable"
+	case "6":
+		return "Ringing"
+	case "7":
+		return "RingInUse"
+	case "8":
+		return "OnHold"
 	default:
 		return "Unknown"
 	}
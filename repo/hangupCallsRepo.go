@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// causas Q.850 que envia asterisk en el campo Cause del evento Hangup
+const (
+	causeUnallocated        = 1
+	causeNoRouteTransit     = 2
+	causeNoRouteDestination = 3
+	causeNormalClearing     = 16
+	causeUserBusy           = 17
+	causeNoUserResponse     = 18
+	causeNoAnswer           = 19
+	causeSubscriberAbsent   = 20
+	causeCallRejected       = 21
+	causeDestOutOfOrder     = 27
+	causeInvalidNumber      = 28
+	causeCongestion         = 34
+	causeNetworkOutOfOrder  = 38
+	causeTemporaryFailure   = 41
+	causeSwitchCongestion   = 42
+	causeChanUnavailable    = 44
+	causeAnsweredElsewhere  = 26
+)
+
+// recordHangup guarda el primer Hangup de la llamada, el canal que cuelga primero es
+// la parte que termino la llamada. Se ignoran los canales que no terminan la llamada:
+// los agentes que la cola cancela al ser atendida, los intentos de la cola mientras
+// la entrante espera y el agente que ya transfirio la llamada
+func recordHangup(db models.ConnMysql, pbx string, msg *goami2.Message, linkedId string, ownChannel bool, rules *callRules) {
+	cause, _ := strconv.Atoi(msg.Field("Cause"))
+	if cause == causeAnsweredElsewhere {
+		return
+	}
+	callerIdNum := msg.Field("CallerIDNum")
+
+	var recorded bool
+	var call models.TrackedCall
+	callTracker.Update(pbx, linkedId, func(tracked *models.TrackedCall) {
+		if tracked.Hangup != nil {
+			return
+		}
+		if tracked.Direction == models.DirectionInbound && tracked.AnsweredAt == nil && !ownChannel {
+			return
+		}
+		if tracked.Transfers > 0 && callerIdNum != tracked.Agent && rules.isAgent(callerIdNum) {
+			return
+		}
+
+		// en las salientes el canal que origina es del agente, en las entrantes del cliente
+		agentSide := callerIdNum == tracked.Agent || ownChannel == (tracked.Direction == models.DirectionOutbound)
+		hangup := models.CallHangup{Cause: cause, CauseTxt: msg.Field("Cause-txt"), Channel: msg.Field("Channel"), By: models.HangupByCustomer}
+		if agentSide {
+			hangup.By = models.HangupByAgent
+		}
+		tracked.Hangup = &hangup
+		recorded = true
+		call = *tracked
+	})
+
+	// si la llamada ya se cerro por BridgeLeave el Hangup llega despues
+	if recorded && call.State.IsFinal() {
+		outcomeCall(db, call)
+	}
+}
+
+// callOutcome resultado normalizado segun si fue atendida y la causa del Hangup
+func callOutcome(call models.TrackedCall) string {
+	if call.Outcome != "" {
+		return call.Outcome
+	}
+	if call.AnsweredAt != nil {
+		return models.OutcomeAnswered
+	}
+	if call.Hangup == nil {
+		return models.OutcomeFailed
+	}
+
+	switch call.Hangup.Cause {
+	case causeUserBusy:
+		return models.OutcomeBusy
+	case causeNoUserResponse, causeNoAnswer:
+		return models.OutcomeNoAnswer
+	case causeCallRejected:
+		return models.OutcomeRejected
+	case causeUnallocated, causeNoRouteTransit, causeNoRouteDestination, causeSubscriberAbsent, causeDestOutOfOrder, causeInvalidNumber:
+		return models.OutcomeUnreachable
+	case causeCongestion, causeNetworkOutOfOrder, causeTemporaryFailure, causeSwitchCongestion, causeChanUnavailable:
+		return models.OutcomeCongestion
+	case causeNormalClearing, 0:
+		if call.Hangup.By == models.HangupByAgent {
+			return models.OutcomeCancelled
+		}
+		if call.Direction == models.DirectionInbound {
+			return models.OutcomeAbandoned
+		}
+		return models.OutcomeNoAnswer
+	}
+	return models.OutcomeFailed
+}
+
+// outcomeCall guarda la causa del Hangup, quien colgo y el resultado de la llamada
+func outcomeCall(db models.ConnMysql, call models.TrackedCall) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	hangup := models.CallHangup{}
+	if call.Hangup != nil {
+		hangup = *call.Hangup
+	}
+
+	query := `UPDATE calls SET outcome = ?, hangup_cause = NULLIF(?, 0), hangup_cause_txt = NULLIF(?, ''), hangup_channel = NULLIF(?, ''), hangup_by = NULLIF(?, '')
+		WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, callOutcome(call), hangup.Cause, hangup.CauseTxt, hangup.Channel, hangup.By, call.Pbx, call.Linkedid)
+	if err != nil {
+		utils.Logline("Failed to update call outcome", call.Linkedid, err)
+		return fmt.Errorf("failed to update call outcome")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// handleHoldEvent pone o quita la espera de una llamada atendida
+// Hold / MusicOnHoldStart la llamada se pone en espera
+// Unhold / MusicOnHoldStop la llamada sale de la espera
+// la musica en espera de las colas llega antes de ser atendida y se ignora
+func handleHoldEvent(db models.ConnMysql, pbx string, msg *goami2.Message, linkedId string, ev models.CallEvent) {
+	call, ok := callTracker.Get(pbx, linkedId)
+	if !ok || (call.State != models.CallActive && call.State != models.CallOnHold) {
+		return
+	}
+
+	from, to, ok := fireCallEvent(pbx, linkedId, ev, msg)
+	if !ok || from == to {
+		return
+	}
+
+	utils.Logline(fmt.Sprintf("new event [%s] ", msg.Field("Event")), msg)
+	call, _ = callTracker.Get(pbx, linkedId)
+	holdCall(db, call)
+
+	eventType := models.BusCallResumed
+	if call.State == models.CallOnHold {
+		eventType = models.BusCallHeld
+	}
+	publishEvent(models.BusEvent{Type: eventType, Pbx: call.Pbx, Call: &call})
+}
+
+// holdCall refleja en la base de datos la espera actual y los acumulados de la llamada
+func holdCall(db models.ConnMysql, call models.TrackedCall) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	hold := "N"
+	if call.State == models.CallOnHold {
+		hold = "S"
+	}
+
+	query := `UPDATE current_calls AS cc INNER JOIN calls AS c ON c.id = cc.id_call SET cc.hold = ? WHERE c.pbx = ? AND c.uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, hold, call.Pbx, call.Linkedid)
+	if err != nil {
+		utils.Logline("Failed to update hold on current_calls", call.Linkedid, err)
+		return fmt.Errorf("failed to update hold on current_calls")
+	}
+
+	query = `UPDATE calls SET hold_count = ?, hold_seconds = ? WHERE pbx = ? AND uniqueid = ?`
+	_, err = db.Conn.ExecContext(ctx, query, call.HoldCount, call.HoldSeconds, call.Pbx, call.Linkedid)
+	if err != nil {
+		utils.Logline("Failed to update hold on calls", call.Linkedid, err)
+		return fmt.Errorf("failed to update hold on calls")
+	}
+
+	return nil
+}
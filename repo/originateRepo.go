@@ -0,0 +1,327 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// valores por defecto de las llamadas click-to-call
+const (
+	defaultOriginateRingTimeout = 30 * time.Second
+	defaultOriginateContext     = "from-internal"
+)
+
+var ErrOriginateNotFound = errors.New("originate not found")
+var ErrOriginateAgent = errors.New("extension is not an active agent")
+var ErrOriginateAgentBusy = errors.New("agent has a call in progress")
+var ErrPhoneDnc = errors.New("phone is marked as do not call")
+var ErrPhoneBlacklisted = errors.New("phone is blacklisted")
+var ErrPhoneNotAllowed = errors.New("phone does not match the allowed numbers")
+
+// numeros que se pueden marcar por defecto: fijos (02xx) y moviles (04xx) nacionales y
+// locales de 7 digitos, quedan fuera los internacionales (00), tarifa especial (0900) y similares
+const defaultOriginateAllowedPattern = `^(0[24][0-9]{9}|[2-9][0-9]{6})$`
+
+// originateAllowed patron de ORIGINATE_ALLOWED_PATTERN, si es invalido se usa el por defecto
+var originateAllowed = sync.OnceValue(func() *regexp.Regexp {
+	pattern := os.Getenv("ORIGINATE_ALLOWED_PATTERN")
+	if pattern == "" {
+		pattern = defaultOriginateAllowedPattern
+	}
+	allowed, err := regexp.Compile(pattern)
+	if err != nil {
+		utils.Logline("invalid ORIGINATE_ALLOWED_PATTERN, using the default", pattern, err)
+		return regexp.MustCompile(defaultOriginateAllowedPattern)
+	}
+	return allowed
+})
+
+// nombre de variable de canal, con _ o __ al inicio se heredan a los canales hijos
+var originateVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// razones del OriginateResponse, ver enum ast_control_frame_type de asterisk
+var originateReasons = map[string]string{
+	"0": "failed",
+	"1": "hangup",
+	"3": "ring timeout",
+	"4": "answered",
+	"5": "busy",
+	"8": "congestion",
+}
+
+const originateColumns = `id, action_id, pbx, agent, phone, caller_id, account_code, variables, status, reason, uniqueid, channel,
+	requested_by, datetime_request, datetime_response`
+
+// OriginateCall click-to-call, repica la extension del agente y al contestar marca el numero.
+// La llamada se registra en calls antes de enviar el Originate asi sus eventos ya la
+// encuentran rastreada, el uniqueid final llega en el OriginateResponse
+func OriginateCall(db models.ConnMysql, req models.OriginateReq, requestedBy string) (models.CallOriginate, error) {
+	for name := range req.Variables {
+		if !originateVariableName.MatchString(name) {
+			return models.CallOriginate{}, fmt.Errorf("invalid channel variable name %q", name)
+		}
+	}
+
+	pbx, err := utils.PbxForExtension(req.Extension)
+	if err != nil {
+		return models.CallOriginate{}, err
+	}
+	// revision rapida antes de ir a mysql, la que cuenta es la de startOriginate
+	if _, busy := callTracker.ActiveByAgent(pbx.Name, req.Extension); busy {
+		return models.CallOriginate{}, ErrOriginateAgentBusy
+	}
+	if err := checkDialable(db, req.Phone); err != nil {
+		return models.CallOriginate{}, err
+	}
+	agentId := getAgentId(db, req.Extension)
+	if agentId == 0 {
+		return models.CallOriginate{}, ErrOriginateAgent
+	}
+
+	ringTimeout := envDuration("ORIGINATE_RING_TIMEOUT", defaultOriginateRingTimeout)
+	if req.Timeout > 0 {
+		ringTimeout = time.Duration(req.Timeout) * time.Second
+	}
+	dialContext := os.Getenv("ORIGINATE_CONTEXT")
+	if dialContext == "" {
+		dialContext = defaultOriginateContext
+	}
+	callerId := req.CallerId
+	if callerId == "" {
+		callerId = fmt.Sprintf(`"%s" <%s>`, req.Phone, req.Phone)
+	}
+
+	originate := models.CallOriginate{Pbx: pbx.Name, Agent: req.Extension, Phone: req.Phone, CallerId: callerId,
+		AccountCode: req.AccountCode, Variables: req.Variables, Status: models.OriginateQueued, RequestedBy: requestedBy}
+	err = startOriginate(db, pbx, &originate, agentId, models.Originate{
+		Agent:       req.Extension,
+		Phone:       req.Phone,
+		Context:     dialContext,
+		CallerId:    callerId,
+		RingTimeout: ringTimeout,
+		AccountCode: req.AccountCode,
+		Variables:   req.Variables,
+	})
+	if err != nil {
+		return originate, err
+	}
+
+	// el OriginateResponse pudo llegar antes que la respuesta de la accion
+	return GetOriginate(db, originate.Id)
+}
+
+// startOriginate registra la llamada en call_originates y en calls, la rastrea y encola el
+// Originate, el resultado llega en el OriginateResponse. Lo usan la click-to-call y las
+// rellamadas (ver callbacksRepo.go)
+func startOriginate(db models.ConnMysql, pbx models.Pbx, originate *models.CallOriginate, agentId int, dial models.Originate) error {
+	if err := insertOriginate(db, originate); err != nil {
+		return err
+	}
+
+	// la saliente se rastrea como cualquier otra, el ChannelId es el Linkedid de la llamada.
+	// El agente se toma en el mismo paso, si mientras tanto recibio otra llamada no se origina
+	rules := activeRules.Load()
+	call := models.TrackedCall{Linkedid: originate.ActionId, Pbx: pbx.Name, Direction: models.DirectionOutbound, Agent: originate.Agent, Originated: true}
+	if !callTracker.StartIfAgentIdle(call) {
+		failOriginate(db, pbx.Name, originate.ActionId, ErrOriginateAgentBusy.Error(), "")
+		return ErrOriginateAgentBusy
+	}
+	channel := utils.ExtensionChannel(pbx, originate.Agent).Dial()
+	if err := insertOutboundCall(db, originate.ActionId, agentId, originate.Agent, channel, originate.Phone, rules.cfg.Outbound, pbx.Name); err != nil {
+		callTracker.Remove(pbx.Name, originate.ActionId)
+		failOriginate(db, pbx.Name, originate.ActionId, err.Error(), "")
+		return err
+	}
+	publishCall(models.BusCallStarted, pbx.Name, originate.ActionId)
+
+	dial.ChannelId = originate.ActionId
+	if err := queueOriginate(pbx, dial, originate.ActionId); err != nil {
+		utils.Logline("error on originate", originate.ActionId, err)
+		failOriginate(db, pbx.Name, originate.ActionId, err.Error(), "")
+		return fmt.Errorf("an error occurred executing ami command")
+	}
+	return nil
+}
+
+// checkDialable el numero debe cumplir ORIGINATE_ALLOWED_PATTERN y no puede estar marcado
+// como dnc en calls ni en la lista negra, vale para la click-to-call y las rellamadas
+func checkDialable(db models.ConnMysql, phone string) error {
+	if !originateAllowed().MatchString(phone) {
+		return ErrPhoneNotAllowed
+	}
+
+	var dnc, blacklisted bool
+	err := db.Conn.QueryRowContext(db.Ctx, `SELECT EXISTS(SELECT 1 FROM calls WHERE phone = ? AND dnc = 1)`, phone).Scan(&dnc)
+	if err != nil {
+		utils.Logline("error checking dnc", phone, err)
+		return err
+	}
+	if dnc {
+		return ErrPhoneDnc
+	}
+
+	err = db.Conn.QueryRowContext(db.Ctx, `SELECT EXISTS(SELECT 1 FROM call_blacklist WHERE phone = ?)`, phone).Scan(&blacklisted)
+	if err != nil {
+		utils.Logline("error checking blacklist", phone, err)
+		return err
+	}
+	if blacklisted {
+		return ErrPhoneBlacklisted
+	}
+	return nil
+}
+
+// insertOriginate registra la llamada originada, el ActionID (y ChannelId) sale del id
+// salvo que ya venga asignado, ej: el intento de una rellamada
+func insertOriginate(db models.ConnMysql, originate *models.CallOriginate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var variables *string
+	if len(originate.Variables) > 0 {
+		raw, _ := json.Marshal(originate.Variables)
+		value := string(raw)
+		variables = &value
+	}
+
+	query := `INSERT INTO call_originates (action_id, pbx, agent, phone, caller_id, account_code, variables, status, requested_by, datetime_request)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 'queued', ?, NOW())`
+	res, err := db.Conn.ExecContext(ctx, query, originate.ActionId, originate.Pbx, originate.Agent, originate.Phone, originate.CallerId, originate.AccountCode,
+		variables, originate.RequestedBy)
+	if err != nil {
+		utils.Logline("Failed to insert originate", originate.Agent, originate.Phone, err)
+		return fmt.Errorf("failed to insert originate")
+	}
+	id, _ := res.LastInsertId()
+	originate.Id = int(id)
+	if originate.ActionId != "" {
+		return nil
+	}
+
+	originate.ActionId = fmt.Sprintf("click-%d", originate.Id)
+	_, err = db.Conn.ExecContext(ctx, `UPDATE call_originates SET action_id = ? WHERE id = ?`, originate.ActionId, originate.Id)
+	if err != nil {
+		utils.Logline("Failed to update originate", originate.Id, err)
+		return fmt.Errorf("failed to insert originate")
+	}
+	return nil
+}
+
+// handleOriginateResponse guarda el resultado de la click-to-call o la rellamada y lo publica en el bus,
+// si el agente no contesto la llamada se cierra como sin respuesta
+func handleOriginateResponse(db models.ConnMysql, pbx string, msg *goami2.Message) {
+	actionId := msg.ActionID()
+	reason := originateReasons[msg.Field("Reason")]
+	if reason == "" {
+		reason = msg.Field("Reason")
+	}
+	// el intento de rellamada termina aunque la llamada ya no este rastreada
+	if strings.HasPrefix(actionId, "callback-") {
+		finishCallbackOriginate(db, actionId, msg.Field("Response") == "Success", reason)
+	}
+	if _, ok := callTracker.Get(pbx, actionId); !ok {
+		return // Originate de otro cliente
+	}
+	utils.Logline("new event [originateresponse] ", msg)
+
+	uniqueId := msg.Field("Uniqueid")
+	if uniqueId == "<null>" {
+		uniqueId = ""
+	}
+
+	if msg.Field("Response") != "Success" {
+		failOriginate(db, pbx, actionId, reason, msg.Field("Channel"))
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		query := `UPDATE call_originates SET status = 'success', reason = ?, uniqueid = ?, channel = ?, datetime_response = NOW()
+			WHERE action_id = ? AND status = 'queued'`
+		if _, err := db.Conn.ExecContext(ctx, query, reason, uniqueId, msg.Field("Channel"), actionId); err != nil {
+			utils.Logline("Failed to update originate", msg, err)
+		}
+	}
+
+	if originate, err := originateByActionId(db, actionId); err == nil {
+		publishEvent(models.BusEvent{Type: models.BusOriginateResponse, Pbx: pbx, Originate: &originate})
+	}
+}
+
+// failOriginate marca la click-to-call como fallida y cierra la llamada si sigue abierta
+func failOriginate(db models.ConnMysql, pbx string, actionId string, reason string, channel string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `UPDATE call_originates SET status = 'failure', reason = ?, channel = NULLIF(?, ''), datetime_response = NOW()
+		WHERE action_id = ? AND status = 'queued'`
+	if _, err := db.Conn.ExecContext(ctx, query, truncate(reason, 255), channel, actionId); err != nil {
+		utils.Logline("Failed to update originate", actionId, err)
+	}
+
+	callTracker.Update(pbx, actionId, func(call *models.TrackedCall) {
+		if call.Outcome == "" && call.Hangup == nil {
+			call.Outcome = originateOutcome(reason)
+		}
+	})
+	if from, to, err := callTracker.Fire(pbx, actionId, models.EventHangup); err == nil && to.IsFinal() && !from.IsFinal() {
+		endCall(db, pbx, nil, actionId, to)
+	}
+}
+
+// originateOutcome resultado de la llamada cuando el agente no contesto el Originate
+func originateOutcome(reason string) string {
+	switch reason {
+	case "busy":
+		return models.OutcomeBusy
+	case "ring timeout":
+		return models.OutcomeNoAnswer
+	case "hangup":
+		return models.OutcomeRejected
+	case "congestion":
+		return models.OutcomeCongestion
+	}
+	return models.OutcomeFailed
+}
+
+// GetOriginate llamada originada por id
+func GetOriginate(db models.ConnMysql, id int) (models.CallOriginate, error) {
+	row := db.Conn.QueryRowContext(db.Ctx, `SELECT `+originateColumns+` FROM call_originates WHERE id = ?`, id)
+	return scanOriginate(row, id)
+}
+
+func originateByActionId(db models.ConnMysql, actionId string) (models.CallOriginate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	row := db.Conn.QueryRowContext(ctx, `SELECT `+originateColumns+` FROM call_originates WHERE action_id = ?`, actionId)
+	return scanOriginate(row, actionId)
+}
+
+func scanOriginate(row *sql.Row, key any) (models.CallOriginate, error) {
+	var o models.CallOriginate
+	var variables *string
+	err := row.Scan(&o.Id, &o.ActionId, &o.Pbx, &o.Agent, &o.Phone, &o.CallerId, &o.AccountCode, &variables, &o.Status, &o.Reason,
+		&o.Uniqueid, &o.Channel, &o.RequestedBy, &o.DatetimeRequest, &o.DatetimeResponse)
+	if errors.Is(err, sql.ErrNoRows) {
+		return o, ErrOriginateNotFound
+	}
+	if err != nil {
+		utils.Logline("error getting originate", key, err)
+		return o, err
+	}
+	if variables != nil {
+		json.Unmarshal([]byte(*variables), &o.Variables)
+	}
+	return o, nil
+}
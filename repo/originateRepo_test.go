@@ -0,0 +1,27 @@
+package repo
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestOriginateAllowedPattern(t *testing.T) {
+	allowed := regexp.MustCompile(defaultOriginateAllowedPattern)
+	tests := []struct {
+		phone string
+		want  bool
+	}{
+		{"04141234567", true},    // movil
+		{"02125551234", true},    // fijo
+		{"5551234", true},        // local
+		{"0058414123456", false}, // internacional
+		{"09001234567", false},   // tarifa especial
+		{"0414123456", false},    // incompleto
+		{"0123456", false},
+	}
+	for _, tt := range tests {
+		if got := allowed.MatchString(tt.phone); got != tt.want {
+			t.Errorf("%s allowed = %v, want %v", tt.phone, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// handleQueueEvent rastrea las llamadas entrantes de las colas declaradas en .callrules
+// QueueCallerJoin el cliente entra a la cola
+// AgentCalled la cola repica a un agente
+// AgentRingNoAnswer el agente no contesto, la llamada vuelve a esperar
+// AgentConnect un agente atendio la llamada
+// AgentComplete la llamada atendida termino
+// QueueCallerAbandon el cliente colgo antes de ser atendido
+func handleQueueEvent(db models.ConnMysql, pbx string, msg *goami2.Message, linkedId string, rules *callRules) {
+	queueRule, ok := rules.inboundQueue(msg.Field("Queue"))
+	if !ok {
+		return
+	}
+
+	if msg.Field("Event") == "QueueCallerJoin" {
+		call := models.TrackedCall{Linkedid: linkedId, Pbx: pbx, Direction: models.DirectionInbound, Queue: msg.Field("Queue")}
+		if !callTracker.Start(call) {
+			return
+		}
+		utils.Logline("new event [queuecallerjoin] ", msg)
+		if err := insertInboundCall(db, msg, queueRule, pbx); err != nil {
+			callTracker.Remove(pbx, linkedId)
+			return
+		}
+		publishCall(models.BusCallStarted, pbx, linkedId)
+		return
+	}
+
+	// tambien aplica a las llamadas transferidas hacia la cola
+	if call, ok := callTracker.Get(pbx, linkedId); !ok || (call.Direction != models.DirectionInbound && call.Transfers == 0) {
+		return
+	}
+
+	switch msg.Field("Event") {
+	case "AgentCalled":
+		fireCallEvent(pbx, linkedId, models.EventDial, msg)
+	case "AgentRingNoAnswer":
+		if _, _, ok := fireCallEvent(pbx, linkedId, models.EventRingNoAnswer, msg); ok {
+			callTracker.Update(pbx, linkedId, func(call *models.TrackedCall) { call.RingNoAnswer++ })
+			ringNoAnswerCall(db, pbx, msg, linkedId)
+		}
+	case "AgentConnect":
+		if from, _, ok := fireCallEvent(pbx, linkedId, models.EventAnswer, msg); ok {
+			agent := utils.ParseChannel(msg.Field("Interface")).Exten
+			utils.Logline("new event [agentconnect] ", msg)
+			if from == models.CallTransferred {
+				transferLegAnswered(db, pbx, msg, linkedId, agent)
+				return
+			}
+			callTracker.Update(pbx, linkedId, func(call *models.TrackedCall) { call.Agent = agent })
+			publishCall(models.BusCallAnswered, pbx, linkedId)
+			agentConnectCall(db, pbx, msg, linkedId, agent)
+			closeCallbacksAnswered(db, pbx, linkedId)
+		}
+	case "AgentComplete":
+		if from, to, ok := fireCallEvent(pbx, linkedId, models.EventHangup, msg); ok && to.IsFinal() && !from.IsFinal() {
+			utils.Logline("new event [agentcomplete] ", msg)
+			endCall(db, pbx, msg, linkedId, to)
+		}
+	case "QueueCallerAbandon":
+		if from, to, ok := fireCallEvent(pbx, linkedId, models.EventAbandon, msg); ok && to.IsFinal() && !from.IsFinal() {
+			utils.Logline("new event [queuecallerabandon] ", msg)
+			callTracker.Update(pbx, linkedId, func(call *models.TrackedCall) { call.Outcome = models.OutcomeAbandoned })
+			abandonCall(db, pbx, msg, linkedId)
+			endCall(db, pbx, msg, linkedId, to)
+		}
+	}
+}
+
+func insertInboundCall(db models.ConnMysql, msg *goami2.Message, rule models.QueueRule, pbx string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	uniqueIdDb := msg.Field("Linkedid")
+	callerIdNum := msg.Field("CallerIDNum")
+	channel := msg.Field("Channel")
+
+	query := `INSERT INTO calls (id_campaign, phone, status, uniqueid, fecha_llamada, retries, id_agent, datetime_entry_queue, duration_wait, dnc, datetime_originate, trunk, scheduled, direction, pbx)
+		VALUES (?, ?, 'Ringing', ?, NOW(), 0, NULL, NOW(), 0, 0, NOW(), ?, 0, 'inbound', ?)`
+	_, err := db.Conn.ExecContext(ctx, query, rule.CampaignId, callerIdNum, uniqueIdDb, channel, pbx)
+	if err != nil {
+		utils.Logline("Failed to insert inbound call: ", msg, err)
+		return fmt.Errorf("failed to insert inbound call")
+	}
+
+	var callId string
+	err = db.Conn.QueryRowContext(ctx, `SELECT id FROM calls WHERE pbx = ? AND uniqueid = ?`, pbx, uniqueIdDb).Scan(&callId)
+	if err != nil {
+		utils.Logline("Failed to insert inbound call: ", msg, err)
+		return fmt.Errorf("failed to insert inbound call")
+	}
+
+	query = `INSERT INTO current_calls (id_call, fecha_inicio, uniqueid, queue, agentnum, event, Channel, ChannelClient, hold)
+		VALUES (?, NOW(), ?, ?, '', 'Dialing', ?, ?, 'N')`
+	_, err = db.Conn.ExecContext(ctx, query, callId, uniqueIdDb, rule.Queue, channel, callerIdNum)
+	if err != nil {
+		utils.Logline("Failed to insert inbound current_call: ", msg, err)
+		return fmt.Errorf("failed to insert inbound current_call")
+	}
+
+	return nil
+}
+
+func ringNoAnswerCall(db models.ConnMysql, pbx string, msg *goami2.Message, uniqueIdDb string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `UPDATE calls SET ring_no_answer = ring_no_answer + 1 WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to update ring_no_answer", msg, err)
+		return fmt.Errorf("failed to update ring_no_answer")
+	}
+	return nil
+}
+
+func agentConnectCall(db models.ConnMysql, pbx string, msg *goami2.Message, uniqueIdDb string, agent string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	agentId := getAgentId(db, agent)
+	if agentId == 0 {
+		utils.Logline("answering agent is not registered on call_center", agent, msg)
+	}
+
+	// HoldTime es el tiempo que el cliente espero en la cola antes de ser atendido
+	query := `UPDATE calls SET status = 'Active', start_time = NOW(), duration_wait = ?, id_agent = NULLIF(?, 0) WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, msg.Field("HoldTime"), agentId, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to start inbound call", msg, err)
+		return fmt.Errorf("failed to start inbound call")
+	}
+
+	query = `UPDATE current_calls AS cc INNER JOIN calls AS c ON c.id = cc.id_call SET cc.event = 'Link', cc.agentnum = ?
+		WHERE c.pbx = ? AND c.uniqueid = ?`
+	_, err = db.Conn.ExecContext(ctx, query, agent, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to start inbound current_call", msg, err)
+		return fmt.Errorf("failed to start inbound current_call")
+	}
+
+	return nil
+}
+
+func abandonCall(db models.ConnMysql, pbx string, msg *goami2.Message, uniqueIdDb string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `UPDATE calls SET abandon_position = ? WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, msg.Field("Position"), pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to update abandon_position", msg, err)
+		return fmt.Errorf("failed to update abandon_position")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package repo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// cache de los miembros de las colas de cada central, se mantiene con los eventos
+// QueueMemberAdded y QueueMemberRemoved del listener y se carga con QueueStatus cada vez
+// que la sesion conecta. Mientras la cache de la central esta fria ExtensionStatus
+// consulta QueueStatus a la central
+type queueMemberCache struct {
+	mu       sync.RWMutex
+	members  map[string]map[string]map[string]queueMemberEntry // pbx -> cola -> extension
+	seededAt map[string]time.Time                              // pbx con la cache cargada
+}
+
+// queueMemberEntry los miembros retirados se conservan con member en false para que
+// una carga de QueueStatus mas vieja no los vuelva a agregar
+type queueMemberEntry struct {
+	member bool
+	at     time.Time
+}
+
+var queueMembers = &queueMemberCache{members: make(map[string]map[string]map[string]queueMemberEntry), seededAt: make(map[string]time.Time)}
+
+// handleQueueMemberEvent actualiza la cache con QueueMemberAdded o QueueMemberRemoved
+func handleQueueMemberEvent(pbx string, msg *goami2.Message) {
+	exten := queueMemberExten(msg)
+	if exten == "" {
+		return
+	}
+	queueMembers.set(pbx, msg.Field("Queue"), exten, msg.Field("Event") == "QueueMemberAdded", time.Now())
+}
+
+// queueMemberExten extension del miembro de la cola
+func queueMemberExten(msg *goami2.Message) string {
+	return utils.ParseChannel(queueMemberInterface(msg)).Exten
+}
+
+// queueMemberInterface Location es la interfaz del miembro, en los eventos QueueMember*
+// viene como Interface y en versiones viejas solo viene Name
+func queueMemberInterface(msg *goami2.Message) string {
+	for _, field := range []string{"Location", "Interface", "Name"} {
+		if iface := msg.Field(field); iface != "" {
+			return iface
+		}
+	}
+	return ""
+}
+
+// seedQueueMembers carga la cache de la central con QueueStatus, los eventos
+// recibidos mientras tanto son mas nuevos y no se pisan
+func seedQueueMembers(pbx models.Pbx) {
+	startedAt := time.Now()
+	queues, err := getAmiQueueStatus(pbx, "")
+	if err != nil {
+		utils.Logline("failed to load queue members", pbx.Name, err)
+		return
+	}
+
+	queueMembers.load(pbx.Name, queues, startedAt)
+	utils.Logline("queue members loaded", pbx.Name, len(queues))
+}
+
+// invalidate enfria la cache de la central, se llama cuando la sesion se cae
+func (c *queueMemberCache) invalidate(pbx string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seededAt, pbx)
+}
+
+// load aplica QueueStatus a la cache de la central y la marca caliente, los miembros
+// que no vienen en la respuesta se retiran salvo que un evento posterior a at los agregara
+func (c *queueMemberCache) load(pbx string, queues []models.QueueStats, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for queue, members := range c.members[pbx] {
+		for exten := range members {
+			c.setLocked(pbx, queue, exten, false, at)
+		}
+	}
+	loaded := make(map[string]bool)
+	for _, queue := range queues {
+		loaded[queue.Queue] = true
+		c.queue(pbx, queue.Queue)
+		for _, member := range queue.Members {
+			c.setLocked(pbx, queue.Queue, member.Extension, true, at)
+		}
+	}
+
+	// colas que ya no existen en la central y sin eventos recientes
+	for queue, members := range c.members[pbx] {
+		if loaded[queue] {
+			continue
+		}
+		stale := true
+		for _, entry := range members {
+			if entry.at.After(at) {
+				stale = false
+				break
+			}
+		}
+		if stale {
+			delete(c.members[pbx], queue)
+		}
+	}
+	c.seededAt[pbx] = time.Now()
+}
+
+// set guarda el miembro si el cambio es mas nuevo que el de la cache
+func (c *queueMemberCache) set(pbx string, queue string, exten string, member bool, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(pbx, queue, exten, member, at)
+}
+
+func (c *queueMemberCache) setLocked(pbx string, queue string, exten string, member bool, at time.Time) {
+	members := c.queue(pbx, queue)
+	if entry, ok := members[exten]; ok && entry.at.After(at) {
+		return
+	}
+	members[exten] = queueMemberEntry{member: member, at: at}
+}
+
+func (c *queueMemberCache) queue(pbx string, queue string) map[string]queueMemberEntry {
+	if c.members[pbx] == nil {
+		c.members[pbx] = make(map[string]map[string]queueMemberEntry)
+	}
+	if c.members[pbx][queue] == nil {
+		c.members[pbx][queue] = make(map[string]queueMemberEntry)
+	}
+	return c.members[pbx][queue]
+}
+
+// get retorna las colas de la central con sus miembros si la cache esta caliente
+func (c *queueMemberCache) get(pbx string) ([]models.QueueStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.seededAt[pbx]; !ok {
+		return nil, false
+	}
+	var queues []models.QueueStats
+	for queue, members := range c.members[pbx] {
+		stats := models.QueueStats{Queue: queue, Pbx: pbx, Members: []models.QueueMemberStats{}}
+		for exten, entry := range members {
+			if entry.member {
+				stats.Members = append(stats.Members, models.QueueMemberStats{Extension: exten})
+			}
+		}
+		queues = append(queues, stats)
+	}
+	return queues, true
+}
+
+// queueMembership miembros de las colas de todas las centrales desde la cache, las
+// centrales con la cache fria se consultan en paralelo
+func queueMembership() []models.QueueStats {
+	var queues []models.QueueStats
+	var cold []models.Pbx
+	for _, pbx := range utils.PbxList() {
+		if cached, ok := queueMembers.get(pbx.Name); ok {
+			queues = append(queues, cached...)
+			continue
+		}
+		cold = append(cold, pbx)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, pbx := range cold {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats, err := getAmiQueueStatus(pbx, "")
+			if err != nil {
+				utils.Logline("error getting queue status", pbx.Name, err)
+				return
+			}
+			mu.Lock()
+			queues = append(queues, stats...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return queues
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+)
+
+func queueMemberEvent(event string, queue string, iface string) *goami2.Message {
+	msg := goami2.NewMessage()
+	msg.AddField("Event", event)
+	msg.AddField("Queue", queue)
+	msg.AddField("Interface", iface)
+	return msg
+}
+
+func TestQueueMemberCache(t *testing.T) {
+	cache := queueMembers
+	queueMembers = &queueMemberCache{members: make(map[string]map[string]map[string]queueMemberEntry), seededAt: make(map[string]time.Time)}
+	t.Cleanup(func() { queueMembers = cache })
+
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberAdded", "8000", "PJSIP/8001"))
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberAdded", "8000", "Local/8002@from-queue/n"))
+	if _, ok := queueMembers.get("central"); ok {
+		t.Fatal("cache without QueueStatus should be cold")
+	}
+
+	queueMembers.mu.Lock()
+	queueMembers.seededAt["central"] = time.Now()
+	queueMembers.mu.Unlock()
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberRemoved", "8000", "PJSIP/8001"))
+
+	queues, ok := queueMembers.get("central")
+	if !ok || len(queues) != 1 {
+		t.Fatalf("unexpected queues: %+v", queues)
+	}
+	if members := queues[0].Members; len(members) != 1 || members[0].Extension != "8002" {
+		t.Errorf("members = %+v, want only 8002", members)
+	}
+
+	queueMembers.invalidate("central")
+	if _, ok := queueMembers.get("central"); ok {
+		t.Error("cache of a disconnected pbx should be cold")
+	}
+}
+
+// los eventos recibidos mientras QueueStatus estaba en curso no se pierden con la carga
+func TestQueueMemberSeedKeepsNewerEvents(t *testing.T) {
+	cache := queueMembers
+	queueMembers = &queueMemberCache{members: make(map[string]map[string]map[string]queueMemberEntry), seededAt: make(map[string]time.Time)}
+	t.Cleanup(func() { queueMembers = cache })
+
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberAdded", "8000", "PJSIP/8009"))
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberAdded", "9000", "PJSIP/9001"))
+	startedAt := time.Now()
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberAdded", "8000", "PJSIP/8003"))
+	handleQueueMemberEvent("central", queueMemberEvent("QueueMemberRemoved", "8000", "PJSIP/8002"))
+
+	// la respuesta de QueueStatus es anterior a los dos ultimos eventos
+	queueMembers.load("central", []models.QueueStats{{Queue: "8000", Members: []models.QueueMemberStats{{Extension: "8001"}, {Extension: "8002"}}}}, startedAt)
+
+	queues, ok := queueMembers.get("central")
+	if !ok || len(queues) != 1 || queues[0].Queue != "8000" {
+		t.Fatalf("unexpected queues: %+v", queues)
+	}
+	var extens []string
+	for _, member := range queues[0].Members {
+		extens = append(extens, member.Extension)
+	}
+	sort.Strings(extens)
+	if len(extens) != 2 || extens[0] != "8001" || extens[1] != "8003" {
+		t.Errorf("members = %v, want [8001 8003]", extens)
+	}
+}
@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// directorio por defecto de MixMonitor en issabel
+const defaultRecordingsDir = "/var/spool/asterisk/monitor"
+
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// handleRecordingEvent guarda el archivo de MixMonitor de una llamada rastreada, llega
+// por VarSet de MIXMONITOR_FILENAME o en MixMonitorStart si manager.conf tiene
+// channelvars=MIXMONITOR_FILENAME. Solo se guarda la primera grabacion de la llamada
+func handleRecordingEvent(db models.ConnMysql, pbx string, msg *goami2.Message, linkedId string, filename string) {
+	if filename == "" {
+		return
+	}
+	if _, ok := callTracker.Get(pbx, linkedId); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `UPDATE calls SET recording_file = ? WHERE pbx = ? AND uniqueid = ? AND recording_file IS NULL`
+	res, err := db.Conn.ExecContext(ctx, query, filename, pbx, linkedId)
+	if err != nil {
+		utils.Logline("Failed to update recording_file", msg, err)
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows > 0 {
+		utils.Logline("new event [recording] ", msg)
+	}
+}
+
+// GetCallRecording retorna la grabacion asociada a la llamada
+func GetCallRecording(db models.ConnMysql, callId int) (models.CallRecording, error) {
+	var recording models.CallRecording
+	var file sql.NullString
+
+	query := `SELECT id, uniqueid, recording_file FROM calls WHERE id = ?`
+	err := db.Conn.QueryRowContext(db.Ctx, query, callId).Scan(&recording.IdCall, &recording.Uniqueid, &file)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && file.String == "") {
+		return recording, ErrRecordingNotFound
+	}
+	if err != nil {
+		utils.Logline("error getting call recording", callId, err)
+		return recording, err
+	}
+
+	recording.File = file.String
+	return recording, nil
+}
+
+// RecordingPath ruta del archivo dentro de RECORDINGS_DIR, no permite salir del directorio
+func RecordingPath(file string) (string, error) {
+	dir := os.Getenv("RECORDINGS_DIR")
+	if dir == "" {
+		dir = defaultRecordingsDir
+	}
+	dir = filepath.Clean(dir)
+
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("recording %s is outside of %s", file, dir)
+	}
+	return path, nil
+}
+
+// LogRecordingAccess deja constancia de quien accedio a la grabacion
+func LogRecordingAccess(db models.ConnMysql, access models.RecordingAccess) {
+	utils.Logline("recording access", access)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO recording_access_log (id_call, username, client_ip, range_header, result, datetime_access)
+		VALUES (?, ?, ?, ?, ?, NOW())`
+	_, err := db.Conn.ExecContext(ctx, query, access.IdCall, access.Username, access.ClientIp, access.Range, access.Result)
+	if err != nil {
+		utils.Logline("Failed to insert recording_access_log", access, err)
+	}
+}
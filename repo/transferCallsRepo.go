@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// handleTransferEvent registra cada pata de transferencia de una llamada rastreada
+// BlindTransfer el agente envia la llamada a otra extension o cola sin consultar
+// AttendedTransfer el agente consulta primero al destino y luego le pasa la llamada,
+// el Linkedid de la consulta queda como alias de la llamada original
+func handleTransferEvent(db models.ConnMysql, pbx string, msg *goami2.Message, rules *callRules) {
+	if msg.Field("Result") != "Success" {
+		return
+	}
+
+	switch msg.Field("Event") {
+	case "BlindTransfer":
+		linkedId, ok := trackedLinkedId(pbx, msg.Field("TransfereeLinkedid"), msg.Field("TransfererLinkedid"))
+		if !ok {
+			return
+		}
+		target := msg.Field("Extension")
+		leg := models.CallTransfer{Type: "blind", FromAgent: msg.Field("TransfererCallerIDNum"), Target: target, TargetType: rules.targetType(target)}
+		if !startTransfer(db, pbx, msg, linkedId, leg) {
+			return
+		}
+	case "AttendedTransfer":
+		linkedId, ok := trackedLinkedId(pbx, msg.Field("TransfereeLinkedid"), msg.Field("OrigTransfererLinkedid"))
+		if !ok {
+			return
+		}
+		target := msg.Field("TransferTargetCallerIDNum")
+		if target == "" {
+			target = msg.Field("SecondTransfererConnectedLineNum")
+		}
+		leg := models.CallTransfer{Type: "attended", FromAgent: msg.Field("OrigTransfererCallerIDNum"), Target: target, TargetType: rules.targetType(target)}
+		if !startTransfer(db, pbx, msg, linkedId, leg) {
+			return
+		}
+
+		// la llamada de consulta termina y sus eventos pasan a la llamada original
+		for _, consultId := range []string{msg.Field("SecondTransfererLinkedid"), msg.Field("TransferTargetLinkedid")} {
+			if consultId == "" || consultId == linkedId {
+				continue
+			}
+			if from, to, err := callTracker.Fire(pbx, consultId, models.EventBridgeLeave); err == nil && to.IsFinal() && !from.IsFinal() {
+				finishCall(db, pbx, consultId, to)
+			}
+			callTracker.Alias(pbx, consultId, linkedId)
+		}
+
+		// al completarse la transferencia atendida el destino ya esta hablando con el cliente
+		if msg.Field("DestType") != "Fail" {
+			if from, to, ok := fireCallEvent(pbx, linkedId, models.EventBridgeEnter, msg); ok && from == models.CallTransferred && to == models.CallActive {
+				transferLegAnswered(db, pbx, msg, linkedId, target)
+			}
+		}
+	}
+}
+
+// trackedLinkedId retorna el primero de los Linkedid que corresponda a una llamada rastreada
+func trackedLinkedId(pbx string, linkedIds ...string) (string, bool) {
+	for _, linkedId := range linkedIds {
+		if linkedId == "" {
+			continue
+		}
+		linkedId = callTracker.Resolve(pbx, linkedId)
+		if _, ok := callTracker.Get(pbx, linkedId); ok {
+			return linkedId, true
+		}
+	}
+	return "", false
+}
+
+// startTransfer pasa la llamada a Transferred y guarda la pata en call_transfers
+func startTransfer(db models.ConnMysql, pbx string, msg *goami2.Message, linkedId string, leg models.CallTransfer) bool {
+	if _, _, ok := fireCallEvent(pbx, linkedId, models.EventTransfer, msg); !ok {
+		return false
+	}
+	callTracker.Update(pbx, linkedId, func(call *models.TrackedCall) { call.Transfers++ })
+	utils.Logline(fmt.Sprintf("new event [%s] ", msg.Field("Event")), msg)
+	if call, ok := callTracker.Get(pbx, linkedId); ok {
+		publishEvent(models.BusEvent{Type: models.BusCallTransferred, Pbx: call.Pbx, Call: &call, Transfer: &leg})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO call_transfers (id_call, uniqueid, transfer_type, from_agent, target, target_type, datetime_transfer)
+		SELECT id, uniqueid, ?, ?, ?, ?, NOW() FROM calls WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, leg.Type, leg.FromAgent, leg.Target, leg.TargetType, pbx, linkedId)
+	if err != nil {
+		utils.Logline("Failed to insert call_transfer", msg, err)
+	}
+	return true
+}
+
+// transferLegAnswered el destino de la transferencia atendio, pasa a ser el agente de la llamada
+func transferLegAnswered(db models.ConnMysql, pbx string, msg *goami2.Message, uniqueIdDb string, agent string) error {
+	callTracker.Update(pbx, uniqueIdDb, func(call *models.TrackedCall) { call.Agent = agent })
+	publishCall(models.BusCallAnswered, pbx, uniqueIdDb)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `UPDATE calls SET status = 'Active', transfer = ?, resolved_by = ? WHERE pbx = ? AND uniqueid = ?`
+	_, err := db.Conn.ExecContext(ctx, query, agent, agent, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("failed to update transferred call on db", msg, err)
+		return fmt.Errorf("failed to update transferred call on db")
+	}
+
+	query = `UPDATE current_calls AS cc INNER JOIN calls AS c ON c.id = cc.id_call
+		SET cc.agentnum = ?, cc.event = 'Link', cc.Channel = COALESCE(NULLIF(?, ''), cc.Channel)
+		WHERE c.pbx = ? AND c.uniqueid = ?`
+	_, err = db.Conn.ExecContext(ctx, query, agent, msg.Field("Channel"), pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to update transferred current_call", msg, err)
+		return fmt.Errorf("failed to update transferred current_call")
+	}
+
+	query = `UPDATE call_transfers SET answered_by = ?, datetime_answer = NOW()
+		WHERE id_call = (SELECT id FROM calls WHERE pbx = ? AND uniqueid = ?) AND answered_by IS NULL ORDER BY id DESC LIMIT 1`
+	_, err = db.Conn.ExecContext(ctx, query, agent, pbx, uniqueIdDb)
+	if err != nil {
+		utils.Logline("Failed to update call_transfer", msg, err)
+		return fmt.Errorf("failed to update call_transfer")
+	}
+
+	return nil
+}
+
+// CallTransfers cadena de transferencias de una llamada de la central, sin central la
+// central por defecto
+func CallTransfers(db models.ConnMysql, pbx string, uniqueId string) ([]models.CallTransfer, error) {
+	if pbx == "" {
+		pbx = utils.DefaultPbx().Name
+	}
+	query := `SELECT t.id, t.transfer_type, t.from_agent, t.target, t.target_type, t.answered_by, t.datetime_transfer, t.datetime_answer
+		FROM call_transfers AS t
+		INNER JOIN calls AS c ON c.id = t.id_call
+		WHERE c.pbx = ? AND c.uniqueid = ? ORDER BY t.id ASC`
+	rows, err := db.Conn.QueryContext(db.Ctx, query, pbx, uniqueId)
+	if err != nil {
+		utils.Logline("error getting call transfers", uniqueId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []models.CallTransfer{}
+	for rows.Next() {
+		var leg models.CallTransfer
+		if err := rows.Scan(&leg.Id, &leg.Type, &leg.FromAgent, &leg.Target, &leg.TargetType, &leg.AnsweredBy, &leg.TransferredAt, &leg.AnsweredAt); err != nil {
+			utils.Logline("error scanning call transfers", uniqueId, err)
+			return nil, err
+		}
+		transfers = append(transfers, leg)
+	}
+
+	return transfers, rows.Err()
+}
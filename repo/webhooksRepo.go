@@ -0,0 +1,542 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+// valores por defecto de las entregas de webhooks
+const (
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookRetryBase   = 30 * time.Second // espera antes del segundo intento, se duplica en cada fallo
+	defaultWebhookRetryMax    = 1 * time.Hour
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookWorkers     = 4
+	webhookSendingTimeout     = 5 * time.Minute // una entrega en 'sending' por mas tiempo quedo huerfana
+	webhookRetryEvery         = 5 * time.Second
+	webhookBatch              = 100
+)
+
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+var ErrWebhookDeliveryInProgress = errors.New("webhook delivery is being sent")
+
+var webhookNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,60}$`)
+
+// webhookConfig suscripciones validadas e indexadas por nombre
+type webhookConfig struct {
+	list   []models.Webhook
+	byName map[string]models.Webhook
+}
+
+var activeWebhooks atomic.Pointer[webhookConfig]
+
+func init() {
+	activeWebhooks.Store(&webhookConfig{list: []models.Webhook{}, byName: map[string]models.Webhook{}})
+}
+
+// webhookDispatcher suscriptor del bus que guarda las entregas y las envia con sus workers
+type webhookDispatcher struct {
+	db      models.ConnMysql
+	sub     *BusSubscription
+	queue   chan int // id de webhook_deliveries
+	workers int
+	client  *http.Client
+	since   time.Time
+
+	sent      atomic.Int64
+	delivered atomic.Int64
+	failed    atomic.Int64
+	dead      atomic.Int64
+	lost      atomic.Int64 // entregas que no se pudieron guardar en webhook_deliveries
+}
+
+var webhooks *webhookDispatcher
+var webhooksOnce sync.Once
+
+// LoadWebhooks lee, valida y activa las suscripciones del archivo indicado. Si el archivo
+// no existe no se envian webhooks. Si es invalido retorna el error de validacion y las
+// suscripciones activas no cambian
+func LoadWebhooks(path string) ([]models.Webhook, error) {
+	// open file
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		utils.Logline("webhooks file not found, no webhooks are sent", path)
+		return GetWebhooks(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks %s: %v", path, err)
+	}
+	defer file.Close()
+
+	// decode json data to struct
+	var list []models.Webhook
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid webhooks %s: %v", path, err)
+	}
+
+	cfg, err := compileWebhooks(list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhooks %s: %v", path, err)
+	}
+
+	activeWebhooks.Store(cfg)
+	utils.Logline("webhooks loaded", path, len(list))
+	return GetWebhooks(), nil
+}
+
+// GetWebhooks suscripciones activas sin el secreto
+func GetWebhooks() []models.Webhook {
+	list := []models.Webhook{}
+	for _, webhook := range activeWebhooks.Load().list {
+		webhook.Secret = ""
+		list = append(list, webhook)
+	}
+	return list
+}
+
+func compileWebhooks(list []models.Webhook) (*webhookConfig, error) {
+	var errs []string
+	cfg := &webhookConfig{list: list, byName: make(map[string]models.Webhook)}
+	if cfg.list == nil {
+		cfg.list = []models.Webhook{}
+	}
+
+	for i, webhook := range list {
+		if !webhookNameRegex.MatchString(webhook.Name) {
+			errs = append(errs, fmt.Sprintf("[%d].name: %q must be letters, numbers, _ . or - up to 60 chars", i, webhook.Name))
+		}
+		if _, ok := cfg.byName[webhook.Name]; ok {
+			errs = append(errs, fmt.Sprintf("[%d].name: %q is duplicated", i, webhook.Name))
+		}
+		if u, err := url.Parse(webhook.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("[%d].url: %q must be an http or https url", i, webhook.Url))
+		}
+		if len(webhook.Secret) < 16 {
+			errs = append(errs, fmt.Sprintf("[%d].secret: at least 16 chars are required", i))
+		}
+		if err := models.ValidBusEventTypes(webhook.Types); err != nil {
+			errs = append(errs, fmt.Sprintf("[%d].types: %v", i, err))
+		}
+		cfg.byName[webhook.Name] = webhook
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return cfg, nil
+}
+
+// StartWebhooks se suscribe al bus y lanza los workers de entrega y el ciclo de reintentos,
+// las entregas pendientes de una ejecucion anterior se retoman desde webhook_deliveries
+func StartWebhooks(db models.ConnMysql) {
+	webhooksOnce.Do(func() {
+		webhooks = &webhookDispatcher{
+			db:      db,
+			queue:   make(chan int, envInt("WEBHOOK_BUFFER", 1000)),
+			workers: envInt("WEBHOOK_WORKERS", defaultWebhookWorkers),
+			client:  &http.Client{},
+			since:   time.Now(),
+		}
+
+		// el bus no espera a los suscriptores, para no perder eventos cuando mysql tarda los
+		// que se acumularon se guardan juntos y los descartados quedan en WebhookStats
+		webhooks.sub = SubscribeEvents("webhooks", envInt("WEBHOOK_BUFFER", 1000), models.BusDropOldest)
+		go func() {
+			for ev := range webhooks.sub.Events() {
+				webhooks.enqueue(webhookBatchOf(ev, webhooks.sub.Events()))
+			}
+		}()
+
+		for range webhooks.workers {
+			go func() {
+				for id := range webhooks.queue {
+					webhooks.send(id, false)
+				}
+			}()
+		}
+
+		go func() {
+			for range time.Tick(webhookRetryEvery) {
+				webhooks.retryDue()
+			}
+		}()
+		utils.Logline("webhooks dispatcher started", webhooks.workers)
+	})
+}
+
+// WebhookStats metricas del despachador, nil si no se inicio
+func WebhookStats() *models.WebhookStats {
+	if webhooks == nil {
+		return nil
+	}
+	return &models.WebhookStats{
+		Webhooks:     len(activeWebhooks.Load().list),
+		Workers:      webhooks.workers,
+		QueueDepth:   len(webhooks.queue),
+		Sent:         webhooks.sent.Load(),
+		Delivered:    webhooks.delivered.Load(),
+		Failed:       webhooks.failed.Load(),
+		Dead:         webhooks.dead.Load(),
+		Dropped:      webhooks.sub.dropped.Load(),
+		Lost:         webhooks.lost.Load(),
+		RunningSince: webhooks.since,
+	}
+}
+
+// webhookBatchOf el evento recibido y los que ya esperan en la cola, hasta webhookBatch
+func webhookBatchOf(ev models.BusEvent, events <-chan models.BusEvent) []models.BusEvent {
+	batch := []models.BusEvent{ev}
+	for len(batch) < webhookBatch {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, ev)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// enqueue guarda en un solo INSERT una entrega por cada webhook interesado en cada evento y
+// despierta a los workers con las entregas pendientes
+func (d *webhookDispatcher) enqueue(events []models.BusEvent) {
+	var values []string
+	var args []any
+	webhookList := activeWebhooks.Load().list
+	for _, ev := range events {
+		var payload []byte
+		for _, webhook := range webhookList {
+			if webhook.Disabled || !webhook.Filter().Match(ev) {
+				continue
+			}
+			if payload == nil {
+				var err error
+				if payload, err = json.Marshal(ev); err != nil {
+					utils.Logline("Failed to encode webhook payload", ev.Id, err)
+					break
+				}
+			}
+			values = append(values, `(?, ?, ?, ?, ?, 'pending', NOW(), NOW())`)
+			args = append(args, webhook.Name, webhook.Url, ev.Type, ev.Id, string(payload))
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	// si el INSERT del lote falla se reintenta fila por fila, los eventos ya salieron del
+	// bus y solo se pierden las entregas que tampoco se pueden guardar solas
+	if err := d.insertDeliveries(values, args); err != nil {
+		utils.Logline("Failed to insert webhook deliveries, inserting one by one", len(events), len(values), err)
+		for i := range values {
+			row := args[i*webhookDeliveryArgs : (i+1)*webhookDeliveryArgs]
+			if err := d.insertDeliveries(values[i:i+1], row); err != nil {
+				d.lost.Add(1)
+				utils.Logline("Failed to insert webhook delivery, lost", row[0], row[3], err)
+			}
+		}
+	}
+	d.queueDue()
+}
+
+const webhookDeliveryArgs = 5 // valores de cada entrega en el INSERT de enqueue
+
+func (d *webhookDispatcher) insertDeliveries(values []string, args []any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhook_deliveries (webhook, url, event_type, event_id, payload, status, datetime_created, datetime_next_attempt)
+		VALUES ` + strings.Join(values, ", ")
+	_, err := d.db.Conn.ExecContext(ctx, query, args...)
+	return err
+}
+
+// retryDue libera las entregas huerfanas y encola las que ya deben reintentarse
+func (d *webhookDispatcher) retryDue() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries SET status = 'retrying', datetime_next_attempt = NOW()
+		WHERE status = 'sending' AND datetime_sending < NOW() - INTERVAL ? SECOND`
+	if _, err := d.db.Conn.ExecContext(ctx, query, int(webhookSendingTimeout.Seconds())); err != nil {
+		utils.Logline("Failed to release webhook deliveries", err)
+		return
+	}
+	d.queueDue()
+}
+
+// queueDue pasa a los workers las entregas pendientes o que ya deben reintentarse, si la
+// cola esta llena las que faltan se toman en el siguiente ciclo
+func (d *webhookDispatcher) queueDue() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id FROM webhook_deliveries
+		WHERE status IN ('pending', 'retrying') AND datetime_next_attempt <= NOW()
+		ORDER BY datetime_next_attempt ASC
+		LIMIT ?`
+	rows, err := d.db.Conn.QueryContext(ctx, query, webhookBatch)
+	if err != nil {
+		utils.Logline("Failed to get due webhook deliveries", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			utils.Logline("Failed to scan due webhook delivery", err)
+			return
+		}
+		select {
+		case d.queue <- id:
+		default:
+			return
+		}
+	}
+}
+
+// send toma la entrega y hace un intento, manual es el reenvio desde administracion
+// que se permite en cualquier estado menos mientras otro worker la envia
+func (d *webhookDispatcher) send(id int, manual bool) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries SET status = 'sending', datetime_sending = NOW()
+		WHERE id = ? AND status IN ('pending', 'retrying') AND datetime_next_attempt <= NOW()`
+	if manual {
+		query = `UPDATE webhook_deliveries SET status = 'sending', datetime_sending = NOW() WHERE id = ? AND status <> 'sending'`
+	}
+	res, err := d.db.Conn.ExecContext(ctx, query, id)
+	if err != nil {
+		utils.Logline("Failed to claim webhook delivery", id, err)
+		return models.WebhookDelivery{}, err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		if !manual {
+			return models.WebhookDelivery{}, nil
+		}
+		if _, err := GetWebhookDelivery(d.db, id); err != nil {
+			return models.WebhookDelivery{}, err
+		}
+		return models.WebhookDelivery{}, ErrWebhookDeliveryInProgress
+	}
+
+	delivery, err := scanWebhookDelivery(d.db.Conn.QueryRowContext(ctx, `SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id))
+	if err != nil {
+		utils.Logline("Failed to get webhook delivery", id, err)
+		return delivery, err
+	}
+
+	// el secreto y la url se toman de la configuracion actual
+	webhook, ok := activeWebhooks.Load().byName[delivery.Webhook]
+	var statusCode int
+	var sendErr error
+	var elapsed time.Duration
+	switch {
+	case !ok:
+		sendErr = fmt.Errorf("webhook is no longer configured")
+	case webhook.Disabled && !manual:
+		sendErr = fmt.Errorf("webhook is disabled")
+	default:
+		started := time.Now()
+		statusCode, sendErr = d.post(webhook, delivery)
+		elapsed = time.Since(started)
+	}
+
+	d.sent.Add(1)
+	attempt := delivery.Attempts + 1
+	status := models.WebhookDelivered
+	var nextAttempt time.Duration
+	var errText string
+	if sendErr != nil {
+		errText = sendErr.Error()
+		if len(errText) > 255 {
+			errText = errText[:255]
+		}
+		d.failed.Add(1)
+		status, nextAttempt = webhookRetry(attempt)
+		if !ok || (webhook.Disabled && !manual) {
+			status = models.WebhookDead
+		}
+		if status == models.WebhookDead {
+			d.dead.Add(1)
+		}
+		utils.Logline("webhook delivery failed", delivery.Webhook, id, attempt, status, errText)
+	} else {
+		d.delivered.Add(1)
+	}
+	if ok {
+		delivery.Url = webhook.Url
+	}
+
+	// el POST pudo tardar hasta WEBHOOK_TIMEOUT, el resultado se guarda con su propio plazo
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query = `INSERT INTO webhook_delivery_attempts (id_delivery, attempt, status_code, error, duration_ms, manual, datetime_attempt)
+		VALUES (?, ?, NULLIF(?, 0), NULLIF(?, ''), ?, ?, NOW())`
+	_, err = d.db.Conn.ExecContext(ctx, query, id, attempt, statusCode, errText, elapsed.Milliseconds(), manual)
+	if err != nil {
+		utils.Logline("Failed to insert webhook delivery attempt", id, err)
+	}
+
+	query = `UPDATE webhook_deliveries SET status = ?, attempts = ?, url = ?, last_status_code = NULLIF(?, 0), last_error = NULLIF(?, ''),
+			datetime_next_attempt = IF(? = 'retrying', NOW() + INTERVAL ? SECOND, NULL),
+			datetime_delivered = IF(? = 'delivered', NOW(), datetime_delivered)
+		WHERE id = ?`
+	_, err = d.db.Conn.ExecContext(ctx, query, status, attempt, delivery.Url, statusCode, errText,
+		status, int(nextAttempt.Seconds()), status, id)
+	if err != nil {
+		utils.Logline("Failed to update webhook delivery", id, err)
+		return delivery, err
+	}
+
+	return GetWebhookDelivery(d.db, id)
+}
+
+// post envia el payload firmado, cualquier respuesta que no sea 2xx es un fallo
+func (d *webhookDispatcher) post(webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("WEBHOOK_TIMEOUT", defaultWebhookTimeout))
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ired-callcenter-webhooks")
+	req.Header.Set(utils.WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(utils.WebhookHeaderDelivery, fmt.Sprint(delivery.Id))
+	req.Header.Set(utils.WebhookHeaderTimestamp, fmt.Sprint(timestamp))
+	req.Header.Set(utils.WebhookHeaderSignature, utils.SignWebhook(webhook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// webhookRetry estado luego de un intento fallido y la espera hasta el siguiente, la
+// espera se duplica en cada intento hasta WEBHOOK_RETRY_MAX
+func webhookRetry(attempt int) (string, time.Duration) {
+	if attempt >= envInt("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts) {
+		return models.WebhookDead, 0
+	}
+	wait := envDuration("WEBHOOK_RETRY_BASE", defaultWebhookRetryBase)
+	maxWait := envDuration("WEBHOOK_RETRY_MAX", defaultWebhookRetryMax)
+	for i := 1; i < attempt && wait < maxWait; i++ {
+		wait *= 2
+	}
+	return models.WebhookRetrying, min(wait, maxWait)
+}
+
+const webhookDeliveryColumns = `id, webhook, url, event_type, event_id, payload, status, attempts, last_status_code, last_error,
+	datetime_created, datetime_next_attempt, datetime_delivered`
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := row.Scan(&delivery.Id, &delivery.Webhook, &delivery.Url, &delivery.EventType, &delivery.EventId, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.LastStatusCode, &delivery.LastError, &delivery.DateCreated,
+		&delivery.DateNextAttempt, &delivery.DateDelivered)
+	return delivery, err
+}
+
+// WebhookDeliveries entregas en el estado indicado, las mas recientes primero
+func WebhookDeliveries(db models.ConnMysql, status string, filter models.WebhookDeliveriesReq) ([]models.WebhookDelivery, error) {
+	if filter.Limit == 0 {
+		filter.Limit = 100
+	}
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE status = ? AND (? = '' OR webhook = ?) AND (? = '' OR event_type = ?)
+		ORDER BY id DESC
+		LIMIT ?`
+	rows, err := db.Conn.QueryContext(db.Ctx, query, status, filter.Webhook, filter.Webhook, filter.Type, filter.Type, filter.Limit)
+	if err != nil {
+		utils.Logline("error getting webhook deliveries", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			utils.Logline("error scanning webhook deliveries", err)
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDelivery entrega con cada intento y la respuesta del receptor
+func GetWebhookDelivery(db models.ConnMysql, id int) (models.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(db.Conn.QueryRowContext(db.Ctx, `SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return delivery, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		utils.Logline("error getting webhook delivery", id, err)
+		return delivery, err
+	}
+
+	query := `SELECT attempt, status_code, error, duration_ms, datetime_attempt, manual
+		FROM webhook_delivery_attempts WHERE id_delivery = ? ORDER BY id ASC`
+	rows, err := db.Conn.QueryContext(db.Ctx, query, id)
+	if err != nil {
+		utils.Logline("error getting webhook delivery attempts", id, err)
+		return delivery, err
+	}
+	defer rows.Close()
+
+	delivery.AttemptList = []models.WebhookDeliveryAttempt{}
+	for rows.Next() {
+		var attempt models.WebhookDeliveryAttempt
+		if err := rows.Scan(&attempt.Attempt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMs, &attempt.DateAttempt, &attempt.Manual); err != nil {
+			utils.Logline("error scanning webhook delivery attempts", id, err)
+			return delivery, err
+		}
+		delivery.AttemptList = append(delivery.AttemptList, attempt)
+	}
+	return delivery, rows.Err()
+}
+
+// RedeliverWebhook reenvia la entrega ahora y retorna el resultado del intento, si falla
+// vuelve a reintentarse solo si aun no agoto WEBHOOK_MAX_ATTEMPTS
+func RedeliverWebhook(id int) (models.WebhookDelivery, error) {
+	if webhooks == nil {
+		return models.WebhookDelivery{}, fmt.Errorf("webhooks dispatcher is not running")
+	}
+	return webhooks.send(id, true)
+}
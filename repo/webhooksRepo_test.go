@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ired.com/callcenter/models"
+	"ired.com/callcenter/utils"
+)
+
+const testWebhookSecret = "0123456789abcdef"
+
+// useTestWebhooks activa las suscripciones durante la prueba
+func useTestWebhooks(t *testing.T, list []models.Webhook) {
+	t.Helper()
+	cfg, err := compileWebhooks(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := activeWebhooks.Load()
+	activeWebhooks.Store(cfg)
+	t.Cleanup(func() { activeWebhooks.Store(previous) })
+}
+
+// el receptor valida la firma, un fallo se reintenta y al agotar los intentos la entrega muere
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string // secreto con el que el receptor valida la firma
+		statusCode int
+		attempts   int // intentos previos de la entrega
+		status     string
+		wait       int // segundos hasta el siguiente intento
+	}{
+		{"delivered", testWebhookSecret, http.StatusNoContent, 0, models.WebhookDelivered, 0},
+		{"bad signature", "fedcba9876543210", http.StatusNoContent, 0, models.WebhookRetrying, 30},
+		{"retry", testWebhookSecret, http.StatusInternalServerError, 2, models.WebhookRetrying, 120},
+		{"dead letter", testWebhookSecret, http.StatusInternalServerError, defaultWebhookMaxAttempts - 1, models.WebhookDead, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				err := utils.VerifyWebhook(tt.secret, r.Header.Get(utils.WebhookHeaderSignature), r.Header.Get(utils.WebhookHeaderTimestamp), body, time.Minute)
+				if err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if r.Header.Get(utils.WebhookHeaderDelivery) != "5" || r.Header.Get(utils.WebhookHeaderEvent) != models.BusCallStarted {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer srv.Close()
+			useTestWebhooks(t, []models.Webhook{{Name: "crm", Url: srv.URL, Secret: testWebhookSecret}})
+
+			db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+				if strings.Contains(query, "FROM webhook_deliveries") {
+					return strings.Split(strings.Join(strings.Fields(webhookDeliveryColumns), ""), ","), [][]driver.Value{{
+						int64(5), "crm", srv.URL, models.BusCallStarted, int64(11), `{"type":"call_started"}`, models.WebhookPending,
+						int64(tt.attempts), nil, nil, "2026-10-18 10:00:00", nil, nil,
+					}}
+				}
+				return nil, nil
+			})
+			d := &webhookDispatcher{db: db, queue: make(chan int, 1), client: srv.Client()}
+
+			if _, err := d.send(5, false); err != nil {
+				t.Fatal(err)
+			}
+			updates := fake.Execs("UPDATE webhook_deliveries SET status = ?")
+			if len(updates) != 1 {
+				t.Fatalf("delivery updates = %+v", updates)
+			}
+			if status, wait := updates[0].Args[0], updates[0].Args[6]; status != tt.status || wait != int64(tt.wait) {
+				t.Errorf("status = %v wait = %v, want %s %d", status, wait, tt.status, tt.wait)
+			}
+			if dead := d.dead.Load(); (tt.status == models.WebhookDead) != (dead == 1) {
+				t.Errorf("dead = %d", dead)
+			}
+		})
+	}
+}
+
+// los eventos acumulados se guardan en un solo INSERT y pasan a los workers
+func TestWebhookEnqueueBatch(t *testing.T) {
+	useTestWebhooks(t, []models.Webhook{
+		{Name: "crm", Url: "https://crm.example.com/hook", Secret: testWebhookSecret},
+		{Name: "bi", Url: "https://bi.example.com/hook", Secret: testWebhookSecret, Types: []string{models.BusCallEnded}},
+	})
+	db, fake := newFakeMysql(t, func(query string, args []any) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "SELECT id FROM webhook_deliveries") {
+			return []string{"id"}, [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}}
+		}
+		return nil, nil
+	})
+	d := &webhookDispatcher{db: db, queue: make(chan int, 10)}
+
+	events := make(chan models.BusEvent, 10)
+	events <- models.BusEvent{Id: 2, Type: models.BusCallEnded}
+	d.enqueue(webhookBatchOf(models.BusEvent{Id: 1, Type: models.BusCallStarted}, events))
+
+	inserts := fake.Execs("INSERT INTO webhook_deliveries")
+	if len(inserts) != 1 || len(inserts[0].Args) != 3*5 {
+		t.Fatalf("want one INSERT with 3 deliveries, got %+v", inserts)
+	}
+	if len(d.queue) != 3 {
+		t.Errorf("queued deliveries = %d, want 3", len(d.queue))
+	}
+}
+
+// si el INSERT del lote falla las entregas se guardan una por una y las que no se pueden
+// guardar se cuentan como perdidas
+func TestWebhookEnqueueFallback(t *testing.T) {
+	useTestWebhooks(t, []models.Webhook{{Name: "crm", Url: "https://crm.example.com/hook", Secret: testWebhookSecret}})
+	tests := []struct {
+		name    string
+		fail    func(query string) error
+		inserts int
+		lost    int64
+	}{
+		{"batch fails", func(query string) error {
+			if strings.Contains(query, "), (") {
+				return errors.New("packet too large")
+			}
+			return nil
+		}, 2, 0},
+		{"mysql down", func(query string) error {
+			if strings.Contains(query, "INSERT INTO webhook_deliveries") {
+				return errors.New("connection refused")
+			}
+			return nil
+		}, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeMysql(t, nil)
+			fake.err = tt.fail
+			d := &webhookDispatcher{db: db, queue: make(chan int, 10)}
+
+			d.enqueue([]models.BusEvent{{Id: 1, Type: models.BusCallStarted}, {Id: 2, Type: models.BusCallEnded}})
+			if inserts := fake.Execs("INSERT INTO webhook_deliveries"); len(inserts) != tt.inserts {
+				t.Errorf("inserts = %d, want %d", len(inserts), tt.inserts)
+			}
+			if lost := d.lost.Load(); lost != tt.lost {
+				t.Errorf("lost = %d, want %d", lost, tt.lost)
+			}
+		})
+	}
+}
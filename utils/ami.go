@@ -1,26 +1,172 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/staskobzar/goami2"
+	"ired.com/callcenter/models"
 )
 
-func ConnectToAmi() (*goami2.Client, error) {
+// nombre de la central cuando se usan las variables AMI_SERVER/AMI_USER/AMI_PASSWD
+const DefaultPbxName = "default"
+
+// central con las regex ya compiladas
+type pbxConn struct {
+	models.Pbx
+	patterns []*regexp.Regexp
+	channels []channelRule
+}
+
+// centrales configuradas, se cargan una sola vez al iniciar el servicio
+var pbxList []pbxConn
+
+// LoadPbxConfig lee la lista de centrales del archivo indicado, si el archivo no
+// existe se usa una sola central con las variables AMI_SERVER/AMI_USER/AMI_PASSWD
+func LoadPbxConfig(path string) error {
+	var list []models.Pbx
+
+	file, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		list = []models.Pbx{envPbx()}
+	case err != nil:
+		return fmt.Errorf("failed to read pbx config %s: %v", path, err)
+	default:
+		defer file.Close()
+		decoder := json.NewDecoder(file)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&list); err != nil {
+			return fmt.Errorf("invalid pbx config %s: %v", path, err)
+		}
+	}
+
+	// validar la configuracion
+	var errs []string
+	var conns []pbxConn
+	seen := make(map[string]bool)
+	if len(list) == 0 {
+		errs = append(errs, "at least one pbx is required")
+	}
+	for i, pbx := range list {
+		if pbx.Name == "" {
+			errs = append(errs, fmt.Sprintf("[%d].name: required", i))
+		}
+		if seen[pbx.Name] {
+			errs = append(errs, fmt.Sprintf("[%d].name: %q is duplicated", i, pbx.Name))
+		}
+		seen[pbx.Name] = true
+		if pbx.Server == "" {
+			errs = append(errs, fmt.Sprintf("[%d].server: required", i))
+		}
+		conn := pbxConn{Pbx: pbx}
+		for k, pattern := range pbx.Extensions {
+			re, err := regexp.Compile(pattern)
+			if err != nil || pattern == "" {
+				errs = append(errs, fmt.Sprintf("[%d].extensions[%d]: invalid pattern %q", i, k, pattern))
+				continue
+			}
+			conn.patterns = append(conn.patterns, re)
+		}
+		if pbx.Technology != "" && !validTechnology(pbx.Technology) {
+			errs = append(errs, fmt.Sprintf("[%d].technology: %q is not SIP, PJSIP, IAX2 or Local", i, pbx.Technology))
+		}
+		for k, rule := range pbx.Channels {
+			re, err := regexp.Compile(rule.Extensions)
+			if err != nil || rule.Extensions == "" {
+				errs = append(errs, fmt.Sprintf("[%d].channels[%d].extensions: invalid pattern %q", i, k, rule.Extensions))
+				continue
+			}
+			if !validTechnology(rule.Technology) {
+				errs = append(errs, fmt.Sprintf("[%d].channels[%d].technology: %q is not SIP, PJSIP, IAX2 or Local", i, k, rule.Technology))
+				continue
+			}
+			conn.channels = append(conn.channels, channelRule{ChannelRule: rule, pattern: re})
+		}
+		conns = append(conns, conn)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid pbx config %s: %s", path, strings.Join(errs, "; "))
+	}
+
+	pbxList = conns
+	return nil
+}
+
+// PbxList centrales configuradas en el orden del archivo
+func PbxList() []models.Pbx {
+	list := make([]models.Pbx, len(pbxList))
+	for i, conn := range pbxList {
+		list[i] = conn.Pbx
+	}
+	return list
+}
+
+// DefaultPbx primera central configurada, es la dueña de las llamadas sin central
+func DefaultPbx() models.Pbx {
+	if len(pbxList) == 0 {
+		return envPbx()
+	}
+	return pbxList[0].Pbx
+}
+
+// envPbx central definida con las variables AMI_* del .env
+func envPbx() models.Pbx {
+	return models.Pbx{Name: DefaultPbxName, Server: os.Getenv("AMI_SERVER"), User: os.Getenv("AMI_USER"), Password: os.Getenv("AMI_PASSWD"),
+		Technology: os.Getenv("AMI_TECHNOLOGY")}
+}
+
+// GetPbx central por nombre
+func GetPbx(name string) (models.Pbx, bool) {
+	for _, conn := range pbxList {
+		if conn.Name == name {
+			return conn.Pbx, true
+		}
+	}
+	return models.Pbx{}, false
+}
+
+// PbxForExtension central dueña de la extension, la primera cuyas regex coincidan o
+// la primera sin regex. Con una sola central todas las extensiones son suyas
+func PbxForExtension(exten string) (models.Pbx, error) {
+	if len(pbxList) <= 1 {
+		return DefaultPbx(), nil
+	}
+	for _, conn := range pbxList {
+		for _, re := range conn.patterns {
+			if re.MatchString(exten) {
+				return conn.Pbx, nil
+			}
+		}
+	}
+	for _, conn := range pbxList {
+		if len(conn.patterns) == 0 {
+			return conn.Pbx, nil
+		}
+	}
+	return models.Pbx{}, fmt.Errorf("no pbx owns the extension %s", exten)
+}
+
+func ConnectToAmi(pbx models.Pbx) (*goami2.Client, error) {
 	// Connect to Asterisk AMI
-	connPbx, err := net.Dial("tcp", os.Getenv("AMI_SERVER"))
+	connPbx, err := net.DialTimeout("tcp", pbx.Server, 5*time.Second)
 	if err != nil {
-		Logline("Error connecting to Asterisk", err)
-		return nil, fmt.Errorf("error connecting to asterisk")
+		Logline("Error connecting to Asterisk", pbx.Name, err)
+		return nil, fmt.Errorf("error connecting to asterisk %s", pbx.Name)
 	}
 
 	// Login to AMI
-	clientAmi, err := goami2.NewClient(connPbx, os.Getenv("AMI_USER"), os.Getenv("AMI_PASSWD"))
+	clientAmi, err := goami2.NewClient(connPbx, pbx.User, pbx.Password)
 	if err != nil {
-		Logline("Error logging into AMI", err)
-		return nil, fmt.Errorf("error logging into ami")
+		connPbx.Close()
+		Logline("Error logging into AMI", pbx.Name, err)
+		return nil, fmt.Errorf("error logging into ami %s", pbx.Name)
 	}
 
 	return clientAmi, nil
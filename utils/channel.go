@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	"ired.com/callcenter/models"
+)
+
+// contexto por defecto de los canales Local
+const defaultLocalContext = "from-internal"
+
+// sufijo unico que asterisk agrega al nombre del canal, ej: SIP/8001-0000001a
+var channelSuffix = regexp.MustCompile(`-[0-9a-fA-F]{4,}$`)
+
+// regla de tecnologia con la regex compilada
+type channelRule struct {
+	models.ChannelRule
+	pattern *regexp.Regexp
+}
+
+// validTechnology indica si la tecnologia es soportada
+func validTechnology(tech string) bool {
+	switch tech {
+	case models.TechSIP, models.TechPJSIP, models.TechIAX2, models.TechLocal:
+		return true
+	}
+	return false
+}
+
+// ExtensionChannel canal de la extension en su central segun los rangos de .pbx, sin
+// regla que coincida se usa la tecnologia por defecto de la central (SIP)
+func ExtensionChannel(pbx models.Pbx, exten string) models.Channel {
+	channel := models.Channel{Tech: models.TechSIP, Exten: exten}
+	if pbx.Technology != "" {
+		channel.Tech = pbx.Technology
+	}
+
+	for _, conn := range pbxList {
+		if conn.Name != pbx.Name {
+			continue
+		}
+		for _, rule := range conn.channels {
+			if rule.pattern.MatchString(exten) {
+				channel.Tech = rule.Technology
+				channel.Context = rule.Context
+				break
+			}
+		}
+	}
+
+	if channel.Tech == models.TechLocal && channel.Context == "" {
+		channel.Context = defaultLocalContext
+	}
+	return channel
+}
+
+// ParseChannel obtiene la tecnologia y la extension del nombre de un canal o de la
+// interfaz de un miembro de cola, ej:
+// SIP/8001-0000001a, PJSIP/8001, IAX2/8001-1234, Local/8001@from-queue-00000012;1
+func ParseChannel(name string) models.Channel {
+	tech, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return models.Channel{Exten: name}
+	}
+	channel := models.Channel{Tech: tech}
+
+	// Local/8001@from-queue/n, el ;1 o ;2 indica el lado del canal Local
+	if i := strings.IndexAny(rest, ";"); i >= 0 {
+		rest = rest[:i]
+	}
+	rest = channelSuffix.ReplaceAllString(rest, "")
+	exten, context, _ := strings.Cut(rest, "@")
+	if i := strings.Index(context, "/"); i >= 0 {
+		context = context[:i]
+	}
+
+	channel.Exten = exten
+	if strings.EqualFold(tech, models.TechLocal) {
+		channel.Context = context
+	}
+	return channel
+}
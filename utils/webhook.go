@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cabeceras de cada entrega de webhook
+const (
+	WebhookHeaderSignature = "X-Callcenter-Signature" // sha256=<hex>
+	WebhookHeaderTimestamp = "X-Callcenter-Timestamp" // unix en segundos, forma parte de la firma
+	WebhookHeaderEvent     = "X-Callcenter-Event"
+	WebhookHeaderDelivery  = "X-Callcenter-Delivery" // id de webhook_deliveries, se repite en los reintentos
+)
+
+// SignWebhook firma del cuerpo: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook valida la firma recibida y que el timestamp no tenga mas de tolerance,
+// asi el receptor descarta cuerpos alterados o reenviados por terceros
+func VerifyWebhook(secret string, signature string, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := time.Since(time.Unix(ts, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return fmt.Errorf("timestamp out of tolerance: %s", age.Round(time.Second))
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("invalid signature format")
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignWebhook(secret, ts, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}